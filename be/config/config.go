@@ -9,13 +9,36 @@ import (
 
 type Config struct {
 	PORT string `env:"APP_PORT" envDefault:":7000"`
+	URL  string `env:"APP_URL" envDefault:"http://localhost:7000"`
 	JWT  JWT
 	DB   DB
+	Mail Mail
+
+	// PasswordResetURL is the frontend page opened by mailed reset links, with the token appended.
+	PasswordResetURL string `env:"PASSWORD_RESET_URL" envDefault:"http://localhost:3000/password/reset"`
+
+	PasswordPolicy PasswordPolicy
+	LoginThrottle  LoginThrottle
+	Deletion       AccountDeletion
+	Export         DataExport
+	Storage        Storage
+	Photo          Photo
+	Discovery      Discovery
+	OIDCProviders  []OIDCProvider `envPrefix:"OIDC_PROVIDER"`
 }
 
 type JWT struct {
-	Secret string `env:"JWT_SECRET" envDefault:"super-secret"`
-	Expiry int    `env:"JWT_EXPIRY" envDefault:"3600"`
+	Secret             string `env:"JWT_SECRET" envDefault:"super-secret"`
+	Expiry             int    `env:"JWT_EXPIRY" envDefault:"3600"`
+	RefreshExpiry      int    `env:"JWT_REFRESH_EXPIRY" envDefault:"2592000"`
+	VerificationExpiry int    `env:"JWT_VERIFICATION_EXPIRY" envDefault:"86400"`
+	ResetExpiry        int    `env:"JWT_RESET_EXPIRY" envDefault:"3600"`
+	MFAExpiry          int    `env:"JWT_MFA_EXPIRY" envDefault:"300"`
+	// Algorithm is one of HS256, RS256 or EdDSA. Asymmetric algorithms sign with PrivateKeyFile
+	// and also accept tokens signed by the keys in PublicKeyFiles, which allows key rotation.
+	Algorithm      string   `env:"JWT_ALGORITHM" envDefault:"HS256"`
+	PrivateKeyFile string   `env:"JWT_PRIVATE_KEY_FILE"`
+	PublicKeyFiles []string `env:"JWT_PUBLIC_KEY_FILES" envSeparator:","`
 }
 
 type DB struct {
@@ -26,6 +49,90 @@ type DB struct {
 	Database string `env:"DB_NAME" envDefault:"postgres"`
 }
 
+type Mail struct {
+	Driver string `env:"MAIL_DRIVER" envDefault:"log"`
+	From   string `env:"MAIL_FROM" envDefault:"no-reply@dating-app.local"`
+	Dir    string `env:"MAIL_DIR" envDefault:"mails"`
+}
+
+type PasswordPolicy struct {
+	MinLength     int  `env:"PASSWORD_MIN_LENGTH" envDefault:"8"`
+	RequireUpper  bool `env:"PASSWORD_REQUIRE_UPPER" envDefault:"false"`
+	RequireLower  bool `env:"PASSWORD_REQUIRE_LOWER" envDefault:"false"`
+	RequireDigit  bool `env:"PASSWORD_REQUIRE_DIGIT" envDefault:"false"`
+	RequireSymbol bool `env:"PASSWORD_REQUIRE_SYMBOL" envDefault:"false"`
+}
+
+// LoginThrottle locks an account or IP for LockoutSeconds after the configured number of
+// consecutive failures, doubling the lockout for every further failure up to MaxLockoutSeconds.
+type LoginThrottle struct {
+	AccountMaxAttempts int `env:"LOGIN_ACCOUNT_MAX_ATTEMPTS" envDefault:"5"`
+	IPMaxAttempts      int `env:"LOGIN_IP_MAX_ATTEMPTS" envDefault:"20"`
+	WindowSeconds      int `env:"LOGIN_ATTEMPT_WINDOW" envDefault:"900"`
+	LockoutSeconds     int `env:"LOGIN_LOCKOUT" envDefault:"60"`
+	MaxLockoutSeconds  int `env:"LOGIN_MAX_LOCKOUT" envDefault:"3600"`
+}
+
+// AccountDeletion keeps an account for GraceSeconds after the user asks for its deletion.
+// The erasure job looks for accounts past their grace period every JobIntervalSeconds.
+type AccountDeletion struct {
+	GraceSeconds       int `env:"ACCOUNT_DELETION_GRACE" envDefault:"2592000"`
+	JobIntervalSeconds int `env:"ACCOUNT_DELETION_JOB_INTERVAL" envDefault:"3600"`
+}
+
+// DataExport stores the export archives in Dir for ExpirySeconds. The export job picks up
+// new requests every JobIntervalSeconds, and builds again an export left processing for
+// ProcessingTimeoutSeconds by a worker that stopped.
+type DataExport struct {
+	Dir                      string `env:"EXPORT_DIR" envDefault:"exports"`
+	ExpirySeconds            int    `env:"EXPORT_EXPIRY" envDefault:"604800"`
+	JobIntervalSeconds       int    `env:"EXPORT_JOB_INTERVAL" envDefault:"10"`
+	ProcessingTimeoutSeconds int    `env:"EXPORT_PROCESSING_TIMEOUT" envDefault:"3600"`
+}
+
+// Storage selects the blob store for uploaded files. The local driver writes to Dir and the files are
+// served under BaseURL by the application. The s3 driver talks to any S3-compatible endpoint using
+// path-style requests, and objects are linked under S3PublicURL.
+type Storage struct {
+	Driver      string `env:"STORAGE_DRIVER" envDefault:"local"`
+	Dir         string `env:"STORAGE_DIR" envDefault:"uploads"`
+	BaseURL     string `env:"STORAGE_BASE_URL" envDefault:"http://localhost:7000/media"`
+	S3Endpoint  string `env:"STORAGE_S3_ENDPOINT"`
+	S3Region    string `env:"STORAGE_S3_REGION" envDefault:"us-east-1"`
+	S3Bucket    string `env:"STORAGE_S3_BUCKET"`
+	S3AccessKey string `env:"STORAGE_S3_ACCESS_KEY"`
+	S3SecretKey string `env:"STORAGE_S3_SECRET_KEY"`
+	S3PublicURL string `env:"STORAGE_S3_PUBLIC_URL"`
+}
+
+// Photo limits the gallery of a profile to MaxCount photos of at most MaxSize bytes each.
+type Photo struct {
+	MaxSize  int64 `env:"PHOTO_MAX_SIZE" envDefault:"5242880"`
+	MaxCount int   `env:"PHOTO_MAX_COUNT" envDefault:"6"`
+}
+
+// Discovery hides a profile the user swiped left on for PassResurfaceSeconds, after which it can
+// be shown again. Zero keeps it hidden for good. A user can send SuperlikeDailyLimit superlikes a
+// day, or SuperlikePremiumDailyLimit with an active subscription. Subscribers can undo a swipe for
+// UndoWindowSeconds.
+type Discovery struct {
+	PassResurfaceSeconds       int `env:"DISCOVERY_PASS_RESURFACE" envDefault:"2592000"`
+	SuperlikeDailyLimit        int `env:"SUPERLIKE_DAILY_LIMIT" envDefault:"1"`
+	SuperlikePremiumDailyLimit int `env:"SUPERLIKE_PREMIUM_DAILY_LIMIT" envDefault:"5"`
+	UndoWindowSeconds          int `env:"SWIPE_UNDO_WINDOW" envDefault:"300"`
+}
+
+// OIDCProvider configures an OpenID Connect issuer such as Google or Apple, read from
+// OIDC_PROVIDER_<n>_NAME, OIDC_PROVIDER_<n>_ISSUER and so on.
+type OIDCProvider struct {
+	Name         string   `env:"NAME"`
+	Issuer       string   `env:"ISSUER"`
+	ClientID     string   `env:"CLIENT_ID"`
+	ClientSecret string   `env:"CLIENT_SECRET"`
+	RedirectURL  string   `env:"REDIRECT_URL"`
+	Scopes       []string `env:"SCOPES" envSeparator:"," envDefault:"openid,email,profile"`
+}
+
 func New(file string) (*Config, error) {
 	if err := godotenv.Load(file); err != nil {
 		log.Printf("unable to load .env file: %e", err)
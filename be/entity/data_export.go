@@ -0,0 +1,34 @@
+package entity
+
+import "time"
+
+const (
+	ExportStatusPending    = "pending"
+	ExportStatusProcessing = "processing"
+	ExportStatusReady      = "ready"
+	ExportStatusFailed     = "failed"
+	ExportStatusExpired    = "expired"
+)
+
+// DataExport is a request for a ZIP archive of everything stored about a user.
+type DataExport struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	UserID      uint       `json:"-"`
+	Status      string     `json:"status"`
+	FilePath    string     `json:"-"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"-"`
+}
+
+// UserData is the data of a user included in a data export.
+type UserData struct {
+	User          User
+	ViewsMade     []ProfileViewLog
+	ViewsReceived []ProfileViewLog
+	SwipesMade    []Swipe
+	Matches       []Match
+	Subscriptions []Subscription
+	Preference    *DiscoveryPreference
+}
@@ -0,0 +1,26 @@
+package entity
+
+import "time"
+
+// LoginAttempt tracks consecutive failed logins for a key such as an account email or a client IP.
+type LoginAttempt struct {
+	Key          string     `json:"key" gorm:"primaryKey"`
+	Failures     int        `json:"failures"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (a *LoginAttempt) IsLocked(now time.Time) bool {
+	return a.LockedUntil != nil && a.LockedUntil.After(now)
+}
+
+// LoginLockout is the audit record written every time a key gets locked out.
+type LoginLockout struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Key         string    `json:"key"`
+	IP          string    `json:"ip"`
+	Failures    int       `json:"failures"`
+	LockedUntil time.Time `json:"locked_until"`
+	CreatedAt   time.Time `json:"created_at"`
+}
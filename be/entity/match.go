@@ -1,17 +1,54 @@
 package entity
 
+import "time"
+
+// Match is a mutual like between two profiles. ProfileID is the profile that liked first and
+// CreatedAt the time of the match. An unmatched match is kept to record who ended it and when.
 type Match struct {
-	ID        int    `gorm:"primaryKey" json:"id,omitempty"`
-	ProfileID int    `json:"profile_id,omitempty"`
-	PartnerID int    `json:"partner_id,omitempty"`
-	Status    string `json:"status,omitempty"`
+	ID          int        `gorm:"primaryKey" json:"id,omitempty"`
+	ProfileID   int        `json:"profile_id,omitempty"`
+	PartnerID   int        `json:"partner_id,omitempty"`
+	Status      string     `json:"status,omitempty"`
+	UnmatchedBy *int       `json:"unmatched_by,omitempty"`
+	UnmatchedAt *time.Time `json:"unmatched_at,omitempty"`
+	// ProfileOpenedAt and PartnerOpenedAt are when each side first opened the match.
+	ProfileOpenedAt *time.Time `json:"profile_opened_at,omitempty"`
+	PartnerOpenedAt *time.Time `json:"partner_opened_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
 
-	Profile Profile `gorm:"foreignKey:ProfileID;references:ID" json:"profile,omitempty"`
-	Partner Profile `gorm:"foreignKey:PartnerID;references:ID" json:"-"`
+	Profile *Profile `gorm:"foreignKey:ProfileID;references:ID" json:"profile,omitempty"`
+	Partner *Profile `gorm:"foreignKey:PartnerID;references:ID" json:"-"`
 }
 
 const (
-	StatusPending  = "pending"
-	StatusAccepted = "accepted"
-	StatusRejected = "rejected"
+	StatusAccepted  = "accepted"
+	StatusUnmatched = "unmatched"
 )
+
+// IsActive reports whether the two profiles are still matched. Anything shared between them, such
+// as their conversation, is only reachable while the match is active.
+func (m *Match) IsActive() bool {
+	return m.Status == StatusAccepted
+}
+
+// Involves reports whether the profile is one of the two sides of the match.
+func (m *Match) Involves(profileID int) bool {
+	return m.ProfileID == profileID || m.PartnerID == profileID
+}
+
+// MatchListItem is a match as listed to one of its sides. IsNew stays set until that side opens it.
+type MatchListItem struct {
+	MatchID   int           `json:"match_id"`
+	MatchedAt time.Time     `json:"matched_at"`
+	IsNew     bool          `json:"is_new"`
+	Name      string        `json:"name"`
+	Partner   PublicProfile `json:"partner"`
+}
+
+// MatchPage is a page of matches, the most recent first. NextCursor fetches the following page and
+// is empty on the last one.
+type MatchPage struct {
+	Matches    []MatchListItem `json:"matches"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,48 @@
+package entity
+
+import "time"
+
+const (
+	DefaultMinAge        = 18
+	DefaultMaxAge        = 99
+	DefaultMaxDistanceKm = 100
+)
+
+// DiscoveryPreference describes who a user wants to be shown. An empty Genders list accepts every gender
+// and a nil MaxDistanceKm stands for DefaultMaxDistanceKm.
+type DiscoveryPreference struct {
+	ID            uint      `json:"-" gorm:"primaryKey"`
+	UserID        uint      `json:"-"`
+	MinAge        int       `json:"min_age"`
+	MaxAge        int       `json:"max_age"`
+	Genders       []string  `json:"genders" gorm:"serializer:json"`
+	MaxDistanceKm *int      `json:"max_distance_km"`
+	OnlyWithPhoto bool      `json:"only_with_photo"`
+	CreatedAt     time.Time `json:"-"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// DefaultDiscoveryPreference returns the preferences of a user who has not set any.
+func DefaultDiscoveryPreference(userID uint) *DiscoveryPreference {
+	return &DiscoveryPreference{
+		UserID:  userID,
+		MinAge:  DefaultMinAge,
+		MaxAge:  DefaultMaxAge,
+		Genders: []string{},
+	}
+}
+
+// BirthdateRange returns the range of birthdates, earliest exclusive and latest inclusive,
+// of people whose age at now lies between MinAge and MaxAge.
+func (p *DiscoveryPreference) BirthdateRange(now time.Time) (time.Time, time.Time) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	return today.AddDate(-p.MaxAge-1, 0, 0), today.AddDate(-p.MinAge, 0, 0)
+}
+
+// RadiusKm returns the maximum distance of the people the user wants to be shown.
+func (p *DiscoveryPreference) RadiusKm() int {
+	if p == nil || p.MaxDistanceKm == nil {
+		return DefaultMaxDistanceKm
+	}
+	return *p.MaxDistanceKm
+}
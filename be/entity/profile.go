@@ -1,14 +1,153 @@
 package entity
 
+import (
+	"encoding/json"
+	"main/geo"
+	"time"
+)
+
+const (
+	GenderMale      = "male"
+	GenderFemale    = "female"
+	GenderNonBinary = "non_binary"
+)
+
+const birthdateLayout = "2006-01-02"
+
 type Profile struct {
-	ID          uint   `json:"id"`
-	UserID      uint   `json:"user_id"`
-	Picture     string `json:"picture"`
-	Description string `json:"description"`
+	ID                uint       `json:"id"`
+	UserID            uint       `json:"user_id"`
+	Picture           string     `json:"picture"`
+	Description       string     `json:"description"`
+	Birthdate         *time.Time `json:"birthdate,omitempty" gorm:"type:date"`
+	Gender            string     `json:"gender"`
+	InterestedIn      []string   `json:"interested_in" gorm:"serializer:json"`
+	City              string     `json:"city"`
+	Latitude          *float64   `json:"latitude,omitempty"`
+	Longitude         *float64   `json:"longitude,omitempty"`
+	LocationUpdatedAt *time.Time `json:"location_updated_at,omitempty"`
+	HeightCm          *int       `json:"height_cm"`
+	Job               string     `json:"job"`
+	Interests         []string   `json:"interests" gorm:"serializer:json"`
+
+	Photos []ProfilePhoto `json:"photos" gorm:"foreignKey:ProfileID"`
+
+	// DistanceKm is the exact distance to the viewer, read by discovery and never serialized.
+	DistanceKm *float64 `json:"-" gorm:"->;-:migration"`
+	// Superliked tells whether the profile superliked the viewer, read by discovery.
+	Superliked bool `json:"-" gorm:"->;-:migration"`
+}
+
+// AgeAt returns the age in whole years at the given time, or nil when the birthdate is unknown.
+func (p *Profile) AgeAt(now time.Time) *int {
+	if p.Birthdate == nil {
+		return nil
+	}
+	age := now.Year() - p.Birthdate.Year()
+	if now.Month() < p.Birthdate.Month() || (now.Month() == p.Birthdate.Month() && now.Day() < p.Birthdate.Day()) {
+		age--
+	}
+	return &age
+}
+
+// HasLocation reports whether the profile has coordinates.
+func (p *Profile) HasLocation() bool {
+	return p.Latitude != nil && p.Longitude != nil
+}
+
+// DistanceKmTo returns the distance to another profile, or nil when either location is unknown.
+func (p *Profile) DistanceKmTo(other *Profile) *float64 {
+	if !p.HasLocation() || !other.HasLocation() {
+		return nil
+	}
+	distance := geo.HaversineKm(*p.Latitude, *p.Longitude, *other.Latitude, *other.Longitude)
+	return &distance
+}
+
+// PublicProfile is the profile as shown to other users. The exact birthdate and coordinates are left out
+// and the distance is rounded.
+type PublicProfile struct {
+	ID           uint     `json:"id"`
+	UserID       uint     `json:"user_id"`
+	Picture      string   `json:"picture"`
+	Description  string   `json:"description"`
+	Age          *int     `json:"age,omitempty"`
+	DistanceKm   *int     `json:"distance_km,omitempty"`
+	Gender       string   `json:"gender"`
+	InterestedIn []string `json:"interested_in"`
+	City         string   `json:"city"`
+	HeightCm     *int     `json:"height_cm"`
+	Job          string   `json:"job"`
+	Interests    []string `json:"interests"`
+	Superliked   bool     `json:"superliked_you,omitempty"`
+
+	Photos []ProfilePhoto `json:"photos"`
+}
+
+func (p *Profile) Public() PublicProfile {
+	var distance *int
+	if p.DistanceKm != nil {
+		approximate := geo.ApproximateKm(*p.DistanceKm)
+		distance = &approximate
+	}
+	return PublicProfile{
+		ID:           p.ID,
+		UserID:       p.UserID,
+		Picture:      p.Picture,
+		Description:  p.Description,
+		Age:          p.AgeAt(time.Now()),
+		DistanceKm:   distance,
+		Gender:       p.Gender,
+		InterestedIn: nonNil(p.InterestedIn),
+		City:         p.City,
+		HeightCm:     p.HeightCm,
+		Job:          p.Job,
+		Interests:    nonNil(p.Interests),
+		Superliked:   p.Superliked,
+		Photos:       nonNilPhotos(p.Photos),
+	}
+}
+
+// MarshalJSON adds the derived age and writes the birthdate as a plain date.
+func (p Profile) MarshalJSON() ([]byte, error) {
+	type profile Profile
+	p.InterestedIn = nonNil(p.InterestedIn)
+	p.Interests = nonNil(p.Interests)
+	p.Photos = nonNilPhotos(p.Photos)
+	var birthdate *string
+	if p.Birthdate != nil {
+		formatted := p.Birthdate.Format(birthdateLayout)
+		birthdate = &formatted
+	}
+	return json.Marshal(struct {
+		profile
+		Birthdate *string `json:"birthdate,omitempty"`
+		Age       *int    `json:"age,omitempty"`
+	}{
+		profile:   profile(p),
+		Birthdate: birthdate,
+		Age:       p.AgeAt(time.Now()),
+	})
+}
+
+// nonNil makes empty tag lists encode as [] instead of null.
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+func nonNilPhotos(photos []ProfilePhoto) []ProfilePhoto {
+	if photos == nil {
+		return []ProfilePhoto{}
+	}
+	return photos
 }
 
 type ProfileViewLog struct {
-	ID        uint `json:"id"`
-	ViewerID  uint `json:"viewer_id"`
-	ProfileID uint `json:"profile_id"`
+	ID        uint      `json:"id"`
+	ViewerID  uint      `json:"viewer_id"`
+	ProfileID uint      `json:"profile_id"`
+	CreatedAt time.Time `json:"created_at"`
 }
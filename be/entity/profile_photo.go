@@ -0,0 +1,17 @@
+package entity
+
+import "time"
+
+// ProfilePhoto is a photo of the profile gallery, stored as a thumbnail and a full size variant.
+// Position orders the gallery from 0 and exactly one photo of a non-empty gallery is primary.
+type ProfilePhoto struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	ProfileID    uint      `json:"-"`
+	Position     int       `json:"position"`
+	IsPrimary    bool      `json:"is_primary"`
+	ThumbnailKey string    `json:"-"`
+	FullKey      string    `json:"-"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	FullURL      string    `json:"full_url"`
+	CreatedAt    time.Time `json:"created_at"`
+}
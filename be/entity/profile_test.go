@@ -0,0 +1,76 @@
+package entity
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileAgeAt(t *testing.T) {
+	birthdate := time.Date(2000, time.June, 15, 0, 0, 0, 0, time.UTC)
+	profile := Profile{Birthdate: &birthdate}
+
+	assert.Equal(t, 23, *profile.AgeAt(time.Date(2024, time.June, 14, 12, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 24, *profile.AgeAt(time.Date(2024, time.June, 15, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 24, *profile.AgeAt(time.Date(2024, time.December, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Nil(t, (&Profile{}).AgeAt(time.Now()))
+}
+
+func TestProfileMarshalJSON(t *testing.T) {
+	birthdate := time.Date(2000, time.June, 15, 0, 0, 0, 0, time.UTC)
+	latitude, longitude := -6.2, 106.8
+	profile := Profile{ID: 1, Birthdate: &birthdate, Latitude: &latitude, Longitude: &longitude}
+
+	data, err := json.Marshal(profile)
+	assert.NoError(t, err)
+
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &body))
+	assert.Equal(t, "2000-06-15", body["birthdate"])
+	assert.NotNil(t, body["age"])
+	assert.Equal(t, -6.2, body["latitude"])
+	assert.Equal(t, []interface{}{}, body["interests"])
+}
+
+func TestProfilePublic(t *testing.T) {
+	birthdate := time.Now().AddDate(-30, 0, -1)
+	latitude, longitude := -6.2, 106.8
+	profile := Profile{ID: 1, Birthdate: &birthdate, Latitude: &latitude, Longitude: &longitude, City: "Jakarta", Interests: []string{"hiking"}}
+
+	data, err := json.Marshal(profile.Public())
+	assert.NoError(t, err)
+
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &body))
+	assert.Equal(t, float64(30), body["age"])
+	assert.Equal(t, "Jakarta", body["city"])
+	assert.Equal(t, []interface{}{"hiking"}, body["interests"])
+	assert.NotContains(t, body, "birthdate")
+	assert.NotContains(t, body, "latitude")
+	assert.NotContains(t, body, "longitude")
+	assert.NotContains(t, body, "distance_km")
+}
+
+func TestProfileDistanceKmTo(t *testing.T) {
+	jakartaLat, jakartaLng := -6.2088, 106.8456
+	bandungLat, bandungLng := -6.9175, 107.6191
+	jakarta := Profile{Latitude: &jakartaLat, Longitude: &jakartaLng}
+	bandung := Profile{Latitude: &bandungLat, Longitude: &bandungLng}
+
+	distance := jakarta.DistanceKmTo(&bandung)
+	assert.InDelta(t, 116.0, *distance, 1)
+	assert.Nil(t, jakarta.DistanceKmTo(&Profile{}))
+
+	bandung.DistanceKm = distance
+	assert.Equal(t, 115, *bandung.Public().DistanceKm)
+}
+
+func TestDiscoveryPreferenceBirthdateRange(t *testing.T) {
+	preference := &DiscoveryPreference{MinAge: 25, MaxAge: 30}
+	earliest, latest := preference.BirthdateRange(time.Date(2024, time.June, 15, 10, 0, 0, 0, time.UTC))
+
+	assert.Equal(t, time.Date(1993, time.June, 15, 0, 0, 0, 0, time.UTC), earliest)
+	assert.Equal(t, time.Date(1999, time.June, 15, 0, 0, 0, 0, time.UTC), latest)
+}
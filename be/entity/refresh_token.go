@@ -0,0 +1,29 @@
+package entity
+
+import "time"
+
+// RefreshToken is a single-use token that can be exchanged for a new access token.
+// Tokens issued from the same login share a FamilyID so the whole chain can be
+// revoked when a rotated token is presented again.
+type RefreshToken struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id"`
+	FamilyID     string     `json:"family_id"`
+	TokenHash    string     `json:"-"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uint      `json:"replaced_by_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// PasswordResetToken is a single-use, time-limited token mailed to users who forgot their password.
+type PasswordResetToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
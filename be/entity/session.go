@@ -0,0 +1,21 @@
+package entity
+
+import "time"
+
+// Session is a login on one device. Its ID is shared by the refresh token family and
+// the sid claim of every access token issued for the login.
+type Session struct {
+	ID         string     `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"-"`
+	DeviceName string     `json:"device_name"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"-"`
+}
+
+func (s *Session) IsRevoked() bool {
+	return s.RevokedAt != nil
+}
@@ -0,0 +1,50 @@
+package entity
+
+import "time"
+
+// Swipe is a like, superlike or pass of a profile on a target profile. There is at most one swipe
+// per pair and direction; swiping a profile again after a pass updates it.
+type Swipe struct {
+	ID        int       `gorm:"primaryKey" json:"id"`
+	ProfileID int       `json:"profile_id"`
+	TargetID  int       `json:"target_id"`
+	Direction string    `json:"direction"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Profile Profile `gorm:"foreignKey:ProfileID;references:ID" json:"-"`
+}
+
+const (
+	SwipeLike      = "like"
+	SwipeSuperlike = "superlike"
+	SwipePass      = "pass"
+)
+
+// IsLike reports whether the swipe is a like or a superlike.
+func (s *Swipe) IsLike() bool {
+	return s.Direction == SwipeLike || s.Direction == SwipeSuperlike
+}
+
+// SuperlikeQuota is the number of superlikes a user can still send until the quota resets.
+type SuperlikeQuota struct {
+	Limit     int       `json:"limit"`
+	Used      int       `json:"used"`
+	Remaining int       `json:"remaining"`
+	ResetsAt  time.Time `json:"resets_at"`
+}
+
+// ReceivedLike is a like waiting for an answer of its recipient. The liker's profile is left out
+// for users without a subscription.
+type ReceivedLike struct {
+	Profile    *PublicProfile `json:"profile,omitempty"`
+	Superliked bool           `json:"superliked"`
+	LikedAt    time.Time      `json:"liked_at"`
+}
+
+// ReceivedLikes lists the likes received by a user. Blurred tells that the profiles are hidden.
+type ReceivedLikes struct {
+	Count   int            `json:"count"`
+	Blurred bool           `json:"blurred"`
+	Likes   []ReceivedLike `json:"likes"`
+}
@@ -5,17 +5,41 @@ import (
 )
 
 type User struct {
-	ID        uint      `json:"id" gorm:"primaryKey"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  string    `json:"-"` // omit password in response JSON
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                  uint       `json:"id" gorm:"primaryKey"`
+	Name                string     `json:"name"`
+	Email               string     `json:"email"`
+	Password            string     `json:"-"` // omit password in response JSON
+	VerifiedAt          *time.Time `json:"verified_at"`
+	TOTPSecret          string     `json:"-" gorm:"column:totp_secret"`
+	TOTPEnabledAt       *time.Time `json:"totp_enabled_at" gorm:"column:totp_enabled_at"`
+	TOTPLastStep        *int64     `json:"-" gorm:"column:totp_last_step"`
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
 
 	Profile      Profile      `json:"profile" gorm:"foreignKey:UserID"`
 	Subscription Subscription `json:"subscription" gorm:"foreignKey:UserID"`
 }
 
+func (u *User) IsVerified() bool {
+	return u.VerifiedAt != nil
+}
+
+// IsTOTPEnabled reports whether two-factor authentication is active. TOTPSecret is already
+// set while an enrollment is pending confirmation.
+func (u *User) IsTOTPEnabled() bool {
+	return u.TOTPEnabledAt != nil
+}
+
+// MFARecoveryCode is a hashed single-use code that can replace a TOTP code.
+type MFARecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id"`
+	CodeHash  string     `json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
 type Subscription struct {
 	ID         uint      `json:"id" gorm:"primaryKey"`
 	UserID     uint      `json:"user_id"`
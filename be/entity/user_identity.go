@@ -0,0 +1,24 @@
+package entity
+
+import "time"
+
+// UserIdentity links an external OpenID Connect account to a user.
+type UserIdentity struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OAuthState keeps the nonce and PKCE verifier of a pending authorization request until its callback.
+type OAuthState struct {
+	ID           uint      `json:"id" gorm:"primaryKey"`
+	StateHash    string    `json:"-"`
+	Provider     string    `json:"provider"`
+	Nonce        string    `json:"-"`
+	CodeVerifier string    `json:"-"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
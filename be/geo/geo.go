@@ -0,0 +1,29 @@
+package geo
+
+import "math"
+
+// EarthRadiusKm is the mean Earth radius.
+const EarthRadiusKm = 6371.0
+
+// HaversineKm returns the great circle distance in kilometres between two points given in degrees.
+func HaversineKm(lat1, lng1, lat2, lng2 float64) float64 {
+	phi1 := lat1 * math.Pi / 180
+	phi2 := lat2 * math.Pi / 180
+	dPhi := (lat2 - lat1) * math.Pi / 180
+	dLambda := (lng2 - lng1) * math.Pi / 180
+
+	a := math.Sin(dPhi/2)*math.Sin(dPhi/2) + math.Cos(phi1)*math.Cos(phi2)*math.Sin(dLambda/2)*math.Sin(dLambda/2)
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// ApproximateKm rounds a distance so it can be shown to other users without allowing their position
+// to be triangulated: never below 1 km, to the kilometre up to 10 km and to 5 km beyond.
+func ApproximateKm(km float64) int {
+	switch {
+	case km < 1:
+		return 1
+	case km < 10:
+		return int(math.Round(km))
+	}
+	return int(math.Round(km/5)) * 5
+}
@@ -0,0 +1,26 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHaversineKm(t *testing.T) {
+	// Jakarta to Bandung
+	assert.InDelta(t, 116.0, HaversineKm(-6.2088, 106.8456, -6.9175, 107.6191), 1)
+	// London to Paris
+	assert.InDelta(t, 343.5, HaversineKm(51.5074, -0.1278, 48.8566, 2.3522), 1)
+	assert.Equal(t, 0.0, HaversineKm(-6.2, 106.8, -6.2, 106.8))
+	// antipodal points must not produce NaN
+	assert.InDelta(t, 20015.1, HaversineKm(0, 0, 0, 180), 1)
+}
+
+func TestApproximateKm(t *testing.T) {
+	assert.Equal(t, 1, ApproximateKm(0))
+	assert.Equal(t, 1, ApproximateKm(0.4))
+	assert.Equal(t, 4, ApproximateKm(3.6))
+	assert.Equal(t, 10, ApproximateKm(11.2))
+	assert.Equal(t, 15, ApproximateKm(13))
+	assert.Equal(t, 115, ApproximateKm(116))
+}
@@ -1,16 +1,27 @@
 package helpers
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"log"
 	"main/config"
 	"main/entity"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	PurposeEmailVerification = "email_verification"
+	PurposeMFAPending        = "mfa_pending"
+)
+
 func HashPassword(password string) (*string, error) {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
@@ -26,10 +37,9 @@ func ComparePassword(hashedPassword string, password string) bool {
 	return err == nil
 }
 
-func GenerateAccessToken(user *entity.User, cfg *config.JWT) (*string, error) {
-	secretKey := []byte(cfg.Secret)
-
+func GenerateAccessToken(user *entity.User, sessionID string, cfg *config.JWT, keys *KeySet) (*string, error) {
 	claims := &jwt.MapClaims{
+		"sid":        sessionID,
 		"user_id":    user.ID,
 		"name":       user.Name,
 		"email":      user.Email,
@@ -38,8 +48,7 @@ func GenerateAccessToken(user *entity.User, cfg *config.JWT) (*string, error) {
 		"exp":        time.Now().Add(time.Second * time.Duration(cfg.Expiry)).Unix(),
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(secretKey)
+	tokenString, err := keys.Sign(claims)
 	if err != nil {
 		log.Printf("Failed to generate token: %v", err)
 		return nil, err
@@ -48,12 +57,8 @@ func GenerateAccessToken(user *entity.User, cfg *config.JWT) (*string, error) {
 	return &tokenString, nil
 }
 
-func ValidateToken(jwtString string, secret string) (*jwt.Token, error) {
-	secretKey := []byte(secret)
-
-	token, err := jwt.Parse(jwtString, func(token *jwt.Token) (interface{}, error) {
-		return secretKey, nil
-	})
+func ValidateToken(jwtString string, keys *KeySet) (*jwt.Token, error) {
+	token, err := keys.Parse(jwtString)
 	if err != nil {
 		log.Printf("Failed to validate token: %v", err)
 		return nil, err
@@ -62,6 +67,72 @@ func ValidateToken(jwtString string, secret string) (*jwt.Token, error) {
 	return token, nil
 }
 
+// GenerateEmailVerificationToken signs a token proving ownership of the user's current email address.
+func GenerateEmailVerificationToken(user *entity.User, cfg *config.JWT, keys *KeySet) (*string, error) {
+	return generatePurposeToken(user, PurposeEmailVerification, cfg.VerificationExpiry, keys)
+}
+
+// ValidateEmailVerificationToken returns the user ID and email address the verification token was issued for.
+func ValidateEmailVerificationToken(tokenString string, keys *KeySet) (int, string, error) {
+	return validatePurposeToken(tokenString, PurposeEmailVerification, keys)
+}
+
+// GenerateMFAToken signs the short-lived token handed out after a correct password when the
+// user still has to provide a second factor. It cannot be used as an access token.
+func GenerateMFAToken(user *entity.User, cfg *config.JWT, keys *KeySet) (*string, error) {
+	return generatePurposeToken(user, PurposeMFAPending, cfg.MFAExpiry, keys)
+}
+
+// ValidateMFAToken returns the ID of the user the MFA pending token was issued for.
+func ValidateMFAToken(tokenString string, keys *KeySet) (int, error) {
+	userID, _, err := validatePurposeToken(tokenString, PurposeMFAPending, keys)
+	return userID, err
+}
+
+func generatePurposeToken(user *entity.User, purpose string, expiry int, keys *KeySet) (*string, error) {
+	claims := &jwt.MapClaims{
+		"sub":     strconv.Itoa(int(user.ID)),
+		"email":   user.Email,
+		"purpose": purpose,
+		"iss":     "dating-app",
+		"exp":     time.Now().Add(time.Second * time.Duration(expiry)).Unix(),
+	}
+
+	tokenString, err := keys.Sign(claims)
+	if err != nil {
+		log.Printf("Failed to generate %s token: %v", purpose, err)
+		return nil, err
+	}
+
+	return &tokenString, nil
+}
+
+func validatePurposeToken(tokenString string, purpose string, keys *KeySet) (int, string, error) {
+	token, err := ValidateToken(tokenString, keys)
+	if err != nil {
+		return 0, "", err
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if claimed, _ := claims["purpose"].(string); claimed != purpose {
+		return 0, "", errors.New("invalid token purpose")
+	}
+	subject, err := claims.GetSubject()
+	if err != nil {
+		return 0, "", err
+	}
+	userID, err := strconv.Atoi(subject)
+	if err != nil {
+		return 0, "", err
+	}
+	email, _ := claims["email"].(string)
+	return userID, email, nil
+}
+
+// NormalizeEmail trims and lowercases an email address so lookups are case-insensitive.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
 func ConvertStringToInt(str string) int {
 	num, err := strconv.Atoi(str)
 	if err != nil {
@@ -70,3 +141,19 @@ func ConvertStringToInt(str string) int {
 	}
 	return num
 }
+
+// GenerateRandomToken returns a URL-safe random string built from n random bytes.
+func GenerateRandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Failed to generate random token: %v", err)
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex encoded SHA-256 digest of an opaque token so it can be stored and looked up safely.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
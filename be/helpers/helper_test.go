@@ -5,6 +5,7 @@ import (
 	"main/entity"
 	"testing"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -44,7 +45,10 @@ func TestGenerateAccessToken(t *testing.T) {
 		Expiry: 3600,
 	}
 
-	token, err := GenerateAccessToken(user, cfg)
+	keys, err := NewKeySet(cfg)
+	assert.NoError(t, err)
+
+	token, err := GenerateAccessToken(user, "session", cfg, keys)
 	assert.NoError(t, err)
 	assert.NotNil(t, token)
 }
@@ -63,17 +67,49 @@ func TestValidateToken(t *testing.T) {
 		Expiry: 3600,
 	}
 
-	tokenString, _ := GenerateAccessToken(user, cfg)
-	token, err := ValidateToken(*tokenString, cfg.Secret)
+	keys, _ := NewKeySet(cfg)
+	tokenString, _ := GenerateAccessToken(user, "session", cfg, keys)
+	token, err := ValidateToken(*tokenString, keys)
 	assert.NoError(t, err)
 	assert.NotNil(t, token)
+	assert.Equal(t, "session", token.Claims.(jwt.MapClaims)["sid"])
 
 	invalidToken := "invalidtoken"
-	token, err = ValidateToken(invalidToken, cfg.Secret)
+	token, err = ValidateToken(invalidToken, keys)
 	assert.Error(t, err)
 	assert.Nil(t, token)
 }
 
+func TestEmailVerificationToken(t *testing.T) {
+	user := &entity.User{
+		ID:    7,
+		Email: "test@example.com",
+	}
+	cfg := &config.JWT{
+		Secret:             "testsecret",
+		Expiry:             3600,
+		VerificationExpiry: 3600,
+	}
+	keys, _ := NewKeySet(cfg)
+
+	tokenString, err := GenerateEmailVerificationToken(user, cfg, keys)
+	assert.NoError(t, err)
+
+	userID, email, err := ValidateEmailVerificationToken(*tokenString, keys)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, userID)
+	assert.Equal(t, "test@example.com", email)
+
+	accessToken, _ := GenerateAccessToken(user, "session", cfg, keys)
+	_, _, err = ValidateEmailVerificationToken(*accessToken, keys)
+	assert.Error(t, err)
+
+	cfg.VerificationExpiry = -60
+	expiredToken, _ := GenerateEmailVerificationToken(user, cfg, keys)
+	_, _, err = ValidateEmailVerificationToken(*expiredToken, keys)
+	assert.Error(t, err)
+}
+
 func TestConvertStringToInt(t *testing.T) {
 	str := "123"
 	num := ConvertStringToInt(str)
@@ -83,3 +119,20 @@ func TestConvertStringToInt(t *testing.T) {
 	num = ConvertStringToInt(str)
 	assert.Equal(t, 0, num)
 }
+
+func TestGenerateRandomToken(t *testing.T) {
+	token, err := GenerateRandomToken(32)
+	assert.NoError(t, err)
+	assert.Len(t, token, 43)
+
+	other, err := GenerateRandomToken(32)
+	assert.NoError(t, err)
+	assert.NotEqual(t, token, other)
+}
+
+func TestHashToken(t *testing.T) {
+	hash := HashToken("token")
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, HashToken("token"))
+	assert.NotEqual(t, hash, HashToken("other-token"))
+}
@@ -0,0 +1,63 @@
+package helpers
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+)
+
+const (
+	// maxImagePixels rejects decompression bombs before the pixels are decoded.
+	maxImagePixels = 40_000_000
+	jpegQuality    = 85
+)
+
+var ErrUnsupportedImage = errors.New("unsupported image")
+
+// DecodeImage decodes a JPEG or PNG image after checking its dimensions from the header.
+func DecodeImage(data []byte) (image.Image, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || (format != "jpeg" && format != "png") {
+		return nil, ErrUnsupportedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxImagePixels {
+		return nil, ErrUnsupportedImage
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	return img, nil
+}
+
+// ResizeToJPEG scales the image down to fit in a maxSize square, keeping its aspect ratio, and encodes
+// it as JPEG on a white background. Smaller images are not enlarged. Re-encoding also drops any
+// metadata such as the EXIF location of the original file.
+func ResizeToJPEG(img image.Image, maxSize int) ([]byte, error) {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxSize || height > maxSize {
+		if width >= height {
+			height = max(1, height*maxSize/width)
+			width = maxSize
+		} else {
+			width = max(1, width*maxSize/height)
+			height = maxSize
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), &image.Uniform{C: color.White}, image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
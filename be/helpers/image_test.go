@@ -0,0 +1,64 @@
+package helpers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	img.Set(0, 0, color.NRGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestDecodeImage(t *testing.T) {
+	img, err := DecodeImage(encodePNG(t, 20, 10))
+	assert.NoError(t, err)
+	assert.Equal(t, 20, img.Bounds().Dx())
+
+	_, err = DecodeImage([]byte("GIF89a not really"))
+	assert.ErrorIs(t, err, ErrUnsupportedImage)
+}
+
+func TestDecodeImageRejectsHugeDimensions(t *testing.T) {
+	// the header claims 10000x10000 pixels; the decoder must not allocate them
+	data := encodePNG(t, 1, 1)
+	data[16], data[17], data[18], data[19] = 0, 0, 0x27, 0x10
+	data[20], data[21], data[22], data[23] = 0, 0, 0x27, 0x10
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, 10000, cfg.Width)
+
+	_, err = DecodeImage(data)
+	assert.ErrorIs(t, err, ErrUnsupportedImage)
+}
+
+func TestResizeToJPEG(t *testing.T) {
+	img, err := DecodeImage(encodePNG(t, 400, 200))
+	assert.NoError(t, err)
+
+	data, err := ResizeToJPEG(img, 100)
+	assert.NoError(t, err)
+	resized, err := jpeg.DecodeConfig(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, 100, resized.Width)
+	assert.Equal(t, 50, resized.Height)
+
+	// smaller images keep their size
+	data, err = ResizeToJPEG(img, 1000)
+	assert.NoError(t, err)
+	resized, err = jpeg.DecodeConfig(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, 400, resized.Width)
+}
@@ -0,0 +1,258 @@
+package helpers
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"main/config"
+	"math/big"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+// KeySet holds the key used to sign access tokens and every key accepted when verifying them.
+// Asymmetric verification keys are identified by their RFC 7638 thumbprint, which is sent as the kid header.
+type KeySet struct {
+	algorithm        string
+	signingKID       string
+	signingKey       interface{}
+	verificationKeys map[string]interface{}
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewKeySet builds a KeySet from the JWT config.
+// HS256 uses the shared secret, RS256 and EdDSA load the signing key and the additional
+// verification keys from PEM files so retired keys keep validating until their tokens expire.
+func NewKeySet(cfg *config.JWT) (*KeySet, error) {
+	algorithm := cfg.Algorithm
+	if algorithm == "" {
+		algorithm = AlgorithmHS256
+	}
+
+	keys := &KeySet{
+		algorithm:        algorithm,
+		verificationKeys: map[string]interface{}{},
+	}
+
+	if algorithm == AlgorithmHS256 {
+		if cfg.Secret == "" {
+			return nil, errors.New("JWT secret is required for HS256")
+		}
+		keys.signingKey = []byte(cfg.Secret)
+		return keys, nil
+	}
+
+	if algorithm != AlgorithmRS256 && algorithm != AlgorithmEdDSA {
+		return nil, fmt.Errorf("unsupported JWT algorithm %q", algorithm)
+	}
+
+	privateKey, err := loadPrivateKey(cfg.PrivateKeyFile)
+	if err != nil {
+		log.Printf("Failed to load JWT private key: %v", err)
+		return nil, err
+	}
+	publicKey := privateKey.Public()
+	if err := keys.addVerificationKey(publicKey); err != nil {
+		return nil, err
+	}
+	keys.signingKey = privateKey
+	keys.signingKID, _ = thumbprint(publicKey)
+
+	for _, file := range cfg.PublicKeyFiles {
+		publicKey, err := loadPublicKey(file)
+		if err != nil {
+			log.Printf("Failed to load JWT public key %s: %v", file, err)
+			return nil, err
+		}
+		if err := keys.addVerificationKey(publicKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+func (k *KeySet) Algorithm() string {
+	return k.algorithm
+}
+
+// Sign signs the claims with the current signing key and sets the kid header for asymmetric keys.
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(k.algorithm), claims)
+	if k.signingKID != "" {
+		token.Header["kid"] = k.signingKID
+	}
+	return token.SignedString(k.signingKey)
+}
+
+// Parse verifies the token signature against the key set, rejecting any other algorithm.
+func (k *KeySet) Parse(tokenString string) (*jwt.Token, error) {
+	return jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if k.algorithm == AlgorithmHS256 {
+			return k.signingKey, nil
+		}
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, errors.New("missing kid header")
+		}
+		key, ok := k.verificationKeys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+		return key, nil
+	}, jwt.WithValidMethods([]string{k.algorithm}), jwt.WithExpirationRequired())
+}
+
+// JWKS returns the public verification keys. Shared secrets are never published.
+func (k *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for kid, key := range k.verificationKeys {
+		jwk, err := publicJWK(key)
+		if err != nil {
+			continue
+		}
+		jwk.Kid = kid
+		jwk.Use = "sig"
+		jwk.Alg = k.algorithm
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+	return jwks
+}
+
+func (k *KeySet) addVerificationKey(key crypto.PublicKey) error {
+	switch key.(type) {
+	case *rsa.PublicKey:
+		if k.algorithm != AlgorithmRS256 {
+			return fmt.Errorf("RSA key cannot be used with %s", k.algorithm)
+		}
+	case ed25519.PublicKey:
+		if k.algorithm != AlgorithmEdDSA {
+			return fmt.Errorf("Ed25519 key cannot be used with %s", k.algorithm)
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", key)
+	}
+	kid, err := thumbprint(key)
+	if err != nil {
+		return err
+	}
+	k.verificationKeys[kid] = key
+	return nil
+}
+
+func loadPrivateKey(file string) (crypto.Signer, error) {
+	block, err := readPEM(file)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+func loadPublicKey(file string) (crypto.PublicKey, error) {
+	block, err := readPEM(file)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+func readPEM(file string) (*pem.Block, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %s", file)
+	}
+	return block, nil
+}
+
+func publicJWK(key crypto.PublicKey) (JWK, error) {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(key),
+		}, nil
+	}
+	return JWK{}, fmt.Errorf("unsupported public key type %T", key)
+}
+
+// thumbprint computes the RFC 7638 JWK thumbprint of a public key.
+func thumbprint(key crypto.PublicKey) (string, error) {
+	jwk, err := publicJWK(key)
+	if err != nil {
+		return "", err
+	}
+	var members interface{}
+	if jwk.Kty == "RSA" {
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	} else {
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+}
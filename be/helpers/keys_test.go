@@ -0,0 +1,128 @@
+package helpers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"main/config"
+	"main/entity"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeKeyPair(t *testing.T, private interface{}, public interface{}) (string, string) {
+	dir := t.TempDir()
+	privateBytes, err := x509.MarshalPKCS8PrivateKey(private)
+	assert.NoError(t, err)
+	publicBytes, err := x509.MarshalPKIXPublicKey(public)
+	assert.NoError(t, err)
+
+	privateFile := filepath.Join(dir, "private.pem")
+	publicFile := filepath.Join(dir, "public.pem")
+	assert.NoError(t, os.WriteFile(privateFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateBytes}), 0600))
+	assert.NoError(t, os.WriteFile(publicFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicBytes}), 0600))
+	return privateFile, publicFile
+}
+
+func newRSAKeyPair(t *testing.T) (string, string) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	return writeKeyPair(t, key, &key.PublicKey)
+}
+
+func newEd25519KeyPair(t *testing.T) (string, string) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	return writeKeyPair(t, private, public)
+}
+
+func TestKeySetAsymmetric(t *testing.T) {
+	user := &entity.User{ID: 1, Profile: entity.Profile{ID: 1}}
+
+	for algorithm, newKeyPair := range map[string]func(*testing.T) (string, string){
+		AlgorithmRS256: newRSAKeyPair,
+		AlgorithmEdDSA: newEd25519KeyPair,
+	} {
+		t.Run(algorithm, func(t *testing.T) {
+			privateFile, _ := newKeyPair(t)
+			cfg := &config.JWT{Algorithm: algorithm, PrivateKeyFile: privateFile, Expiry: 3600}
+			keys, err := NewKeySet(cfg)
+			assert.NoError(t, err)
+
+			tokenString, err := GenerateAccessToken(user, "session", cfg, keys)
+			assert.NoError(t, err)
+
+			token, err := ValidateToken(*tokenString, keys)
+			assert.NoError(t, err)
+			assert.Equal(t, algorithm, token.Method.Alg())
+			assert.NotEmpty(t, token.Header["kid"])
+
+			jwks := keys.JWKS()
+			assert.Len(t, jwks.Keys, 1)
+			assert.Equal(t, token.Header["kid"], jwks.Keys[0].Kid)
+			assert.Equal(t, algorithm, jwks.Keys[0].Alg)
+		})
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	user := &entity.User{ID: 1, Profile: entity.Profile{ID: 1}}
+	oldPrivateFile, oldPublicFile := newRSAKeyPair(t)
+	newPrivateFile, _ := newRSAKeyPair(t)
+
+	oldCfg := &config.JWT{Algorithm: AlgorithmRS256, PrivateKeyFile: oldPrivateFile, Expiry: 3600}
+	oldKeys, err := NewKeySet(oldCfg)
+	assert.NoError(t, err)
+	oldToken, err := GenerateAccessToken(user, "session", oldCfg, oldKeys)
+	assert.NoError(t, err)
+
+	rotatedCfg := &config.JWT{Algorithm: AlgorithmRS256, PrivateKeyFile: newPrivateFile, PublicKeyFiles: []string{oldPublicFile}, Expiry: 3600}
+	rotatedKeys, err := NewKeySet(rotatedCfg)
+	assert.NoError(t, err)
+	assert.Len(t, rotatedKeys.JWKS().Keys, 2)
+
+	_, err = ValidateToken(*oldToken, rotatedKeys)
+	assert.NoError(t, err)
+
+	newToken, err := GenerateAccessToken(user, "session", rotatedCfg, rotatedKeys)
+	assert.NoError(t, err)
+	_, err = ValidateToken(*newToken, oldKeys)
+	assert.Error(t, err)
+}
+
+func TestKeySetRejectsOtherAlgorithms(t *testing.T) {
+	user := &entity.User{ID: 1, Profile: entity.Profile{ID: 1}}
+	privateFile, _ := newRSAKeyPair(t)
+	rsaCfg := &config.JWT{Algorithm: AlgorithmRS256, PrivateKeyFile: privateFile, Expiry: 3600}
+	rsaKeys, err := NewKeySet(rsaCfg)
+	assert.NoError(t, err)
+
+	hmacCfg := &config.JWT{Secret: "secret", Expiry: 3600}
+	hmacKeys, err := NewKeySet(hmacCfg)
+	assert.NoError(t, err)
+	hmacToken, err := GenerateAccessToken(user, "session", hmacCfg, hmacKeys)
+	assert.NoError(t, err)
+
+	_, err = ValidateToken(*hmacToken, rsaKeys)
+	assert.Error(t, err)
+}
+
+func TestNewKeySetInvalidConfig(t *testing.T) {
+	_, err := NewKeySet(&config.JWT{})
+	assert.Error(t, err)
+
+	_, err = NewKeySet(&config.JWT{Algorithm: "none"})
+	assert.Error(t, err)
+
+	_, err = NewKeySet(&config.JWT{Algorithm: AlgorithmRS256, PrivateKeyFile: "missing.pem"})
+	assert.Error(t, err)
+
+	privateFile, _ := newEd25519KeyPair(t)
+	_, err = NewKeySet(&config.JWT{Algorithm: AlgorithmRS256, PrivateKeyFile: privateFile})
+	assert.Error(t, err)
+}
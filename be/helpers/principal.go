@@ -0,0 +1,25 @@
+package helpers
+
+import "github.com/labstack/echo/v4"
+
+const principalKey = "principal"
+
+// Principal is the authenticated caller of a request. The user and profile IDs are distinct
+// identifiers and must not be used interchangeably.
+type Principal struct {
+	UserID    int
+	ProfileID int
+	SessionID string
+	Name      string
+	Email     string
+}
+
+func SetPrincipal(c echo.Context, principal *Principal) {
+	c.Set(principalKey, principal)
+}
+
+// CurrentPrincipal returns the caller set by the auth middleware, or nil on a public route.
+func CurrentPrincipal(c echo.Context) *Principal {
+	principal, _ := c.Get(principalKey).(*Principal)
+	return principal
+}
@@ -1,6 +1,11 @@
 package helpers
 
-import "github.com/labstack/echo/v4"
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
 
 func ResponseWithError(ctx echo.Context, code int, message string) {
 	err := ctx.JSON(code, map[string]string{"error": message})
@@ -16,3 +21,16 @@ func ResponseWithSuccess(ctx echo.Context, code int, data interface{}) {
 	}
 
 }
+
+// ResponseWithValidationError writes the per-field errors of a failed validation,
+// falling back to a generic bad request for any other error.
+func ResponseWithValidationError(ctx echo.Context, err error) {
+	var fieldErrors ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		ResponseWithError(ctx, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	if err := ctx.JSON(http.StatusBadRequest, map[string]interface{}{"error": "Validation failed", "fields": fieldErrors}); err != nil {
+		ctx.Logger().Error(err)
+	}
+}
@@ -0,0 +1,65 @@
+package helpers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// secret "12345678901234567890" from the RFC 6238 test vectors
+const rfcTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateTOTPCode(t *testing.T) {
+	vectors := map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	}
+	for unix, expected := range vectors {
+		code, err := GenerateTOTPCode(rfcTOTPSecret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, code)
+	}
+}
+
+func TestValidateTOTPCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	step, ok := ValidateTOTPCode(rfcTOTPSecret, "050471", now)
+	assert.True(t, ok)
+	assert.Equal(t, int64(1111111111/30), step)
+
+	_, ok = ValidateTOTPCode(rfcTOTPSecret, "050471", now.Add(30*time.Second))
+	assert.True(t, ok)
+
+	_, ok = ValidateTOTPCode(rfcTOTPSecret, "050471", now.Add(2*time.Minute))
+	assert.False(t, ok)
+
+	_, ok = ValidateTOTPCode(rfcTOTPSecret, "000000", now)
+	assert.False(t, ok)
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	assert.NoError(t, err)
+	assert.Len(t, secret, 32)
+
+	uri := TOTPURI(secret, "john@example.com", "dating-app")
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/dating-app:john@example.com?"))
+	assert.Contains(t, uri, "secret="+secret)
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 10)
+	for _, code := range codes {
+		assert.Regexp(t, `^[A-Z2-7]{5}-[A-Z2-7]{5}$`, code)
+		assert.Len(t, NormalizeRecoveryCode(" "+strings.ToLower(code)+" "), 10)
+	}
+}
@@ -0,0 +1,149 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"main/config"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// bcrypt ignores everything after the first 72 bytes of a password
+const maxPasswordLength = 72
+
+// Validator implements echo.Validator and reports errors keyed by the JSON field name.
+type Validator struct {
+	validate *validator.Validate
+	policy   config.PasswordPolicy
+}
+
+// ValidationErrors maps a JSON field name to a human readable error message.
+type ValidationErrors map[string]string
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, 0, len(v))
+	for field, message := range v {
+		messages = append(messages, field+" "+message)
+	}
+	return strings.Join(messages, ", ")
+}
+
+func NewValidator(policy config.PasswordPolicy) *Validator {
+	v := &Validator{
+		validate: validator.New(validator.WithRequiredStructEnabled()),
+		policy:   policy,
+	}
+	v.validate.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" || name == "" {
+			return field.Name
+		}
+		return name
+	})
+	_ = v.validate.RegisterValidation("password", func(fl validator.FieldLevel) bool {
+		return v.CheckPassword(fl.Field().String()) == ""
+	})
+	return v
+}
+
+func (v *Validator) Validate(i interface{}) error {
+	err := v.validate.Struct(i)
+	if err == nil {
+		return nil
+	}
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		return err
+	}
+	result := ValidationErrors{}
+	for _, fieldError := range fieldErrors {
+		result[fieldError.Field()] = v.message(fieldError)
+	}
+	return result
+}
+
+// CheckPassword returns a description of the first password policy rule the password breaks, or an empty string.
+func (v *Validator) CheckPassword(password string) string {
+	if len(password) < v.policy.MinLength {
+		return fmt.Sprintf("must be at least %d characters", v.policy.MinLength)
+	}
+	if len(password) > maxPasswordLength {
+		return fmt.Sprintf("must be at most %d characters", maxPasswordLength)
+	}
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+	switch {
+	case v.policy.RequireUpper && !hasUpper:
+		return "must contain an uppercase letter"
+	case v.policy.RequireLower && !hasLower:
+		return "must contain a lowercase letter"
+	case v.policy.RequireDigit && !hasDigit:
+		return "must contain a digit"
+	case v.policy.RequireSymbol && !hasSymbol:
+		return "must contain a symbol"
+	}
+	return ""
+}
+
+func (v *Validator) message(fieldError validator.FieldError) string {
+	switch fieldError.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "min", "max":
+		bound := "at least"
+		if fieldError.Tag() == "max" {
+			bound = "at most"
+		}
+		switch fieldError.Kind() {
+		case reflect.Int, reflect.Int64, reflect.Float64:
+			return fmt.Sprintf("must be %s %s", bound, fieldError.Param())
+		case reflect.Slice:
+			return fmt.Sprintf("must have %s %s items", bound, fieldError.Param())
+		}
+		return fmt.Sprintf("must be %s %s characters", bound, fieldError.Param())
+	case "oneof":
+		return "must be one of " + strings.ReplaceAll(fieldError.Param(), " ", ", ")
+	case "datetime":
+		return "must be a date formatted as " + fieldError.Param()
+	case "latitude", "longitude":
+		return "must be a valid " + fieldError.Tag()
+	case "required_with":
+		return "is required with " + snakeCase(fieldError.Param())
+	case "gtefield":
+		return "must not be less than " + snakeCase(fieldError.Param())
+	case "password":
+		return v.CheckPassword(fieldError.Value().(string))
+	}
+	return "is invalid"
+}
+
+// snakeCase turns a struct field name such as MinAge into its JSON name min_age.
+func snakeCase(name string) string {
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,61 @@
+package helpers
+
+import (
+	"main/config"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type validatorTestRequest struct {
+	Email    string `json:"email" validate:"required,email"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+func TestValidatorValidate(t *testing.T) {
+	v := NewValidator(config.PasswordPolicy{MinLength: 8})
+
+	err := v.Validate(&validatorTestRequest{Email: "john@example.com", Password: "password"})
+	assert.NoError(t, err)
+
+	err = v.Validate(&validatorTestRequest{Email: "john", Password: ""})
+	assert.Equal(t, ValidationErrors{
+		"email":    "must be a valid email address",
+		"password": "is required",
+	}, err)
+}
+
+type validatorTestProfile struct {
+	HeightCm  int      `json:"height_cm" validate:"min=100"`
+	Gender    string   `json:"gender" validate:"oneof=male female"`
+	Interests []string `json:"interests" validate:"max=1"`
+}
+
+func TestValidatorMessages(t *testing.T) {
+	v := NewValidator(config.PasswordPolicy{MinLength: 8})
+
+	err := v.Validate(&validatorTestProfile{HeightCm: 20, Gender: "robot", Interests: []string{"a", "b"}})
+	assert.Equal(t, ValidationErrors{
+		"height_cm": "must be at least 100",
+		"gender":    "must be one of male, female",
+		"interests": "must have at most 1 items",
+	}, err)
+}
+
+func TestValidatorCheckPassword(t *testing.T) {
+	v := NewValidator(config.PasswordPolicy{
+		MinLength:     10,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	})
+
+	assert.Equal(t, "must be at least 10 characters", v.CheckPassword("Short1!"))
+	assert.Equal(t, "must contain an uppercase letter", v.CheckPassword("password1!"))
+	assert.Equal(t, "must contain a lowercase letter", v.CheckPassword("PASSWORD1!"))
+	assert.Equal(t, "must contain a digit", v.CheckPassword("Passwordd!"))
+	assert.Equal(t, "must contain a symbol", v.CheckPassword("Password12"))
+	assert.Equal(t, "must be at most 72 characters", v.CheckPassword("Password1!"+string(make([]byte, 70))))
+	assert.Equal(t, "", v.CheckPassword("Password1!"))
+}
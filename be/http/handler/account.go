@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"main/helpers"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// DeleteAccount schedules the authenticated user's account for erasure after the grace period and
+// signs every device out. Logging in again before the deletion date cancels it.
+func (h *AuthHandler) DeleteAccount(echoCtx echo.Context) error {
+	ctx := echoCtx.Request().Context()
+	userId := helpers.CurrentPrincipal(echoCtx).UserID
+	user, err := h.userRepo.FindByID(ctx, userId)
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if user.DeletionScheduledAt != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusConflict, "Account deletion already scheduled")
+		return nil
+	}
+
+	scheduledAt := time.Now().Add(time.Second * time.Duration(h.cfg.Deletion.GraceSeconds))
+	if err := h.userRepo.ScheduleDeletion(ctx, userId, scheduledAt); err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if err := h.tokenRepo.RevokeAllForUser(ctx, userId); err != nil {
+		log.Printf("Failed to revoke tokens: %v", err)
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nYour account and all of its data will be permanently deleted on %s.\n\nIf you change your mind, log in before then to cancel the deletion.\n", user.Name, scheduledAt.UTC().Format(time.RFC1123))
+	if err := h.mailer.Send(user.Email, "Your account is scheduled for deletion", body); err != nil {
+		log.Printf("Failed to send account deletion email: %v", err)
+	}
+
+	helpers.ResponseWithSuccess(echoCtx, http.StatusAccepted, map[string]interface{}{
+		"message":               "Account scheduled for deletion",
+		"deletion_scheduled_at": scheduledAt,
+	})
+	return nil
+}
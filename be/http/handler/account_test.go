@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"main/config"
+	"main/entity"
+	"main/helpers"
+	"main/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	mockMailer := new(MockMailer)
+	cfg := &config.Config{Deletion: config.AccountDeletion{GraceSeconds: 86400}}
+	handler := NewAuthHandler(mockUserRepo, mockTokenRepo, new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), mockMailer, cfg)
+
+	req := httptest.NewRequest(http.MethodDelete, "/me", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	authenticate(c, 1, 2)
+
+	mockUserRepo.On("FindByID", 1).Return(&entity.User{ID: 1, Name: "John", Email: "john@example.com"}, nil)
+	mockUserRepo.On("ScheduleDeletion", 1, mock.MatchedBy(func(at time.Time) bool {
+		return at.After(time.Now().Add(23*time.Hour)) && at.Before(time.Now().Add(25*time.Hour))
+	})).Return(nil)
+	mockTokenRepo.On("RevokeAllForUser", 1).Return(nil)
+	mockMailer.On("Send", "john@example.com", "Your account is scheduled for deletion", mock.Anything).Return(nil)
+
+	if assert.NoError(t, handler.DeleteAccount(c)) {
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Contains(t, rec.Body.String(), "deletion_scheduled_at")
+	}
+	mockUserRepo.AssertExpectations(t)
+	mockTokenRepo.AssertExpectations(t)
+	mockMailer.AssertExpectations(t)
+}
+
+func TestDeleteAccountAlreadyScheduled(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	handler := NewAuthHandler(mockUserRepo, new(MockTokenRepository), new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), new(MockMailer), &config.Config{})
+
+	req := httptest.NewRequest(http.MethodDelete, "/me", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	authenticate(c, 1, 2)
+
+	scheduledAt := time.Now().Add(time.Hour)
+	mockUserRepo.On("FindByID", 1).Return(&entity.User{ID: 1, DeletionScheduledAt: &scheduledAt}, nil)
+
+	if assert.NoError(t, handler.DeleteAccount(c)) {
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	}
+	mockUserRepo.AssertNotCalled(t, "ScheduleDeletion", mock.Anything, mock.Anything)
+}
+
+func TestLoginCancelsAccountDeletion(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	mockSessionRepo := new(MockSessionRepository)
+	cfg := &config.Config{JWT: config.JWT{Secret: "secret"}}
+	handler := NewAuthHandler(mockUserRepo, mockTokenRepo, mockSessionRepo, repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), new(MockMailer), cfg)
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"john@example.com","password":"password"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	password, _ := helpers.HashPassword("password")
+	scheduledAt := time.Now().Add(time.Hour)
+	mockUserRepo.On("FindByEmail", "john@example.com").Return(&entity.User{ID: 1, Email: "john@example.com", Password: *password, DeletionScheduledAt: &scheduledAt}, nil)
+	mockUserRepo.On("CancelDeletion", 1).Return(nil)
+	mockSessionRepo.On("Create", mock.AnythingOfType("*entity.Session")).Return(nil)
+	mockTokenRepo.On("Create", mock.AnythingOfType("*entity.RefreshToken")).Return(nil)
+
+	if assert.NoError(t, handler.Login(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "access_token")
+	}
+	mockUserRepo.AssertExpectations(t)
+}
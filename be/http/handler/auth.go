@@ -1,20 +1,29 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"main/config"
 	"main/entity"
 	"main/helpers"
+	"main/mailer"
 	"main/repository"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
 type RegisterRequest struct {
-	Name     string `json:"name"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Name     string `json:"name" validate:"required,max=255"`
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,password"`
 }
 
 type RegisterResponse struct {
@@ -24,66 +33,361 @@ type RegisterResponse struct {
 }
 
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email      string `json:"email" validate:"required"`
+	Password   string `json:"password" validate:"required"`
+	DeviceName string `json:"device_name" validate:"max=255"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required,password"`
 }
 
 type AuthHandler struct {
-	userRepo repository.UserRepositoryInterface
-	cfg      *config.Config
+	userRepo    repository.UserRepositoryInterface
+	tokenRepo   repository.TokenRepositoryInterface
+	sessionRepo repository.SessionRepositoryInterface
+	attemptRepo repository.LoginAttemptRepositoryInterface
+	keys        *helpers.KeySet
+	mailer      mailer.Mailer
+	cfg         *config.Config
 }
 
-func NewAuthHandler(userRepo repository.UserRepositoryInterface, cfg *config.Config) *AuthHandler {
+func NewAuthHandler(userRepo repository.UserRepositoryInterface, tokenRepo repository.TokenRepositoryInterface, sessionRepo repository.SessionRepositoryInterface, attemptRepo repository.LoginAttemptRepositoryInterface, keys *helpers.KeySet, mailer mailer.Mailer, cfg *config.Config) *AuthHandler {
 	return &AuthHandler{
-		userRepo: userRepo,
-		cfg:      cfg,
+		userRepo:    userRepo,
+		tokenRepo:   tokenRepo,
+		sessionRepo: sessionRepo,
+		attemptRepo: attemptRepo,
+		keys:        keys,
+		mailer:      mailer,
+		cfg:         cfg,
 	}
 }
 
 func (h *AuthHandler) Login(echoCtx echo.Context) error {
+	ctx := echoCtx.Request().Context()
 	var req LoginRequest
 	if err := echoCtx.Bind(&req); err != nil {
 		return echoCtx.JSON(http.StatusBadRequest, err)
 	}
-	user, err := h.userRepo.FindByEmail(req.Email)
+	if err := echoCtx.Validate(&req); err != nil {
+		helpers.ResponseWithValidationError(echoCtx, err)
+		return nil
+	}
+
+	accountKey := "account:" + helpers.NormalizeEmail(req.Email)
+	ipKey := "ip:" + echoCtx.RealIP()
+	retryAfter, err := h.lockedFor(ctx, accountKey, ipKey)
 	if err != nil {
-		helpers.ResponseWithError(echoCtx, http.StatusUnauthorized, "Invalid credentials")
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if retryAfter > 0 {
+		respondLocked(echoCtx, retryAfter)
 		return nil
 	}
 
-	correctPassword := helpers.ComparePassword(user.Password, req.Password)
-	if !correctPassword {
+	user, err := h.userRepo.FindByEmail(ctx, req.Email)
+	if err != nil || !helpers.ComparePassword(user.Password, req.Password) {
+		h.recordFailure(ctx, accountKey, h.cfg.LoginThrottle.AccountMaxAttempts, echoCtx.RealIP())
+		h.recordFailure(ctx, ipKey, h.cfg.LoginThrottle.IPMaxAttempts, echoCtx.RealIP())
 		helpers.ResponseWithError(echoCtx, http.StatusUnauthorized, "Invalid credentials")
 		return nil
 	}
+	if err := h.attemptRepo.Reset(ctx, accountKey); err != nil {
+		log.Printf("Failed to reset login attempts: %v", err)
+	}
+
+	h.completeLogin(echoCtx, user, req.DeviceName)
+	return nil
+}
+
+// completeLogin writes the token pair for an authenticated user, or an MFA pending token
+// when the user has two-factor authentication enabled.
+func (h *AuthHandler) completeLogin(echoCtx echo.Context, user *entity.User, deviceName string) {
+	if user.IsTOTPEnabled() {
+		mfaToken, err := helpers.GenerateMFAToken(user, &h.cfg.JWT, h.keys)
+		if err != nil {
+			helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		helpers.ResponseWithSuccess(echoCtx, http.StatusOK, map[string]interface{}{"mfa_required": true, "mfa_token": *mfaToken})
+		return
+	}
+
+	h.respondWithNewTokens(echoCtx, user, deviceName)
+}
+
+// respondWithNewTokens starts a new session for the user and writes the token pair.
+// The session ID is also the refresh token family. A pending account deletion is cancelled.
+func (h *AuthHandler) respondWithNewTokens(echoCtx echo.Context, user *entity.User, deviceName string) {
+	if user.DeletionScheduledAt != nil {
+		if err := h.userRepo.CancelDeletion(echoCtx.Request().Context(), int(user.ID)); err != nil {
+			log.Printf("Failed to cancel account deletion: %v", err)
+			helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+			return
+		}
+		user.DeletionScheduledAt = nil
+	}
+	sessionID, err := helpers.GenerateRandomToken(16)
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	session := &entity.Session{
+		ID:         sessionID,
+		UserID:     user.ID,
+		DeviceName: deviceName,
+		UserAgent:  echoCtx.Request().UserAgent(),
+		IP:         echoCtx.RealIP(),
+		LastSeenAt: time.Now(),
+	}
+	if err := h.sessionRepo.Create(echoCtx.Request().Context(), session); err != nil {
+		log.Printf("Failed to create session: %v", err)
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+	tokens, err := h.issueTokens(echoCtx.Request().Context(), user, sessionID, nil)
+	if err != nil {
+		log.Printf("Failed to issue tokens: %v", err)
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	helpers.ResponseWithSuccess(echoCtx, http.StatusOK, tokens)
+}
+
+// lockedFor returns how long the most restrictive of the given keys is still locked out.
+func (h *AuthHandler) lockedFor(ctx context.Context, keys ...string) (time.Duration, error) {
+	now := time.Now()
+	var retryAfter time.Duration
+	for _, key := range keys {
+		attempt, err := h.attemptRepo.Find(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		if attempt != nil && attempt.IsLocked(now) && attempt.LockedUntil.Sub(now) > retryAfter {
+			retryAfter = attempt.LockedUntil.Sub(now)
+		}
+	}
+	return retryAfter, nil
+}
 
-	accessToken, err := helpers.GenerateAccessToken(user, &h.cfg.JWT)
+func respondLocked(echoCtx echo.Context, retryAfter time.Duration) {
+	echoCtx.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	helpers.ResponseWithError(echoCtx, http.StatusTooManyRequests, "Too many failed login attempts")
+}
 
+// recordFailure counts a failed login for key and locks it once maxAttempts is reached.
+// Every failure past the limit doubles the lockout, capped at the configured maximum.
+func (h *AuthHandler) recordFailure(ctx context.Context, key string, maxAttempts int, ip string) {
+	throttle := h.cfg.LoginThrottle
+	attempt, err := h.attemptRepo.RecordFailure(ctx, key, time.Second*time.Duration(throttle.WindowSeconds))
 	if err != nil {
-		log.Printf("Failed to generate access token: %v", err)
-		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Invalid credentials")
+		log.Printf("Failed to record login attempt: %v", err)
+		return
+	}
+	if maxAttempts <= 0 || attempt.Failures < maxAttempts {
+		return
+	}
+
+	lockout := time.Second * time.Duration(throttle.LockoutSeconds)
+	maxLockout := time.Second * time.Duration(throttle.MaxLockoutSeconds)
+	for i := maxAttempts; i < attempt.Failures && lockout < maxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > maxLockout {
+		lockout = maxLockout
+	}
+
+	lockedUntil := time.Now().Add(lockout)
+	if err := h.attemptRepo.Lock(ctx, key, lockedUntil, &entity.LoginLockout{
+		Key:         key,
+		IP:          ip,
+		Failures:    attempt.Failures,
+		LockedUntil: lockedUntil,
+	}); err != nil {
+		log.Printf("Failed to lock login: %v", err)
+		return
+	}
+	log.Printf("Login locked for %s until %s after %d failed attempts", key, lockedUntil.Format(time.RFC3339), attempt.Failures)
+}
+
+// RefreshToken exchanges a valid refresh token for a new access and refresh token pair.
+// Presenting a token that was already rotated revokes every token in its family.
+func (h *AuthHandler) RefreshToken(echoCtx echo.Context) error {
+	ctx := echoCtx.Request().Context()
+	var req RefreshTokenRequest
+	if err := echoCtx.Bind(&req); err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusBadRequest, "Invalid request")
+		return nil
+	}
+	if err := echoCtx.Validate(&req); err != nil {
+		helpers.ResponseWithValidationError(echoCtx, err)
 		return nil
 	}
 
-	helpers.ResponseWithSuccess(echoCtx, http.StatusOK, map[string]string{"access_token": *accessToken})
+	token, err := h.tokenRepo.FindByHash(ctx, helpers.HashToken(req.RefreshToken))
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if token == nil {
+		helpers.ResponseWithError(echoCtx, http.StatusUnauthorized, "Invalid refresh token")
+		return nil
+	}
+	if token.RevokedAt != nil {
+		if token.ReplacedByID != nil {
+			h.revokeFamily(ctx, token.FamilyID)
+		}
+		helpers.ResponseWithError(echoCtx, http.StatusUnauthorized, "Invalid refresh token")
+		return nil
+	}
+	if time.Now().After(token.ExpiresAt) {
+		helpers.ResponseWithError(echoCtx, http.StatusUnauthorized, "Refresh token expired")
+		return nil
+	}
+
+	user, err := h.userRepo.FindByID(ctx, int(token.UserID))
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusUnauthorized, "Invalid refresh token")
+		return nil
+	}
+
+	tokens, err := h.issueTokens(ctx, user, token.FamilyID, token)
+	if err != nil {
+		if errors.Is(err, repository.ErrTokenAlreadyRotated) {
+			h.revokeFamily(ctx, token.FamilyID)
+			helpers.ResponseWithError(echoCtx, http.StatusUnauthorized, "Invalid refresh token")
+			return nil
+		}
+		log.Printf("Failed to rotate refresh token: %v", err)
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+
+	helpers.ResponseWithSuccess(echoCtx, http.StatusOK, tokens)
 	return nil
 }
 
+// Logout revokes the refresh token family the given refresh token belongs to.
+func (h *AuthHandler) Logout(echoCtx echo.Context) error {
+	var req RefreshTokenRequest
+	if err := echoCtx.Bind(&req); err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusBadRequest, "Invalid request")
+		return nil
+	}
+	if err := echoCtx.Validate(&req); err != nil {
+		helpers.ResponseWithValidationError(echoCtx, err)
+		return nil
+	}
+
+	token, err := h.tokenRepo.FindByHash(echoCtx.Request().Context(), helpers.HashToken(req.RefreshToken))
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if token == nil {
+		helpers.ResponseWithError(echoCtx, http.StatusUnauthorized, "Invalid refresh token")
+		return nil
+	}
+
+	if err := h.tokenRepo.RevokeFamily(echoCtx.Request().Context(), token.FamilyID); err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+
+	helpers.ResponseWithSuccess(echoCtx, http.StatusOK, map[string]interface{}{"message": "Logged out"})
+	return nil
+}
+
+// JWKS publishes the public keys other services can use to verify access tokens.
+func (h *AuthHandler) JWKS(echoCtx echo.Context) error {
+	return echoCtx.JSON(http.StatusOK, h.keys.JWKS())
+}
+
+// issueTokens generates an access token and a refresh token for the session with the given family ID.
+// When previous is set it is rotated out in favour of the new refresh token.
+func (h *AuthHandler) issueTokens(ctx context.Context, user *entity.User, familyID string, previous *entity.RefreshToken) (map[string]string, error) {
+	accessToken, err := helpers.GenerateAccessToken(user, familyID, &h.cfg.JWT, h.keys)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := helpers.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	token := &entity.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: helpers.HashToken(refreshToken),
+		ExpiresAt: time.Now().Add(time.Second * time.Duration(h.cfg.JWT.RefreshExpiry)),
+	}
+	if previous != nil {
+		err = h.tokenRepo.Rotate(ctx, previous, token)
+	} else {
+		err = h.tokenRepo.Create(ctx, token)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]string{
+		"access_token":  *accessToken,
+		"refresh_token": refreshToken,
+	}, nil
+}
+
+func (h *AuthHandler) revokeFamily(ctx context.Context, familyID string) {
+	if err := h.tokenRepo.RevokeFamily(ctx, familyID); err != nil {
+		log.Printf("Failed to revoke refresh token family: %v", err)
+	}
+}
+
 func (h *AuthHandler) Register(echoCtx echo.Context) error {
 	var req RegisterRequest
 	if err := echoCtx.Bind(&req); err != nil {
 		return echoCtx.JSON(http.StatusBadRequest, err)
 	}
+	req.Name = strings.TrimSpace(req.Name)
+	req.Email = helpers.NormalizeEmail(req.Email)
+	if err := echoCtx.Validate(&req); err != nil {
+		helpers.ResponseWithValidationError(echoCtx, err)
+		return nil
+	}
 	user := entity.User{
 		Name:     req.Name,
 		Email:    req.Email,
 		Password: req.Password,
 	}
-	createdUser, err := h.userRepo.Save(&user)
+	createdUser, err := h.userRepo.Save(echoCtx.Request().Context(), &user)
 	if err != nil {
+		if errors.Is(err, repository.ErrDuplicateEmail) {
+			helpers.ResponseWithError(echoCtx, http.StatusConflict, "Email already registered")
+			return nil
+		}
 		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
 		return nil
 	}
+	if err := h.sendVerificationEmail(createdUser); err != nil {
+		log.Printf("Failed to send verification email: %v", err)
+	}
+
 	responseData := RegisterResponse{
 		ID:    createdUser.ID,
 		Name:  createdUser.Name,
@@ -92,3 +396,176 @@ func (h *AuthHandler) Register(echoCtx echo.Context) error {
 	helpers.ResponseWithSuccess(echoCtx, http.StatusCreated, responseData)
 	return nil
 }
+
+// VerifyEmail marks the user's email as verified using the token from the verification link.
+func (h *AuthHandler) VerifyEmail(echoCtx echo.Context) error {
+	userID, email, err := helpers.ValidateEmailVerificationToken(echoCtx.QueryParam("token"), h.keys)
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusBadRequest, "Invalid or expired verification link")
+		return nil
+	}
+
+	user, err := h.userRepo.FindByID(echoCtx.Request().Context(), userID)
+	if err != nil || user.Email != email {
+		helpers.ResponseWithError(echoCtx, http.StatusBadRequest, "Invalid or expired verification link")
+		return nil
+	}
+
+	if !user.IsVerified() {
+		if err := h.userRepo.Verify(echoCtx.Request().Context(), userID); err != nil {
+			helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+			return nil
+		}
+	}
+
+	helpers.ResponseWithSuccess(echoCtx, http.StatusOK, map[string]interface{}{"message": "Email verified"})
+	return nil
+}
+
+// ResendVerification sends a new verification link to the authenticated user.
+func (h *AuthHandler) ResendVerification(echoCtx echo.Context) error {
+	user, err := h.userRepo.FindByID(echoCtx.Request().Context(), helpers.CurrentPrincipal(echoCtx).UserID)
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if user.IsVerified() {
+		helpers.ResponseWithError(echoCtx, http.StatusBadRequest, "Email already verified")
+		return nil
+	}
+
+	if err := h.sendVerificationEmail(user); err != nil {
+		log.Printf("Failed to send verification email: %v", err)
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+
+	helpers.ResponseWithSuccess(echoCtx, http.StatusOK, map[string]interface{}{"message": "Verification email sent"})
+	return nil
+}
+
+// ForgotPassword mails a single-use reset token. The response is the same whether or not the email
+// is registered so it cannot be used to discover accounts.
+func (h *AuthHandler) ForgotPassword(echoCtx echo.Context) error {
+	var req ForgotPasswordRequest
+	if err := echoCtx.Bind(&req); err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusBadRequest, "Invalid request")
+		return nil
+	}
+	if err := echoCtx.Validate(&req); err != nil {
+		helpers.ResponseWithValidationError(echoCtx, err)
+		return nil
+	}
+
+	user, err := h.userRepo.FindByEmail(echoCtx.Request().Context(), req.Email)
+	if err == nil {
+		if err := h.sendPasswordResetEmail(echoCtx.Request().Context(), user); err != nil {
+			log.Printf("Failed to send password reset email: %v", err)
+		}
+	}
+
+	helpers.ResponseWithSuccess(echoCtx, http.StatusOK, map[string]interface{}{"message": "If the email is registered, a password reset link has been sent"})
+	return nil
+}
+
+// ResetPassword consumes a reset token, sets the new password and revokes every other token of the user.
+func (h *AuthHandler) ResetPassword(echoCtx echo.Context) error {
+	var req ResetPasswordRequest
+	if err := echoCtx.Bind(&req); err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusBadRequest, "Invalid request")
+		return nil
+	}
+	if err := echoCtx.Validate(&req); err != nil {
+		helpers.ResponseWithValidationError(echoCtx, err)
+		return nil
+	}
+
+	token, err := h.tokenRepo.ConsumePasswordReset(echoCtx.Request().Context(), helpers.HashToken(req.Token))
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if token == nil {
+		helpers.ResponseWithError(echoCtx, http.StatusBadRequest, "Invalid or expired reset token")
+		return nil
+	}
+
+	if err := h.changePassword(echoCtx.Request().Context(), int(token.UserID), req.Password); err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+
+	helpers.ResponseWithSuccess(echoCtx, http.StatusOK, map[string]interface{}{"message": "Password updated"})
+	return nil
+}
+
+// ChangePassword updates the authenticated user's password after checking the current one.
+func (h *AuthHandler) ChangePassword(echoCtx echo.Context) error {
+	var req ChangePasswordRequest
+	if err := echoCtx.Bind(&req); err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusBadRequest, "Invalid request")
+		return nil
+	}
+	if err := echoCtx.Validate(&req); err != nil {
+		helpers.ResponseWithValidationError(echoCtx, err)
+		return nil
+	}
+
+	userId := helpers.CurrentPrincipal(echoCtx).UserID
+	user, err := h.userRepo.FindByID(echoCtx.Request().Context(), userId)
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if !helpers.ComparePassword(user.Password, req.CurrentPassword) {
+		helpers.ResponseWithError(echoCtx, http.StatusUnauthorized, "Invalid credentials")
+		return nil
+	}
+
+	if err := h.changePassword(echoCtx.Request().Context(), userId, req.NewPassword); err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+
+	helpers.ResponseWithSuccess(echoCtx, http.StatusOK, map[string]interface{}{"message": "Password updated"})
+	return nil
+}
+
+func (h *AuthHandler) changePassword(ctx context.Context, userId int, password string) error {
+	if err := h.userRepo.UpdatePassword(ctx, userId, password); err != nil {
+		log.Printf("Failed to update password: %v", err)
+		return err
+	}
+	if err := h.tokenRepo.RevokeAllForUser(ctx, userId); err != nil {
+		log.Printf("Failed to revoke tokens: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (h *AuthHandler) sendPasswordResetEmail(ctx context.Context, user *entity.User) error {
+	token, err := helpers.GenerateRandomToken(32)
+	if err != nil {
+		return err
+	}
+	if err := h.tokenRepo.CreatePasswordReset(ctx, &entity.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: helpers.HashToken(token),
+		ExpiresAt: time.Now().Add(time.Second * time.Duration(h.cfg.JWT.ResetExpiry)),
+	}); err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s?token=%s", h.cfg.PasswordResetURL, url.QueryEscape(token))
+	body := fmt.Sprintf("Hi %s,\n\nUse the link below to reset your password. It expires in %d minutes and can only be used once:\n\n%s\n\nIf you did not request a password reset, you can ignore this email.\n", user.Name, h.cfg.JWT.ResetExpiry/60, link)
+	return h.mailer.Send(user.Email, "Reset your password", body)
+}
+
+func (h *AuthHandler) sendVerificationEmail(user *entity.User) error {
+	token, err := helpers.GenerateEmailVerificationToken(user, &h.cfg.JWT, h.keys)
+	if err != nil {
+		return err
+	}
+	link := fmt.Sprintf("%s/verify-email?token=%s", h.cfg.URL, url.QueryEscape(*token))
+	body := fmt.Sprintf("Hi %s,\n\nPlease verify your email address by opening the link below:\n\n%s\n", user.Name, link)
+	return h.mailer.Send(user.Email, "Verify your email address", body)
+}
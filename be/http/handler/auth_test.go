@@ -1,24 +1,96 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"main/config"
 	"main/entity"
 	"main/helpers"
+	"main/repository"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
-func TestRegister(t *testing.T) {
+type MockTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockTokenRepository) Create(_ context.Context, token *entity.RefreshToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockTokenRepository) FindByHash(_ context.Context, hash string) (*entity.RefreshToken, error) {
+	args := m.Called(hash)
+	return args.Get(0).(*entity.RefreshToken), args.Error(1)
+}
+
+func (m *MockTokenRepository) Rotate(_ context.Context, oldToken *entity.RefreshToken, newToken *entity.RefreshToken) error {
+	args := m.Called(oldToken, newToken)
+	return args.Error(0)
+}
+
+func (m *MockTokenRepository) RevokeFamily(_ context.Context, familyID string) error {
+	args := m.Called(familyID)
+	return args.Error(0)
+}
+
+func (m *MockTokenRepository) RevokeAllForUser(_ context.Context, userID int) error {
+	args := m.Called(userID)
+	return args.Error(0)
+}
+
+func (m *MockTokenRepository) CreatePasswordReset(_ context.Context, token *entity.PasswordResetToken) error {
+	args := m.Called(token)
+	return args.Error(0)
+}
+
+func (m *MockTokenRepository) ConsumePasswordReset(_ context.Context, hash string) (*entity.PasswordResetToken, error) {
+	args := m.Called(hash)
+	return args.Get(0).(*entity.PasswordResetToken), args.Error(1)
+}
+
+type MockMailer struct {
+	mock.Mock
+}
+
+func (m *MockMailer) Send(to, subject, body string) error {
+	args := m.Called(to, subject, body)
+	return args.Error(0)
+}
+
+func newTestEcho() *echo.Echo {
 	e := echo.New()
+	e.Validator = helpers.NewValidator(config.PasswordPolicy{MinLength: 8})
+	return e
+}
+
+// authenticate sets the principal like the auth middleware does. Tests pass different user and
+// profile IDs so a handler using one in place of the other is caught.
+func authenticate(c echo.Context, userID, profileID int) {
+	helpers.SetPrincipal(c, &helpers.Principal{UserID: userID, ProfileID: profileID, SessionID: "current"})
+}
+
+func newTestKeySet(t *testing.T) *helpers.KeySet {
+	keys, err := helpers.NewKeySet(&config.JWT{Secret: "secret"})
+	assert.NoError(t, err)
+	return keys
+}
+
+func TestRegister(t *testing.T) {
+	e := newTestEcho()
 	mockUserRepo := new(MockUserRepository)
-	cfg := &config.Config{}
-	handler := NewAuthHandler(mockUserRepo, cfg)
+	mockTokenRepo := new(MockTokenRepository)
+	mockMailer := new(MockMailer)
+	cfg := &config.Config{URL: "http://localhost:7000"}
+	handler := NewAuthHandler(mockUserRepo, mockTokenRepo, new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), mockMailer, cfg)
 
 	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{"name":"John Doe","email":"john@example.com","password":"password"}`))
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
@@ -31,24 +103,78 @@ func TestRegister(t *testing.T) {
 		Password: "password",
 	}
 	mockUserRepo.On("Save", user).Return(user, nil)
+	mockMailer.On("Send", "john@example.com", mock.Anything, mock.MatchedBy(func(body string) bool {
+		return strings.Contains(body, "http://localhost:7000/verify-email?token=")
+	})).Return(nil)
 
 	if assert.NoError(t, handler.Register(c)) {
 		assert.Equal(t, http.StatusCreated, rec.Code)
 		assert.Contains(t, rec.Body.String(), "John Doe")
 	}
+	mockMailer.AssertExpectations(t)
+}
+
+func TestRegisterValidationError(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	handler := NewAuthHandler(mockUserRepo, new(MockTokenRepository), new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), new(MockMailer), &config.Config{})
+
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{"name":"","email":"not-an-email","password":"short"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, handler.Register(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.JSONEq(t, `{
+			"error": "Validation failed",
+			"fields": {
+				"name": "is required",
+				"email": "must be a valid email address",
+				"password": "must be at least 8 characters"
+			}
+		}`, rec.Body.String())
+	}
+	mockUserRepo.AssertNotCalled(t, "Save", mock.Anything)
+}
+
+func TestRegisterDuplicateEmail(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	handler := NewAuthHandler(mockUserRepo, new(MockTokenRepository), new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), new(MockMailer), &config.Config{})
+
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{"name":"John Doe","email":" John@Example.com ","password":"password"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	user := &entity.User{
+		Name:     "John Doe",
+		Email:    "john@example.com",
+		Password: "password",
+	}
+	mockUserRepo.On("Save", user).Return(&entity.User{}, repository.ErrDuplicateEmail)
+
+	if assert.NoError(t, handler.Register(c)) {
+		assert.Equal(t, http.StatusConflict, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Email already registered")
+	}
+	mockUserRepo.AssertExpectations(t)
 }
 
 func TestLogin(t *testing.T) {
-	e := echo.New()
+	e := newTestEcho()
 	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	mockSessionRepo := new(MockSessionRepository)
 	cfg := &config.Config{
 		JWT: config.JWT{
 			Secret: "secret",
 		},
 	}
-	handler := NewAuthHandler(mockUserRepo, cfg)
+	handler := NewAuthHandler(mockUserRepo, mockTokenRepo, mockSessionRepo, repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), new(MockMailer), cfg)
 
-	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"john@example.com","password":"password"}`))
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"john@example.com","password":"password","device_name":"Phone"}`))
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
@@ -58,22 +184,31 @@ func TestLogin(t *testing.T) {
 		Password: *password,
 	}
 	mockUserRepo.On("FindByEmail", "john@example.com").Return(user, nil)
+	mockSessionRepo.On("Create", mock.MatchedBy(func(session *entity.Session) bool {
+		return session.ID != "" && session.DeviceName == "Phone"
+	})).Return(nil)
+	mockTokenRepo.On("Create", mock.AnythingOfType("*entity.RefreshToken")).Return(nil)
 
 	if assert.NoError(t, handler.Login(c)) {
 		assert.Equal(t, http.StatusOK, rec.Code)
 		assert.Contains(t, rec.Body.String(), "access_token")
+		assert.Contains(t, rec.Body.String(), "refresh_token")
 	}
+	session := mockSessionRepo.Calls[0].Arguments.Get(0).(*entity.Session)
+	refreshToken := mockTokenRepo.Calls[0].Arguments.Get(0).(*entity.RefreshToken)
+	assert.Equal(t, session.ID, refreshToken.FamilyID)
 }
 
 func TestLoginInvalidCredentials(t *testing.T) {
-	e := echo.New()
+	e := newTestEcho()
 	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
 	cfg := &config.Config{
 		JWT: config.JWT{
 			Secret: "secret",
 		},
 	}
-	handler := NewAuthHandler(mockUserRepo, cfg)
+	handler := NewAuthHandler(mockUserRepo, mockTokenRepo, new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), new(MockMailer), cfg)
 
 	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"john@example.com","password":"wrongpassword"}`))
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
@@ -94,10 +229,11 @@ func TestLoginInvalidCredentials(t *testing.T) {
 }
 
 func TestRegisterInternalServerError(t *testing.T) {
-	e := echo.New()
+	e := newTestEcho()
 	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
 	cfg := &config.Config{}
-	handler := NewAuthHandler(mockUserRepo, cfg)
+	handler := NewAuthHandler(mockUserRepo, mockTokenRepo, new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), new(MockMailer), cfg)
 
 	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{"name":"John Doe","email":"john@example.com","password":"password"}`))
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
@@ -115,3 +251,403 @@ func TestRegisterInternalServerError(t *testing.T) {
 		assert.Equal(t, http.StatusInternalServerError, rec.Code)
 	}
 }
+
+func TestRefreshToken(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	cfg := &config.Config{
+		JWT: config.JWT{
+			Secret:        "secret",
+			RefreshExpiry: 3600,
+		},
+	}
+	handler := NewAuthHandler(mockUserRepo, mockTokenRepo, new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), new(MockMailer), cfg)
+
+	req := httptest.NewRequest(http.MethodPost, "/token/refresh", strings.NewReader(`{"refresh_token":"refresh"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	token := &entity.RefreshToken{ID: 1, UserID: 1, FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour)}
+	mockTokenRepo.On("FindByHash", helpers.HashToken("refresh")).Return(token, nil)
+	mockUserRepo.On("FindByID", 1).Return(&entity.User{ID: 1}, nil)
+	mockTokenRepo.On("Rotate", token, mock.MatchedBy(func(newToken *entity.RefreshToken) bool {
+		return newToken.FamilyID == "family" && newToken.TokenHash != token.TokenHash
+	})).Return(nil)
+
+	if assert.NoError(t, handler.RefreshToken(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "access_token")
+		assert.Contains(t, rec.Body.String(), "refresh_token")
+	}
+	mockTokenRepo.AssertExpectations(t)
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	cfg := &config.Config{}
+	handler := NewAuthHandler(mockUserRepo, mockTokenRepo, new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), new(MockMailer), cfg)
+
+	req := httptest.NewRequest(http.MethodPost, "/token/refresh", strings.NewReader(`{"refresh_token":"refresh"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	revokedAt := time.Now().Add(-time.Minute)
+	replacedByID := uint(2)
+	token := &entity.RefreshToken{ID: 1, UserID: 1, FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt, ReplacedByID: &replacedByID}
+	mockTokenRepo.On("FindByHash", helpers.HashToken("refresh")).Return(token, nil)
+	mockTokenRepo.On("RevokeFamily", "family").Return(nil)
+
+	if assert.NoError(t, handler.RefreshToken(c)) {
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+	mockTokenRepo.AssertExpectations(t)
+	mockUserRepo.AssertNotCalled(t, "FindByID", mock.Anything)
+}
+
+func TestRefreshTokenExpired(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	cfg := &config.Config{}
+	handler := NewAuthHandler(mockUserRepo, mockTokenRepo, new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), new(MockMailer), cfg)
+
+	req := httptest.NewRequest(http.MethodPost, "/token/refresh", strings.NewReader(`{"refresh_token":"refresh"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	token := &entity.RefreshToken{ID: 1, UserID: 1, FamilyID: "family", ExpiresAt: time.Now().Add(-time.Hour)}
+	mockTokenRepo.On("FindByHash", helpers.HashToken("refresh")).Return(token, nil)
+
+	if assert.NoError(t, handler.RefreshToken(c)) {
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Refresh token expired")
+	}
+}
+
+func TestLogout(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	cfg := &config.Config{}
+	handler := NewAuthHandler(mockUserRepo, mockTokenRepo, new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), new(MockMailer), cfg)
+
+	req := httptest.NewRequest(http.MethodPost, "/logout", strings.NewReader(`{"refresh_token":"refresh"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	token := &entity.RefreshToken{ID: 1, UserID: 1, FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour)}
+	mockTokenRepo.On("FindByHash", helpers.HashToken("refresh")).Return(token, nil)
+	mockTokenRepo.On("RevokeFamily", "family").Return(nil)
+
+	if assert.NoError(t, handler.Logout(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Logged out")
+	}
+	mockTokenRepo.AssertExpectations(t)
+}
+
+func TestJWKS(t *testing.T) {
+	e := newTestEcho()
+	handler := NewAuthHandler(new(MockUserRepository), new(MockTokenRepository), new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), new(MockMailer), &config.Config{})
+
+	req := httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, handler.JWKS(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"keys":[]}`, rec.Body.String())
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	keys := newTestKeySet(t)
+	cfg := &config.Config{JWT: config.JWT{VerificationExpiry: 3600}}
+	handler := NewAuthHandler(mockUserRepo, new(MockTokenRepository), new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), keys, new(MockMailer), cfg)
+
+	user := &entity.User{ID: 1, Email: "john@example.com"}
+	token, _ := helpers.GenerateEmailVerificationToken(user, &cfg.JWT, keys)
+
+	req := httptest.NewRequest(http.MethodGet, "/verify-email?token="+*token, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockUserRepo.On("FindByID", 1).Return(user, nil)
+	mockUserRepo.On("Verify", 1).Return(nil)
+
+	if assert.NoError(t, handler.VerifyEmail(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Email verified")
+	}
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestVerifyEmailChangedEmail(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	keys := newTestKeySet(t)
+	cfg := &config.Config{JWT: config.JWT{VerificationExpiry: 3600}}
+	handler := NewAuthHandler(mockUserRepo, new(MockTokenRepository), new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), keys, new(MockMailer), cfg)
+
+	token, _ := helpers.GenerateEmailVerificationToken(&entity.User{ID: 1, Email: "old@example.com"}, &cfg.JWT, keys)
+
+	req := httptest.NewRequest(http.MethodGet, "/verify-email?token="+*token, nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockUserRepo.On("FindByID", 1).Return(&entity.User{ID: 1, Email: "john@example.com"}, nil)
+
+	if assert.NoError(t, handler.VerifyEmail(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+	mockUserRepo.AssertNotCalled(t, "Verify", 1)
+}
+
+func TestVerifyEmailInvalidToken(t *testing.T) {
+	e := newTestEcho()
+	handler := NewAuthHandler(new(MockUserRepository), new(MockTokenRepository), new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), new(MockMailer), &config.Config{})
+
+	req := httptest.NewRequest(http.MethodGet, "/verify-email?token=invalid", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, handler.VerifyEmail(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Invalid or expired verification link")
+	}
+}
+
+func TestForgotPassword(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	mockMailer := new(MockMailer)
+	cfg := &config.Config{URL: "http://localhost:7000", PasswordResetURL: "https://app.example.com/password/reset", JWT: config.JWT{ResetExpiry: 3600}}
+	handler := NewAuthHandler(mockUserRepo, mockTokenRepo, new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), mockMailer, cfg)
+
+	req := httptest.NewRequest(http.MethodPost, "/password/forgot", strings.NewReader(`{"email":"john@example.com"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockUserRepo.On("FindByEmail", "john@example.com").Return(&entity.User{ID: 1, Email: "john@example.com"}, nil)
+	mockTokenRepo.On("CreatePasswordReset", mock.MatchedBy(func(token *entity.PasswordResetToken) bool {
+		return token.UserID == 1 && token.TokenHash != "" && token.ExpiresAt.After(time.Now())
+	})).Return(nil)
+	mockMailer.On("Send", "john@example.com", mock.Anything, mock.MatchedBy(func(body string) bool {
+		return strings.Contains(body, "https://app.example.com/password/reset?token=")
+	})).Return(nil)
+
+	if assert.NoError(t, handler.ForgotPassword(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	mockTokenRepo.AssertExpectations(t)
+	mockMailer.AssertExpectations(t)
+}
+
+func TestForgotPasswordUnknownEmail(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockMailer := new(MockMailer)
+	handler := NewAuthHandler(mockUserRepo, new(MockTokenRepository), new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), mockMailer, &config.Config{})
+
+	req := httptest.NewRequest(http.MethodPost, "/password/forgot", strings.NewReader(`{"email":"unknown@example.com"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockUserRepo.On("FindByEmail", "unknown@example.com").Return(&entity.User{}, errors.New("record not found"))
+
+	if assert.NoError(t, handler.ForgotPassword(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	mockMailer.AssertNotCalled(t, "Send", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestResetPassword(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	handler := NewAuthHandler(mockUserRepo, mockTokenRepo, new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), new(MockMailer), &config.Config{})
+
+	req := httptest.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(`{"token":"reset","password":"newpassword"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockTokenRepo.On("ConsumePasswordReset", helpers.HashToken("reset")).Return(&entity.PasswordResetToken{ID: 1, UserID: 1}, nil)
+	mockUserRepo.On("UpdatePassword", 1, "newpassword").Return(nil)
+	mockTokenRepo.On("RevokeAllForUser", 1).Return(nil)
+
+	if assert.NoError(t, handler.ResetPassword(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "Password updated")
+	}
+	mockUserRepo.AssertExpectations(t)
+	mockTokenRepo.AssertExpectations(t)
+}
+
+func TestResetPasswordInvalidToken(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	handler := NewAuthHandler(mockUserRepo, mockTokenRepo, new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), new(MockMailer), &config.Config{})
+
+	req := httptest.NewRequest(http.MethodPost, "/password/reset", strings.NewReader(`{"token":"used","password":"newpassword"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockTokenRepo.On("ConsumePasswordReset", helpers.HashToken("used")).Return((*entity.PasswordResetToken)(nil), nil)
+
+	if assert.NoError(t, handler.ResetPassword(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+	mockUserRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything)
+}
+
+func TestChangePassword(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	handler := NewAuthHandler(mockUserRepo, mockTokenRepo, new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), new(MockMailer), &config.Config{})
+
+	req := httptest.NewRequest(http.MethodPut, "/me/password", strings.NewReader(`{"current_password":"password","new_password":"newpassword"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	authenticate(c, 1, 2)
+
+	password, _ := helpers.HashPassword("password")
+	mockUserRepo.On("FindByID", 1).Return(&entity.User{ID: 1, Password: *password}, nil)
+	mockUserRepo.On("UpdatePassword", 1, "newpassword").Return(nil)
+	mockTokenRepo.On("RevokeAllForUser", 1).Return(nil)
+
+	if assert.NoError(t, handler.ChangePassword(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	mockUserRepo.AssertExpectations(t)
+	mockTokenRepo.AssertExpectations(t)
+}
+
+func TestChangePasswordWrongCurrentPassword(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	handler := NewAuthHandler(mockUserRepo, new(MockTokenRepository), new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), newTestKeySet(t), new(MockMailer), &config.Config{})
+
+	req := httptest.NewRequest(http.MethodPut, "/me/password", strings.NewReader(`{"current_password":"wrongpassword","new_password":"newpassword"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	authenticate(c, 1, 2)
+
+	password, _ := helpers.HashPassword("password")
+	mockUserRepo.On("FindByID", 1).Return(&entity.User{ID: 1, Password: *password}, nil)
+
+	if assert.NoError(t, handler.ChangePassword(c)) {
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+	mockUserRepo.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything)
+}
+
+func TestLoginLockout(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	attemptRepo := repository.NewMemoryLoginAttemptRepository()
+	cfg := &config.Config{
+		JWT: config.JWT{Secret: "secret"},
+		LoginThrottle: config.LoginThrottle{
+			AccountMaxAttempts: 3,
+			IPMaxAttempts:      100,
+			WindowSeconds:      900,
+			LockoutSeconds:     60,
+			MaxLockoutSeconds:  3600,
+		},
+	}
+	handler := NewAuthHandler(mockUserRepo, mockTokenRepo, new(MockSessionRepository), attemptRepo, newTestKeySet(t), new(MockMailer), cfg)
+
+	password, _ := helpers.HashPassword("password")
+	user := &entity.User{Email: "john@example.com", Password: *password}
+	mockUserRepo.On("FindByEmail", "john@example.com").Return(user, nil)
+
+	login := func(password string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"john@example.com","password":"`+password+`"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		assert.NoError(t, handler.Login(e.NewContext(req, rec)))
+		return rec
+	}
+
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, login("wrongpassword").Code)
+	}
+
+	rec := login("password")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	if assert.Len(t, attemptRepo.Lockouts, 1) {
+		assert.Equal(t, "account:john@example.com", attemptRepo.Lockouts[0].Key)
+		assert.Equal(t, 3, attemptRepo.Lockouts[0].Failures)
+	}
+	mockTokenRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestLoginSuccessResetsAttempts(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	mockSessionRepo := new(MockSessionRepository)
+	attemptRepo := repository.NewMemoryLoginAttemptRepository()
+	cfg := &config.Config{
+		JWT:           config.JWT{Secret: "secret"},
+		LoginThrottle: config.LoginThrottle{AccountMaxAttempts: 3, WindowSeconds: 900, LockoutSeconds: 60, MaxLockoutSeconds: 3600},
+	}
+	handler := NewAuthHandler(mockUserRepo, mockTokenRepo, mockSessionRepo, attemptRepo, newTestKeySet(t), new(MockMailer), cfg)
+
+	_, _ = attemptRepo.RecordFailure(context.Background(), "account:john@example.com", time.Minute)
+	_, _ = attemptRepo.RecordFailure(context.Background(), "account:john@example.com", time.Minute)
+
+	password, _ := helpers.HashPassword("password")
+	mockUserRepo.On("FindByEmail", "john@example.com").Return(&entity.User{Email: "john@example.com", Password: *password}, nil)
+	mockSessionRepo.On("Create", mock.AnythingOfType("*entity.Session")).Return(nil)
+	mockTokenRepo.On("Create", mock.AnythingOfType("*entity.RefreshToken")).Return(nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"john@example.com","password":"password"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+
+	if assert.NoError(t, handler.Login(e.NewContext(req, rec))) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	attempt, _ := attemptRepo.Find(context.Background(), "account:john@example.com")
+	assert.Nil(t, attempt)
+}
+
+func TestLoginLockoutBackoff(t *testing.T) {
+	attemptRepo := repository.NewMemoryLoginAttemptRepository()
+	cfg := &config.Config{
+		LoginThrottle: config.LoginThrottle{WindowSeconds: 900, LockoutSeconds: 60, MaxLockoutSeconds: 200},
+	}
+	handler := NewAuthHandler(new(MockUserRepository), new(MockTokenRepository), new(MockSessionRepository), attemptRepo, newTestKeySet(t), new(MockMailer), cfg)
+
+	for i := 0; i < 4; i++ {
+		handler.recordFailure(context.Background(), "ip:192.0.2.1", 2, "192.0.2.1")
+	}
+
+	if assert.Len(t, attemptRepo.Lockouts, 3) {
+		expected := []time.Duration{60 * time.Second, 120 * time.Second, 200 * time.Second}
+		for i, lockout := range attemptRepo.Lockouts {
+			assert.WithinDuration(t, lockout.CreatedAt.Add(expected[i]), lockout.LockedUntil, time.Second)
+		}
+	}
+}
@@ -1,8 +1,13 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
+	"main/config"
 	"main/entity"
 	"main/helpers"
 	"main/repository"
@@ -10,25 +15,48 @@ import (
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	defaultMatchPageSize = 20
+	maxMatchPageSize     = 50
+)
+
 type DatingHandler struct {
 	profileRepository repository.ProfileRepositoryInterface
 	matchRepository   repository.MatchRepositoryInterface
+	cfg               *config.Discovery
 }
 
+// SwipeRequest is a swipe on a profile. Action is one of like, pass or superlike; without it the
+// boolean Swipe tells a like from a pass.
 type SwipeRequest struct {
-	ProfileID int  `json:"profile_id"`
-	Swipe     bool `json:"swipe"`
+	ProfileID int    `json:"profile_id"`
+	Swipe     bool   `json:"swipe"`
+	Action    string `json:"action" validate:"omitempty,oneof=like pass superlike"`
+}
+
+// direction returns the swipe direction of the request.
+func (r *SwipeRequest) direction() string {
+	if r.Action != "" {
+		return r.Action
+	}
+	if r.Swipe {
+		return entity.SwipeLike
+	}
+	return entity.SwipePass
 }
 
-func NewDatingHandler(profileRepository repository.ProfileRepositoryInterface, matchRepository repository.MatchRepositoryInterface) *DatingHandler {
+func NewDatingHandler(profileRepository repository.ProfileRepositoryInterface, matchRepository repository.MatchRepositoryInterface, cfg *config.Discovery) *DatingHandler {
 	return &DatingHandler{
 		profileRepository: profileRepository,
 		matchRepository:   matchRepository,
+		cfg:               cfg,
 	}
 }
 
 func (h *DatingHandler) Profile(c echo.Context) error {
-	checkDailyLimit, err := h.matchRepository.CheckDailyLimit(c)
+	ctx := c.Request().Context()
+	principal := helpers.CurrentPrincipal(c)
+	checkDailyLimit, err := h.matchRepository.CheckDailyLimit(ctx, principal.UserID, principal.ProfileID)
 	if err != nil {
 		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
 		return nil
@@ -37,118 +65,269 @@ func (h *DatingHandler) Profile(c echo.Context) error {
 		helpers.ResponseWithError(c, http.StatusForbidden, "Daily limit reached")
 		return nil
 	}
-	profile, err := h.profileRepository.GetRandomProfile(c)
+	profile, err := h.profileRepository.GetRandomProfile(ctx, principal.ProfileID, time.Duration(h.cfg.PassResurfaceSeconds)*time.Second)
+	if errors.Is(err, repository.ErrNoMoreProfiles) {
+		helpers.ResponseWithError(c, http.StatusNotFound, "No more profiles")
+		return nil
+	}
 	if err != nil {
 		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
 		return nil
 	}
 
-	err = h.profileRepository.SaveViewLog(c, int(profile.ID))
+	err = h.profileRepository.SaveViewLog(ctx, principal.ProfileID, int(profile.ID))
 	if err != nil {
 		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
 		return nil
 	}
-	helpers.ResponseWithSuccess(c, http.StatusOK, profile)
+	helpers.ResponseWithSuccess(c, http.StatusOK, profile.Public())
 	return nil
 }
 
+// SwipedProfile records a like, superlike or pass and responds with the next profile. A like on a
+// profile that already liked the user is a match. Superlikes are limited per day.
 func (h *DatingHandler) SwipedProfile(c echo.Context) error {
+	ctx := c.Request().Context()
 	var req SwipeRequest
 	if err := c.Bind(&req); err != nil {
 		helpers.ResponseWithError(c, http.StatusBadRequest, "Invalid request")
 		return nil
 	}
+	if err := c.Validate(&req); err != nil {
+		helpers.ResponseWithValidationError(c, err)
+		return nil
+	}
+	principal := helpers.CurrentPrincipal(c)
 	partnerId := req.ProfileID
-	profileId := c.Get("profile_id").(int)
-
-	_, err := h.profileRepository.FindByID(profileId)
-	if err != nil {
-		helpers.ResponseWithError(c, http.StatusNotFound, "Profile not found")
+	profileId := principal.ProfileID
+	if partnerId == profileId {
+		helpers.ResponseWithError(c, http.StatusBadRequest, "Cannot swipe your own profile")
 		return nil
 	}
 
-	// if user swipe left, reject the match for profile that swiped right
-	if !req.Swipe {
-		pendingMatch, err := h.matchRepository.CheckPendingMatch(partnerId, profileId)
+	direction := req.direction()
+	superlikeLimit := 0
+	if direction == entity.SwipeSuperlike {
+		quota, err := h.matchRepository.SuperlikeQuota(ctx, principal.UserID, profileId, h.cfg.SuperlikeDailyLimit, h.cfg.SuperlikePremiumDailyLimit)
 		if err != nil {
+			c.Logger().Error(err)
 			helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
 			return nil
 		}
-		if pendingMatch == nil {
-			err := h.Profile(c)
-			if err != nil {
-				c.Logger().Error(err)
-				helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
-			}
-			return nil
-		}
-		err = h.matchRepository.RejectMatch(profileId, partnerId)
-		if err != nil {
+		superlikeLimit = quota.Limit
+	}
+	if _, err := h.matchRepository.Swipe(ctx, profileId, partnerId, direction, superlikeLimit); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrProfileNotFound):
+			helpers.ResponseWithError(c, http.StatusNotFound, "Profile not found")
+		case errors.Is(err, repository.ErrAlreadySwiped):
+			helpers.ResponseWithError(c, http.StatusConflict, "Already swiped")
+		case errors.Is(err, repository.ErrAlreadyMatched):
+			helpers.ResponseWithError(c, http.StatusConflict, "Already matched")
+		case errors.Is(err, repository.ErrSuperlikeLimit):
+			helpers.ResponseWithError(c, http.StatusForbidden, "Daily superlike limit reached")
+		default:
 			c.Logger().Error(err)
 			helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
-			return nil
 		}
+		return nil
+	}
 
-		err = h.Profile(c)
-		if err != nil {
+	err := h.Profile(c)
+	if err != nil {
+		c.Logger().Error(err)
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
+	}
+	return nil
+}
+
+// UndoSwipe reverts the caller's most recent swipe if it was made within the undo window, so the
+// profile is served again. It is reserved to subscribers and a swipe that made a match is kept.
+func (h *DatingHandler) UndoSwipe(c echo.Context) error {
+	ctx := c.Request().Context()
+	principal := helpers.CurrentPrincipal(c)
+	isPremium, err := h.matchRepository.IsPremium(ctx, principal.UserID)
+	if err != nil {
+		c.Logger().Error(err)
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return nil
+	}
+	if !isPremium {
+		helpers.ResponseWithError(c, http.StatusForbidden, "Undo requires an active subscription")
+		return nil
+	}
+
+	since := time.Now().Add(-time.Duration(h.cfg.UndoWindowSeconds) * time.Second)
+	swipe, err := h.matchRepository.UndoSwipe(ctx, principal.ProfileID, since)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrNothingToUndo):
+			helpers.ResponseWithError(c, http.StatusNotFound, "No swipe to undo")
+		case errors.Is(err, repository.ErrAlreadyMatched):
+			helpers.ResponseWithError(c, http.StatusConflict, "Cannot undo a match")
+		default:
 			c.Logger().Error(err)
 			helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
-			return nil
 		}
 		return nil
 	}
+	helpers.ResponseWithSuccess(c, http.StatusOK, swipe)
+	return nil
+}
 
-	// check if user already swiped
-	userSwiped, err := h.matchRepository.CheckMatch(profileId, partnerId)
+// ReceivedLikes lists the likes waiting for an answer of the caller. Without a subscription only
+// their number and whether they are superlikes is shown.
+func (h *DatingHandler) ReceivedLikes(c echo.Context) error {
+	ctx := c.Request().Context()
+	principal := helpers.CurrentPrincipal(c)
+	isPremium, err := h.matchRepository.IsPremium(ctx, principal.UserID)
 	if err != nil {
 		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
 		return nil
 	}
-	if userSwiped != nil {
-		if userSwiped.Status == entity.StatusPending {
-			helpers.ResponseWithError(c, http.StatusConflict, "Already swiped")
-			return nil
-		}
-		helpers.ResponseWithError(c, http.StatusConflict, "Already matched")
+	likes, err := h.matchRepository.FindReceivedLikes(ctx, principal.ProfileID)
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
 		return nil
 	}
 
-	// check if user swiped right and the profile that swiped right also swiped right
-	partnerSwiped, err := h.matchRepository.CheckMatch(partnerId, profileId)
+	received := entity.ReceivedLikes{Count: len(likes), Blurred: !isPremium, Likes: make([]entity.ReceivedLike, 0, len(likes))}
+	for _, like := range likes {
+		receivedLike := entity.ReceivedLike{Superliked: like.Direction == entity.SwipeSuperlike, LikedAt: like.UpdatedAt}
+		if isPremium {
+			profile := like.Profile.Public()
+			receivedLike.Profile = &profile
+		}
+		received.Likes = append(received.Likes, receivedLike)
+	}
+	helpers.ResponseWithSuccess(c, http.StatusOK, received)
+	return nil
+}
+
+type AnswerLikeRequest struct {
+	Action string `json:"action" validate:"required,oneof=like pass"`
+}
+
+// AnswerLike likes back or passes on a profile from the received likes. It is reserved to
+// subscribers, who are the only ones to see who liked them.
+func (h *DatingHandler) AnswerLike(c echo.Context) error {
+	ctx := c.Request().Context()
+	var req AnswerLikeRequest
+	if err := c.Bind(&req); err != nil {
+		helpers.ResponseWithError(c, http.StatusBadRequest, "Invalid request")
+		return nil
+	}
+	if err := c.Validate(&req); err != nil {
+		helpers.ResponseWithValidationError(c, err)
+		return nil
+	}
+	principal := helpers.CurrentPrincipal(c)
+	isPremium, err := h.matchRepository.IsPremium(ctx, principal.UserID)
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return nil
+	}
+	if !isPremium {
+		helpers.ResponseWithError(c, http.StatusForbidden, "Seeing who liked you requires an active subscription")
+		return nil
+	}
+	likerId := helpers.ConvertStringToInt(c.Param("id"))
+	like, err := h.matchRepository.FindReceivedLike(ctx, principal.ProfileID, likerId)
 	if err != nil {
 		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
 		return nil
 	}
+	if like == nil {
+		helpers.ResponseWithError(c, http.StatusNotFound, "Like not found")
+		return nil
+	}
 
-	// if partner already swiped right, accept the match
-	if partnerSwiped != nil {
-		err := h.matchRepository.AcceptMatch(profileId, partnerId)
-		if err != nil {
+	result, err := h.matchRepository.Swipe(ctx, principal.ProfileID, likerId, req.Action, 0)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrProfileNotFound):
+			helpers.ResponseWithError(c, http.StatusNotFound, "Like not found")
+		case errors.Is(err, repository.ErrAlreadySwiped), errors.Is(err, repository.ErrAlreadyMatched):
+			helpers.ResponseWithError(c, http.StatusConflict, "Like already answered")
+		default:
+			c.Logger().Error(err)
 			helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		}
+		return nil
+	}
+	helpers.ResponseWithSuccess(c, http.StatusOK, map[string]interface{}{"result": result})
+	return nil
+}
+
+// MatchList returns a page of the caller's matches, the most recent first. The limit query parameter
+// sets the page size and cursor continues from the next_cursor of the previous page.
+func (h *DatingHandler) MatchList(c echo.Context) error {
+	limit := defaultMatchPageSize
+	if param := c.QueryParam("limit"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed < 1 || parsed > maxMatchPageSize {
+			helpers.ResponseWithError(c, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxMatchPageSize))
 			return nil
 		}
-	} else {
-		err := h.matchRepository.CreateMatch(profileId, partnerId)
-		if err != nil {
-			helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		limit = parsed
+	}
+	afterMatchId := 0
+	if cursor := c.QueryParam("cursor"); cursor != "" {
+		parsed, err := strconv.Atoi(cursor)
+		if err != nil || parsed < 1 {
+			helpers.ResponseWithError(c, http.StatusBadRequest, "Invalid cursor")
 			return nil
 		}
+		afterMatchId = parsed
 	}
-	err = h.Profile(c)
+
+	profileId := helpers.CurrentPrincipal(c).ProfileID
+	// one more match than asked tells whether there is a next page
+	matches, err := h.matchRepository.FindMatches(c.Request().Context(), profileId, afterMatchId, limit+1)
 	if err != nil {
-		c.Logger().Error(err)
 		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return nil
+	}
+	page := entity.MatchPage{Matches: matches}
+	if len(matches) > limit {
+		page.Matches = matches[:limit]
+		page.NextCursor = strconv.Itoa(page.Matches[limit-1].MatchID)
 	}
+	helpers.ResponseWithSuccess(c, http.StatusOK, page)
 	return nil
 }
 
-func (h *DatingHandler) MatchList(c echo.Context) error {
-	profileId := c.Get("profile_id").(int)
-	matches, err := h.matchRepository.FindMatchByProfileID(profileId)
+// OpenMatch returns one of the caller's matches with the partner's gallery and clears its new flag.
+func (h *DatingHandler) OpenMatch(c echo.Context) error {
+	profileId := helpers.CurrentPrincipal(c).ProfileID
+	matchId := helpers.ConvertStringToInt(c.Param("id"))
+	match, err := h.matchRepository.OpenMatch(c.Request().Context(), matchId, profileId)
 	if err != nil {
 		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
 		return nil
 	}
-	helpers.ResponseWithSuccess(c, http.StatusOK, matches)
+	if match == nil {
+		helpers.ResponseWithError(c, http.StatusNotFound, "Match not found")
+		return nil
+	}
+	helpers.ResponseWithSuccess(c, http.StatusOK, match)
+	return nil
+}
+
+// Unmatch ends one of the caller's matches. It disappears from both match lists and the two
+// profiles are not shown to each other again.
+func (h *DatingHandler) Unmatch(c echo.Context) error {
+	profileId := helpers.CurrentPrincipal(c).ProfileID
+	matchId := helpers.ConvertStringToInt(c.Param("id"))
+	if _, err := h.matchRepository.Unmatch(c.Request().Context(), matchId, profileId); err != nil {
+		if errors.Is(err, repository.ErrMatchNotFound) {
+			helpers.ResponseWithError(c, http.StatusNotFound, "Match not found")
+			return nil
+		}
+		c.Logger().Error(err)
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal Server Error")
+		return nil
+	}
+	helpers.ResponseWithSuccess(c, http.StatusOK, map[string]interface{}{"message": "Unmatched"})
 	return nil
 }
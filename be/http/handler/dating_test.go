@@ -1,12 +1,18 @@
 package handler
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"main/config"
 	"main/entity"
+	"main/repository"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -21,64 +27,79 @@ type MockMatchRepository struct {
 	mock.Mock
 }
 
-func (m *MockProfileRepository) FindByUserID(userId int) (*entity.Profile, error) {
+func (m *MockProfileRepository) FindByUserID(_ context.Context, userId int) (*entity.Profile, error) {
 	args := m.Called(userId)
 	return args.Get(0).(*entity.Profile), args.Error(1)
 }
 
-func (m *MockProfileRepository) Save(profile *entity.Profile) (*entity.Profile, error) {
+func (m *MockProfileRepository) Save(_ context.Context, profile *entity.Profile) (*entity.Profile, error) {
 	args := m.Called(profile)
 	return args.Get(0).(*entity.Profile), args.Error(1)
 }
 
-func (m *MockProfileRepository) GetRandomProfile(c echo.Context) (*entity.Profile, error) {
-	args := m.Called(c)
+func (m *MockProfileRepository) GetRandomProfile(_ context.Context, viewerID int, passResurface time.Duration) (*entity.Profile, error) {
+	args := m.Called(viewerID, passResurface)
 	return args.Get(0).(*entity.Profile), args.Error(1)
 }
 
-func (m *MockProfileRepository) SaveViewLog(c echo.Context, profileID int) error {
-	args := m.Called(c, profileID)
+func (m *MockProfileRepository) SaveViewLog(_ context.Context, viewerID, profileID int) error {
+	args := m.Called(viewerID, profileID)
 	return args.Error(0)
 }
 
-func (m *MockProfileRepository) FindByID(profileID int) (*entity.Profile, error) {
+func (m *MockProfileRepository) FindByID(_ context.Context, profileID int) (*entity.Profile, error) {
 	args := m.Called(profileID)
 	return args.Get(0).(*entity.Profile), args.Error(1)
 }
 
-func (m *MockMatchRepository) CheckDailyLimit(c echo.Context) (bool, error) {
-	args := m.Called(c)
+func (m *MockMatchRepository) CheckDailyLimit(_ context.Context, userID, profileID int) (bool, error) {
+	args := m.Called(userID, profileID)
 	return args.Bool(0), args.Error(1)
 }
 
-func (m *MockMatchRepository) CheckPendingMatch(partnerID, profileID int) (*entity.Match, error) {
-	args := m.Called(partnerID, profileID)
-	return args.Get(0).(*entity.Match), args.Error(1)
+func (m *MockMatchRepository) Swipe(_ context.Context, profileID, targetID int, direction string, superlikeLimit int) (repository.SwipeResult, error) {
+	args := m.Called(profileID, targetID, direction, superlikeLimit)
+	return args.Get(0).(repository.SwipeResult), args.Error(1)
 }
 
-func (m *MockMatchRepository) RejectMatch(profileID, partnerID int) error {
-	args := m.Called(profileID, partnerID)
-	return args.Error(0)
+func (m *MockMatchRepository) SuperlikeQuota(_ context.Context, userId, profileId, limit, premiumLimit int) (*entity.SuperlikeQuota, error) {
+	args := m.Called(userId, profileId, limit, premiumLimit)
+	return args.Get(0).(*entity.SuperlikeQuota), args.Error(1)
 }
 
-func (m *MockMatchRepository) CheckMatch(profileID, partnerID int) (*entity.Match, error) {
-	args := m.Called(profileID, partnerID)
-	return args.Get(0).(*entity.Match), args.Error(1)
+func (m *MockMatchRepository) UndoSwipe(_ context.Context, profileID int, since time.Time) (*entity.Swipe, error) {
+	args := m.Called(profileID, since)
+	return args.Get(0).(*entity.Swipe), args.Error(1)
 }
 
-func (m *MockMatchRepository) AcceptMatch(profileID, partnerID int) error {
-	args := m.Called(profileID, partnerID)
-	return args.Error(0)
+func (m *MockMatchRepository) IsPremium(_ context.Context, userId int) (bool, error) {
+	args := m.Called(userId)
+	return args.Bool(0), args.Error(1)
 }
 
-func (m *MockMatchRepository) CreateMatch(profileID, partnerID int) error {
-	args := m.Called(profileID, partnerID)
-	return args.Error(0)
+func (m *MockMatchRepository) FindReceivedLikes(_ context.Context, profileID int) ([]entity.Swipe, error) {
+	args := m.Called(profileID)
+	return args.Get(0).([]entity.Swipe), args.Error(1)
 }
 
-func (m *MockMatchRepository) FindMatchByProfileID(profileID int) ([]*entity.Profile, error) {
-	args := m.Called(profileID)
-	return args.Get(0).([]*entity.Profile), args.Error(1)
+func (m *MockMatchRepository) FindReceivedLike(_ context.Context, profileID, likerID int) (*entity.Swipe, error) {
+	args := m.Called(profileID, likerID)
+	return args.Get(0).(*entity.Swipe), args.Error(1)
+}
+
+func (m *MockMatchRepository) Unmatch(_ context.Context, matchID, profileID int) (*entity.Match, error) {
+	args := m.Called(matchID, profileID)
+	return args.Get(0).(*entity.Match), args.Error(1)
+}
+
+func (m *MockMatchRepository) FindMatches(_ context.Context, profileID, afterMatchID, limit int) ([]entity.MatchListItem, error) {
+	args := m.Called(profileID, afterMatchID, limit)
+	return args.Get(0).([]entity.MatchListItem), args.Error(1)
+}
+
+func (m *MockMatchRepository) OpenMatch(_ context.Context, matchID, profileID int) (*entity.MatchListItem, error) {
+	args := m.Called(matchID, profileID)
+	return args.Get(0).(*entity.MatchListItem), args.Error(1)
 }
 
 func TestProfile(t *testing.T) {
@@ -86,20 +107,29 @@ func TestProfile(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
+	authenticate(c, 10, 1)
 
 	mockProfileRepo := new(MockProfileRepository)
 	mockMatchRepo := new(MockMatchRepository)
 
-	handler := NewDatingHandler(mockProfileRepo, mockMatchRepo)
+	handler := NewDatingHandler(mockProfileRepo, mockMatchRepo, &config.Discovery{PassResurfaceSeconds: 3600})
 
-	mockMatchRepo.On("CheckDailyLimit", c).Return(true, nil)
-	mockProfile := &entity.Profile{ID: 1}
-	mockProfileRepo.On("GetRandomProfile", c).Return(mockProfile, nil)
-	mockProfileRepo.On("SaveViewLog", c, 1).Return(nil)
+	mockMatchRepo.On("CheckDailyLimit", 10, 1).Return(true, nil)
+	birthdate := time.Now().AddDate(-25, 0, -1)
+	latitude, longitude, distance := -6.2, 106.8, 13.4
+	mockProfile := &entity.Profile{ID: 3, Birthdate: &birthdate, Gender: entity.GenderFemale, Latitude: &latitude, Longitude: &longitude, DistanceKm: &distance, Superliked: true}
+	mockProfileRepo.On("GetRandomProfile", 1, time.Hour).Return(mockProfile, nil)
+	mockProfileRepo.On("SaveViewLog", 1, 3).Return(nil)
 
 	err := handler.Profile(c)
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"age":25`)
+	assert.Contains(t, rec.Body.String(), `"gender":"female"`)
+	assert.Contains(t, rec.Body.String(), `"distance_km":15`)
+	assert.Contains(t, rec.Body.String(), `"superliked_you":true`)
+	assert.NotContains(t, rec.Body.String(), "birthdate")
+	assert.NotContains(t, rec.Body.String(), "latitude")
 }
 
 func TestProfileDailyLimit(t *testing.T) {
@@ -107,43 +137,88 @@ func TestProfileDailyLimit(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
+	authenticate(c, 10, 1)
 
 	mockProfileRepo := new(MockProfileRepository)
 	mockMatchRepo := new(MockMatchRepository)
 
-	handler := NewDatingHandler(mockProfileRepo, mockMatchRepo)
+	handler := NewDatingHandler(mockProfileRepo, mockMatchRepo, &config.Discovery{PassResurfaceSeconds: 3600})
 
-	mockMatchRepo.On("CheckDailyLimit", c).Return(false, nil)
+	mockMatchRepo.On("CheckDailyLimit", 10, 1).Return(false, nil)
 
 	err := handler.Profile(c)
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusForbidden, rec.Code)
 }
 
-func TestSwipedProfile(t *testing.T) {
+func TestProfileNoMoreProfiles(t *testing.T) {
 	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	authenticate(c, 10, 1)
+
+	mockProfileRepo := new(MockProfileRepository)
+	mockMatchRepo := new(MockMatchRepository)
+
+	handler := NewDatingHandler(mockProfileRepo, mockMatchRepo, &config.Discovery{PassResurfaceSeconds: 3600})
+
+	mockMatchRepo.On("CheckDailyLimit", 10, 1).Return(true, nil)
+	mockProfileRepo.On("GetRandomProfile", 1, time.Hour).Return((*entity.Profile)(nil), repository.ErrNoMoreProfiles)
+
+	err := handler.Profile(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "No more profiles")
+	mockProfileRepo.AssertNotCalled(t, "SaveViewLog", mock.Anything, mock.Anything)
+}
+
+func TestSwipedProfileNoMoreProfiles(t *testing.T) {
+	e := newTestEcho()
+	reqBody := `{"profile_id": 2, "swipe": true}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(reqBody))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	authenticate(c, 10, 1)
+
+	mockProfileRepo := new(MockProfileRepository)
+	mockMatchRepo := new(MockMatchRepository)
+
+	handler := NewDatingHandler(mockProfileRepo, mockMatchRepo, &config.Discovery{PassResurfaceSeconds: 3600})
+
+	mockMatchRepo.On("CheckDailyLimit", 10, 1).Return(true, nil)
+	mockMatchRepo.On("Swipe", 1, 2, entity.SwipeLike, 0).Return(repository.SwipeLiked, nil)
+	mockProfileRepo.On("GetRandomProfile", 1, time.Hour).Return((*entity.Profile)(nil), repository.ErrNoMoreProfiles)
+
+	err := handler.SwipedProfile(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Contains(t, rec.Body.String(), "No more profiles")
+	mockMatchRepo.AssertExpectations(t)
+}
+
+func TestSwipedProfile(t *testing.T) {
+	e := newTestEcho()
 	req := httptest.NewRequest(http.MethodPost, "/", nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
-	c.Set("profile_id", 1)
+	authenticate(c, 10, 1)
 
 	mockProfileRepo := new(MockProfileRepository)
 	mockMatchRepo := new(MockMatchRepository)
 
-	handler := NewDatingHandler(mockProfileRepo, mockMatchRepo)
+	handler := NewDatingHandler(mockProfileRepo, mockMatchRepo, &config.Discovery{PassResurfaceSeconds: 3600})
 	reqBody := `{"profile_id": 2, "swipe": true}`
 	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(reqBody))
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	c = e.NewContext(req, rec)
-	c.Set("profile_id", 1)
+	authenticate(c, 10, 1)
 
-	mockMatchRepo.On("CheckDailyLimit", c).Return(true, nil)
-	mockProfileRepo.On("FindByID", 1).Return(&entity.Profile{ID: 1}, nil)
-	mockMatchRepo.On("CheckMatch", 1, 2).Return((*entity.Match)(nil), nil)
-	mockMatchRepo.On("CheckMatch", 2, 1).Return((*entity.Match)(nil), nil)
-	mockMatchRepo.On("CreateMatch", 1, 2).Return(nil)
-	mockProfileRepo.On("GetRandomProfile", c).Return(&entity.Profile{ID: 2}, nil)
-	mockProfileRepo.On("SaveViewLog", c, 2).Return(nil)
+	mockMatchRepo.On("CheckDailyLimit", 10, 1).Return(true, nil)
+	mockMatchRepo.On("Swipe", 1, 2, entity.SwipeLike, 0).Return(repository.SwipeLiked, nil)
+	mockProfileRepo.On("GetRandomProfile", 1, time.Hour).Return(&entity.Profile{ID: 2}, nil)
+	mockProfileRepo.On("SaveViewLog", 1, 2).Return(nil)
 
 	err := handler.SwipedProfile(c)
 	assert.NoError(t, err)
@@ -151,33 +226,306 @@ func TestSwipedProfile(t *testing.T) {
 }
 
 func TestSwipedProfileDailyLimit(t *testing.T) {
-	e := echo.New()
+	e := newTestEcho()
 	req := httptest.NewRequest(http.MethodPost, "/", nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
-	c.Set("profile_id", 1)
+	authenticate(c, 10, 1)
 
 	mockProfileRepo := new(MockProfileRepository)
 	mockMatchRepo := new(MockMatchRepository)
 
-	handler := NewDatingHandler(mockProfileRepo, mockMatchRepo)
+	handler := NewDatingHandler(mockProfileRepo, mockMatchRepo, &config.Discovery{PassResurfaceSeconds: 3600})
 	reqBody := `{"profile_id": 2, "swipe": true}`
 	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(reqBody))
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	c = e.NewContext(req, rec)
-	c.Set("profile_id", 1)
+	authenticate(c, 10, 1)
+
+	mockMatchRepo.On("CheckDailyLimit", 10, 1).Return(false, nil)
+	mockMatchRepo.On("Swipe", 1, 2, entity.SwipeLike, 0).Return(repository.SwipeLiked, nil)
+	mockProfileRepo.On("GetRandomProfile", 1, time.Hour).Return(&entity.Profile{ID: 2}, nil)
+	mockProfileRepo.On("SaveViewLog", 1, 2).Return(nil)
+
+	err := handler.SwipedProfile(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+}
+
+func TestSwipedProfileLeft(t *testing.T) {
+	e := newTestEcho()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"profile_id": 2, "swipe": false}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c := e.NewContext(req, rec)
+	authenticate(c, 10, 1)
+
+	mockProfileRepo := new(MockProfileRepository)
+	mockMatchRepo := new(MockMatchRepository)
+
+	handler := NewDatingHandler(mockProfileRepo, mockMatchRepo, &config.Discovery{PassResurfaceSeconds: 3600})
+
+	mockMatchRepo.On("Swipe", 1, 2, entity.SwipePass, 0).Return(repository.SwipePassed, nil)
+	mockMatchRepo.On("CheckDailyLimit", 10, 1).Return(true, nil)
+	mockProfileRepo.On("GetRandomProfile", 1, time.Hour).Return(&entity.Profile{ID: 3}, nil)
+	mockProfileRepo.On("SaveViewLog", 1, 3).Return(nil)
+
+	err := handler.SwipedProfile(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockMatchRepo.AssertExpectations(t)
+}
+
+func TestSwipedProfileSuperlike(t *testing.T) {
+	e := newTestEcho()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"profile_id": 2, "action": "superlike"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c := e.NewContext(req, rec)
+	authenticate(c, 10, 1)
 
-	mockMatchRepo.On("CheckDailyLimit", c).Return(false, nil)
-	mockProfileRepo.On("FindByID", 1).Return(&entity.Profile{ID: 1}, nil)
-	mockMatchRepo.On("CheckMatch", 1, 2).Return((*entity.Match)(nil), nil)
-	mockMatchRepo.On("CheckMatch", 2, 1).Return((*entity.Match)(nil), nil)
-	mockMatchRepo.On("CreateMatch", 1, 2).Return(nil)
-	mockProfileRepo.On("GetRandomProfile", c).Return(&entity.Profile{ID: 2}, nil)
-	mockProfileRepo.On("SaveViewLog", c, 2).Return(nil)
+	mockProfileRepo := new(MockProfileRepository)
+	mockMatchRepo := new(MockMatchRepository)
+
+	handler := NewDatingHandler(mockProfileRepo, mockMatchRepo, &config.Discovery{PassResurfaceSeconds: 3600, SuperlikeDailyLimit: 1, SuperlikePremiumDailyLimit: 5})
+
+	mockMatchRepo.On("SuperlikeQuota", 10, 1, 1, 5).Return(&entity.SuperlikeQuota{Limit: 5, Used: 2, Remaining: 3}, nil)
+	mockMatchRepo.On("Swipe", 1, 2, entity.SwipeSuperlike, 5).Return(repository.SwipeLiked, nil)
+	mockMatchRepo.On("CheckDailyLimit", 10, 1).Return(true, nil)
+	mockProfileRepo.On("GetRandomProfile", 1, time.Hour).Return(&entity.Profile{ID: 3}, nil)
+	mockProfileRepo.On("SaveViewLog", 1, 3).Return(nil)
+
+	err := handler.SwipedProfile(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockMatchRepo.AssertExpectations(t)
+}
+
+func TestSwipedProfileSuperlikeLimit(t *testing.T) {
+	e := newTestEcho()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"profile_id": 2, "action": "superlike"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c := e.NewContext(req, rec)
+	authenticate(c, 10, 1)
+
+	mockMatchRepo := new(MockMatchRepository)
+	handler := NewDatingHandler(new(MockProfileRepository), mockMatchRepo, &config.Discovery{SuperlikeDailyLimit: 1, SuperlikePremiumDailyLimit: 5})
+
+	mockMatchRepo.On("SuperlikeQuota", 10, 1, 1, 5).Return(&entity.SuperlikeQuota{Limit: 1, Used: 1}, nil)
+	mockMatchRepo.On("Swipe", 1, 2, entity.SwipeSuperlike, 1).Return(repository.SwipeResult(""), repository.ErrSuperlikeLimit)
 
 	err := handler.SwipedProfile(c)
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Daily superlike limit reached")
+}
+
+func TestSwipedProfileInvalidAction(t *testing.T) {
+	e := newTestEcho()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"profile_id": 2, "action": "maybe"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c := e.NewContext(req, rec)
+	authenticate(c, 10, 1)
+
+	mockMatchRepo := new(MockMatchRepository)
+	handler := NewDatingHandler(new(MockProfileRepository), mockMatchRepo, &config.Discovery{})
+
+	err := handler.SwipedProfile(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	mockMatchRepo.AssertNotCalled(t, "Swipe", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSwipedProfileErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		profileID int
+		swipeErr  error
+		code      int
+		message   string
+	}{
+		{"own profile", 1, nil, http.StatusBadRequest, "Cannot swipe your own profile"},
+		{"unknown profile", 2, repository.ErrProfileNotFound, http.StatusNotFound, "Profile not found"},
+		{"already swiped", 2, repository.ErrAlreadySwiped, http.StatusConflict, "Already swiped"},
+		{"already matched", 2, repository.ErrAlreadyMatched, http.StatusConflict, "Already matched"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEcho()
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(fmt.Sprintf(`{"profile_id": %d, "swipe": true}`, tt.profileID)))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			c := e.NewContext(req, rec)
+			authenticate(c, 10, 1)
+
+			mockMatchRepo := new(MockMatchRepository)
+			handler := NewDatingHandler(new(MockProfileRepository), mockMatchRepo, &config.Discovery{PassResurfaceSeconds: 3600})
+			mockMatchRepo.On("Swipe", 1, tt.profileID, entity.SwipeLike, 0).Return(repository.SwipeResult(""), tt.swipeErr)
+
+			err := handler.SwipedProfile(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.code, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.message)
+			mockMatchRepo.AssertNotCalled(t, "CheckDailyLimit", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUndoSwipe(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/swipe/undo", nil), rec)
+	authenticate(c, 10, 1)
+
+	mockMatchRepo := new(MockMatchRepository)
+	handler := NewDatingHandler(new(MockProfileRepository), mockMatchRepo, &config.Discovery{UndoWindowSeconds: 300})
+
+	mockMatchRepo.On("IsPremium", 10).Return(true, nil)
+	withinWindow := mock.MatchedBy(func(since time.Time) bool {
+		return time.Since(since) >= 300*time.Second && time.Since(since) < 301*time.Second
+	})
+	mockMatchRepo.On("UndoSwipe", 1, withinWindow).Return(&entity.Swipe{ID: 4, ProfileID: 1, TargetID: 2, Direction: entity.SwipePass}, nil)
+
+	err := handler.UndoSwipe(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"target_id":2`)
+	mockMatchRepo.AssertExpectations(t)
+}
+
+func TestUndoSwipeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		premium bool
+		undoErr error
+		code    int
+		message string
+	}{
+		{"free user", false, nil, http.StatusForbidden, "Undo requires an active subscription"},
+		{"nothing to undo", true, repository.ErrNothingToUndo, http.StatusNotFound, "No swipe to undo"},
+		{"match", true, repository.ErrAlreadyMatched, http.StatusConflict, "Cannot undo a match"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodPost, "/swipe/undo", nil), rec)
+			authenticate(c, 10, 1)
+
+			mockMatchRepo := new(MockMatchRepository)
+			handler := NewDatingHandler(new(MockProfileRepository), mockMatchRepo, &config.Discovery{UndoWindowSeconds: 300})
+			mockMatchRepo.On("IsPremium", 10).Return(tt.premium, nil)
+			mockMatchRepo.On("UndoSwipe", 1, mock.Anything).Return((*entity.Swipe)(nil), tt.undoErr)
+
+			err := handler.UndoSwipe(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.code, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.message)
+			if !tt.premium {
+				mockMatchRepo.AssertNotCalled(t, "UndoSwipe", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestReceivedLikes(t *testing.T) {
+	likes := []entity.Swipe{
+		{ProfileID: 4, TargetID: 1, Direction: entity.SwipeSuperlike, Profile: entity.Profile{ID: 4, Job: "Pilot"}},
+		{ProfileID: 5, TargetID: 1, Direction: entity.SwipeLike, Profile: entity.Profile{ID: 5, Job: "Chef"}},
+	}
+	tests := []struct {
+		name     string
+		premium  bool
+		contains []string
+		excludes []string
+	}{
+		{"subscriber", true, []string{`"count":2`, `"blurred":false`, `"job":"Pilot"`, `"superliked":true`}, nil},
+		{"free user", false, []string{`"count":2`, `"blurred":true`, `"superliked":true`}, []string{"Pilot", "Chef", `"profile"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/likes/received", nil), rec)
+			authenticate(c, 10, 1)
+
+			mockMatchRepo := new(MockMatchRepository)
+			handler := NewDatingHandler(new(MockProfileRepository), mockMatchRepo, &config.Discovery{})
+			mockMatchRepo.On("IsPremium", 10).Return(tt.premium, nil)
+			mockMatchRepo.On("FindReceivedLikes", 1).Return(likes, nil)
+
+			err := handler.ReceivedLikes(c)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			for _, fragment := range tt.contains {
+				assert.Contains(t, rec.Body.String(), fragment)
+			}
+			for _, fragment := range tt.excludes {
+				assert.NotContains(t, rec.Body.String(), fragment)
+			}
+		})
+	}
+}
+
+func TestAnswerLike(t *testing.T) {
+	e := newTestEcho()
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/likes/received/4", strings.NewReader(`{"action": "like"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("4")
+	authenticate(c, 10, 1)
+
+	mockMatchRepo := new(MockMatchRepository)
+	handler := NewDatingHandler(new(MockProfileRepository), mockMatchRepo, &config.Discovery{})
+	mockMatchRepo.On("IsPremium", 10).Return(true, nil)
+	mockMatchRepo.On("FindReceivedLike", 1, 4).Return(&entity.Swipe{ProfileID: 4, TargetID: 1, Direction: entity.SwipeLike}, nil)
+	mockMatchRepo.On("Swipe", 1, 4, entity.SwipeLike, 0).Return(repository.SwipeMatched, nil)
+
+	err := handler.AnswerLike(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"result":"matched"`)
+	mockMatchRepo.AssertExpectations(t)
+}
+
+func TestAnswerLikeErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		premium bool
+		like    *entity.Swipe
+		code    int
+	}{
+		{"invalid action", `{"action": "superlike"}`, true, nil, http.StatusBadRequest},
+		{"free user", `{"action": "like"}`, false, nil, http.StatusForbidden},
+		{"no like", `{"action": "pass"}`, true, nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEcho()
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/likes/received/4", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			c := e.NewContext(req, rec)
+			c.SetParamNames("id")
+			c.SetParamValues("4")
+			authenticate(c, 10, 1)
+
+			mockMatchRepo := new(MockMatchRepository)
+			handler := NewDatingHandler(new(MockProfileRepository), mockMatchRepo, &config.Discovery{})
+			mockMatchRepo.On("IsPremium", 10).Return(tt.premium, nil)
+			mockMatchRepo.On("FindReceivedLike", 1, 4).Return(tt.like, nil)
+
+			err := handler.AnswerLike(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.code, rec.Code)
+			mockMatchRepo.AssertNotCalled(t, "Swipe", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
 }
 
 func TestMatchList(t *testing.T) {
@@ -185,17 +533,126 @@ func TestMatchList(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
-	c.Set("profile_id", 1)
+	authenticate(c, 10, 1)
 
 	mockProfileRepo := new(MockProfileRepository)
 	mockMatchRepo := new(MockMatchRepository)
 
-	handler := NewDatingHandler(mockProfileRepo, mockMatchRepo)
+	handler := NewDatingHandler(mockProfileRepo, mockMatchRepo, &config.Discovery{PassResurfaceSeconds: 3600})
 
-	mockMatches := []*entity.Profile{{ID: 1}, {ID: 2}}
-	mockMatchRepo.On("FindMatchByProfileID", 1).Return(mockMatches, nil)
+	mockMatches := []entity.MatchListItem{
+		{MatchID: 8, IsNew: true, Name: "Jane", Partner: entity.PublicProfile{ID: 2}},
+		{MatchID: 5, Name: "Mary", Partner: entity.PublicProfile{ID: 3}},
+	}
+	mockMatchRepo.On("FindMatches", 1, 0, 21).Return(mockMatches, nil)
 
 	err := handler.MatchList(c)
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"match_id":8`)
+	assert.Contains(t, rec.Body.String(), `"is_new":true`)
+	assert.Contains(t, rec.Body.String(), `"name":"Jane"`)
+	assert.NotContains(t, rec.Body.String(), "next_cursor")
+}
+
+func TestMatchListPagination(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/match?limit=2&cursor=9", nil), rec)
+	authenticate(c, 10, 1)
+
+	mockMatchRepo := new(MockMatchRepository)
+	handler := NewDatingHandler(new(MockProfileRepository), mockMatchRepo, &config.Discovery{})
+	mockMatchRepo.On("FindMatches", 1, 9, 3).Return([]entity.MatchListItem{{MatchID: 8}, {MatchID: 5}, {MatchID: 4}}, nil)
+
+	err := handler.MatchList(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var body struct {
+		Data entity.MatchPage `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.Len(t, body.Data.Matches, 2)
+	assert.Equal(t, "5", body.Data.NextCursor)
+}
+
+func TestMatchListInvalidParameters(t *testing.T) {
+	for _, query := range []string{"limit=0", "limit=51", "limit=ten", "cursor=abc", "cursor=-1"} {
+		t.Run(query, func(t *testing.T) {
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/match?"+query, nil), rec)
+			authenticate(c, 10, 1)
+
+			mockMatchRepo := new(MockMatchRepository)
+			handler := NewDatingHandler(new(MockProfileRepository), mockMatchRepo, &config.Discovery{})
+
+			err := handler.MatchList(c)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			mockMatchRepo.AssertNotCalled(t, "FindMatches", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestOpenMatch(t *testing.T) {
+	tests := []struct {
+		name  string
+		match *entity.MatchListItem
+		code  int
+	}{
+		{"own match", &entity.MatchListItem{MatchID: 5, Name: "Jane"}, http.StatusOK},
+		{"unknown match", nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodGet, "/match/5", nil), rec)
+			c.SetParamNames("id")
+			c.SetParamValues("5")
+			authenticate(c, 10, 1)
+
+			mockMatchRepo := new(MockMatchRepository)
+			handler := NewDatingHandler(new(MockProfileRepository), mockMatchRepo, &config.Discovery{})
+			mockMatchRepo.On("OpenMatch", 5, 1).Return(tt.match, nil)
+
+			err := handler.OpenMatch(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.code, rec.Code)
+			mockMatchRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestUnmatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		unmatchErr error
+		code       int
+		message    string
+	}{
+		{"active match", nil, http.StatusOK, "Unmatched"},
+		{"unknown match", repository.ErrMatchNotFound, http.StatusNotFound, "Match not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := echo.New()
+			rec := httptest.NewRecorder()
+			c := e.NewContext(httptest.NewRequest(http.MethodDelete, "/match/5", nil), rec)
+			c.SetParamNames("id")
+			c.SetParamValues("5")
+			authenticate(c, 10, 1)
+
+			mockMatchRepo := new(MockMatchRepository)
+			handler := NewDatingHandler(new(MockProfileRepository), mockMatchRepo, &config.Discovery{})
+			mockMatchRepo.On("Unmatch", 5, 1).Return(&entity.Match{ID: 5, Status: entity.StatusUnmatched}, tt.unmatchErr)
+
+			err := handler.Unmatch(c)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.code, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.message)
+			mockMatchRepo.AssertExpectations(t)
+		})
+	}
 }
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"fmt"
+	"main/entity"
+	"main/helpers"
+	"main/repository"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type ExportHandler struct {
+	exportRepo repository.ExportRepositoryInterface
+}
+
+func NewExportHandler(exportRepo repository.ExportRepositoryInterface) *ExportHandler {
+	return &ExportHandler{
+		exportRepo: exportRepo,
+	}
+}
+
+// RequestExport queues an export of the authenticated user's data. While an export is still being
+// built it is returned instead of queueing another one.
+func (h *ExportHandler) RequestExport(c echo.Context) error {
+	userId := helpers.CurrentPrincipal(c).UserID
+	export, err := h.exportRepo.FindInProgress(c.Request().Context(), userId)
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if export == nil {
+		export = &entity.DataExport{
+			UserID: uint(userId),
+			Status: entity.ExportStatusPending,
+		}
+		if err := h.exportRepo.Create(c.Request().Context(), export); err != nil {
+			helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+			return nil
+		}
+	}
+
+	helpers.ResponseWithSuccess(c, http.StatusAccepted, export)
+	return nil
+}
+
+// GetExport returns the status of an export, or the ZIP archive once it is ready.
+func (h *ExportHandler) GetExport(c echo.Context) error {
+	userId := helpers.CurrentPrincipal(c).UserID
+	id := helpers.ConvertStringToInt(c.Param("id"))
+	export, err := h.exportRepo.FindByID(c.Request().Context(), userId, id)
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if export == nil {
+		helpers.ResponseWithError(c, http.StatusNotFound, "Export not found")
+		return nil
+	}
+
+	switch export.Status {
+	case entity.ExportStatusReady:
+		return c.Attachment(export.FilePath, fmt.Sprintf("data-export-%d.zip", export.ID))
+	case entity.ExportStatusExpired:
+		helpers.ResponseWithError(c, http.StatusGone, "Export expired")
+		return nil
+	case entity.ExportStatusFailed:
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Export failed")
+		return nil
+	}
+	helpers.ResponseWithSuccess(c, http.StatusAccepted, export)
+	return nil
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"context"
+	"main/entity"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockExportRepository struct {
+	mock.Mock
+}
+
+func (m *MockExportRepository) Create(_ context.Context, export *entity.DataExport) error {
+	args := m.Called(export)
+	return args.Error(0)
+}
+
+func (m *MockExportRepository) FindByID(_ context.Context, userID int, id int) (*entity.DataExport, error) {
+	args := m.Called(userID, id)
+	return args.Get(0).(*entity.DataExport), args.Error(1)
+}
+
+func (m *MockExportRepository) FindInProgress(_ context.Context, userID int) (*entity.DataExport, error) {
+	args := m.Called(userID)
+	return args.Get(0).(*entity.DataExport), args.Error(1)
+}
+
+func (m *MockExportRepository) ClaimPending(_ context.Context, staleBefore time.Time) (*entity.DataExport, error) {
+	args := m.Called(staleBefore)
+	return args.Get(0).(*entity.DataExport), args.Error(1)
+}
+
+func (m *MockExportRepository) Complete(_ context.Context, id uint, filePath string, expiresAt time.Time) error {
+	args := m.Called(id, filePath, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockExportRepository) Fail(_ context.Context, id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockExportRepository) FindExpired(_ context.Context, now time.Time) ([]entity.DataExport, error) {
+	args := m.Called(now)
+	return args.Get(0).([]entity.DataExport), args.Error(1)
+}
+
+func (m *MockExportRepository) MarkExpired(_ context.Context, id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockExportRepository) LoadUserData(_ context.Context, userID int) (*entity.UserData, error) {
+	args := m.Called(userID)
+	return args.Get(0).(*entity.UserData), args.Error(1)
+}
+
+func TestRequestExport(t *testing.T) {
+	e := newTestEcho()
+	mockExportRepo := new(MockExportRepository)
+	handler := NewExportHandler(mockExportRepo)
+
+	req := httptest.NewRequest(http.MethodPost, "/me/export", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	authenticate(c, 1, 2)
+
+	mockExportRepo.On("FindInProgress", 1).Return((*entity.DataExport)(nil), nil)
+	mockExportRepo.On("Create", mock.MatchedBy(func(export *entity.DataExport) bool {
+		return export.UserID == 1 && export.Status == entity.ExportStatusPending
+	})).Run(func(args mock.Arguments) {
+		args.Get(0).(*entity.DataExport).ID = 7
+	}).Return(nil)
+
+	if assert.NoError(t, handler.RequestExport(c)) {
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"id":7,"status":"pending"`)
+	}
+}
+
+func TestRequestExportInProgress(t *testing.T) {
+	e := newTestEcho()
+	mockExportRepo := new(MockExportRepository)
+	handler := NewExportHandler(mockExportRepo)
+
+	req := httptest.NewRequest(http.MethodPost, "/me/export", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	authenticate(c, 1, 2)
+
+	mockExportRepo.On("FindInProgress", 1).Return(&entity.DataExport{ID: 3, UserID: 1, Status: entity.ExportStatusProcessing}, nil)
+
+	if assert.NoError(t, handler.RequestExport(c)) {
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"id":3,"status":"processing"`)
+	}
+	mockExportRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestGetExportPending(t *testing.T) {
+	e := newTestEcho()
+	mockExportRepo := new(MockExportRepository)
+	handler := NewExportHandler(mockExportRepo)
+
+	req := httptest.NewRequest(http.MethodGet, "/me/export/3", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("3")
+	authenticate(c, 1, 2)
+
+	mockExportRepo.On("FindByID", 1, 3).Return(&entity.DataExport{ID: 3, UserID: 1, Status: entity.ExportStatusPending}, nil)
+
+	if assert.NoError(t, handler.GetExport(c)) {
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"status":"pending"`)
+	}
+}
+
+func TestGetExportReady(t *testing.T) {
+	e := newTestEcho()
+	mockExportRepo := new(MockExportRepository)
+	handler := NewExportHandler(mockExportRepo)
+
+	filePath := filepath.Join(t.TempDir(), "export.zip")
+	assert.NoError(t, os.WriteFile(filePath, []byte("zip"), 0o600))
+
+	req := httptest.NewRequest(http.MethodGet, "/me/export/3", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("3")
+	authenticate(c, 1, 2)
+
+	mockExportRepo.On("FindByID", 1, 3).Return(&entity.DataExport{ID: 3, UserID: 1, Status: entity.ExportStatusReady, FilePath: filePath}, nil)
+
+	if assert.NoError(t, handler.GetExport(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "zip", rec.Body.String())
+		assert.Contains(t, rec.Header().Get("Content-Disposition"), "data-export-3.zip")
+	}
+}
+
+func TestGetExportNotFound(t *testing.T) {
+	e := newTestEcho()
+	mockExportRepo := new(MockExportRepository)
+	handler := NewExportHandler(mockExportRepo)
+
+	req := httptest.NewRequest(http.MethodGet, "/me/export/4", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("4")
+	authenticate(c, 1, 2)
+
+	mockExportRepo.On("FindByID", 1, 4).Return((*entity.DataExport)(nil), nil)
+
+	if assert.NoError(t, handler.GetExport(c)) {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"main/helpers"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	mfaIssuer         = "dating-app"
+	recoveryCodeCount = 10
+)
+
+type MFACodeRequest struct {
+	Code string `json:"code" validate:"required"`
+}
+
+type DisableMFARequest struct {
+	Password string `json:"password" validate:"required"`
+	Code     string `json:"code" validate:"required"`
+}
+
+type LoginMFARequest struct {
+	MFAToken   string `json:"mfa_token" validate:"required"`
+	Code       string `json:"code" validate:"required"`
+	DeviceName string `json:"device_name" validate:"max=255"`
+}
+
+// EnrollMFA generates a new TOTP secret for the authenticated user. Two-factor authentication
+// is only enforced after the secret is confirmed with ConfirmMFA.
+func (h *AuthHandler) EnrollMFA(echoCtx echo.Context) error {
+	userId := helpers.CurrentPrincipal(echoCtx).UserID
+	user, err := h.userRepo.FindByID(echoCtx.Request().Context(), userId)
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if user.IsTOTPEnabled() {
+		helpers.ResponseWithError(echoCtx, http.StatusConflict, "Two-factor authentication already enabled")
+		return nil
+	}
+
+	secret, err := helpers.GenerateTOTPSecret()
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if err := h.userRepo.SetTOTPSecret(echoCtx.Request().Context(), userId, secret); err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+
+	helpers.ResponseWithSuccess(echoCtx, http.StatusOK, map[string]string{
+		"secret":      secret,
+		"otpauth_uri": helpers.TOTPURI(secret, user.Email, mfaIssuer),
+	})
+	return nil
+}
+
+// ConfirmMFA enables two-factor authentication once the user proves the authenticator app works,
+// and returns the recovery codes. They are stored hashed and cannot be shown again.
+func (h *AuthHandler) ConfirmMFA(echoCtx echo.Context) error {
+	var req MFACodeRequest
+	if err := echoCtx.Bind(&req); err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusBadRequest, "Invalid request")
+		return nil
+	}
+	if err := echoCtx.Validate(&req); err != nil {
+		helpers.ResponseWithValidationError(echoCtx, err)
+		return nil
+	}
+
+	userId := helpers.CurrentPrincipal(echoCtx).UserID
+	user, err := h.userRepo.FindByID(echoCtx.Request().Context(), userId)
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if user.IsTOTPEnabled() {
+		helpers.ResponseWithError(echoCtx, http.StatusConflict, "Two-factor authentication already enabled")
+		return nil
+	}
+	if user.TOTPSecret == "" {
+		helpers.ResponseWithError(echoCtx, http.StatusBadRequest, "Two-factor authentication enrollment not started")
+		return nil
+	}
+	if _, ok := helpers.ValidateTOTPCode(user.TOTPSecret, req.Code, time.Now()); !ok {
+		helpers.ResponseWithError(echoCtx, http.StatusUnauthorized, "Invalid code")
+		return nil
+	}
+
+	codes, err := helpers.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, helpers.HashToken(helpers.NormalizeRecoveryCode(code)))
+	}
+	if err := h.userRepo.EnableTOTP(echoCtx.Request().Context(), userId, hashes); err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+
+	helpers.ResponseWithSuccess(echoCtx, http.StatusOK, map[string]interface{}{"recovery_codes": codes})
+	return nil
+}
+
+// DisableMFA turns two-factor authentication off after checking the password and a second factor.
+func (h *AuthHandler) DisableMFA(echoCtx echo.Context) error {
+	ctx := echoCtx.Request().Context()
+	var req DisableMFARequest
+	if err := echoCtx.Bind(&req); err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusBadRequest, "Invalid request")
+		return nil
+	}
+	if err := echoCtx.Validate(&req); err != nil {
+		helpers.ResponseWithValidationError(echoCtx, err)
+		return nil
+	}
+
+	userId := helpers.CurrentPrincipal(echoCtx).UserID
+	user, err := h.userRepo.FindByID(ctx, userId)
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if !user.IsTOTPEnabled() {
+		helpers.ResponseWithError(echoCtx, http.StatusBadRequest, "Two-factor authentication not enabled")
+		return nil
+	}
+	if !helpers.ComparePassword(user.Password, req.Password) {
+		helpers.ResponseWithError(echoCtx, http.StatusUnauthorized, "Invalid credentials")
+		return nil
+	}
+	valid, err := h.verifySecondFactor(ctx, userId, user.TOTPSecret, req.Code)
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if !valid {
+		helpers.ResponseWithError(echoCtx, http.StatusUnauthorized, "Invalid code")
+		return nil
+	}
+
+	if err := h.userRepo.DisableTOTP(ctx, userId); err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+
+	helpers.ResponseWithSuccess(echoCtx, http.StatusOK, map[string]interface{}{"message": "Two-factor authentication disabled"})
+	return nil
+}
+
+// LoginMFA completes a login started with a correct password by exchanging the MFA pending
+// token and a TOTP or recovery code for the access and refresh tokens.
+func (h *AuthHandler) LoginMFA(echoCtx echo.Context) error {
+	ctx := echoCtx.Request().Context()
+	var req LoginMFARequest
+	if err := echoCtx.Bind(&req); err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusBadRequest, "Invalid request")
+		return nil
+	}
+	if err := echoCtx.Validate(&req); err != nil {
+		helpers.ResponseWithValidationError(echoCtx, err)
+		return nil
+	}
+
+	userId, err := helpers.ValidateMFAToken(req.MFAToken, h.keys)
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return nil
+	}
+
+	mfaKey := "mfa:" + strconv.Itoa(userId)
+	retryAfter, err := h.lockedFor(ctx, mfaKey)
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if retryAfter > 0 {
+		respondLocked(echoCtx, retryAfter)
+		return nil
+	}
+
+	user, err := h.userRepo.FindByID(ctx, userId)
+	if err != nil || !user.IsTOTPEnabled() {
+		helpers.ResponseWithError(echoCtx, http.StatusUnauthorized, "Invalid or expired MFA token")
+		return nil
+	}
+	valid, err := h.verifySecondFactor(ctx, userId, user.TOTPSecret, req.Code)
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if !valid {
+		h.recordFailure(ctx, mfaKey, h.cfg.LoginThrottle.AccountMaxAttempts, echoCtx.RealIP())
+		helpers.ResponseWithError(echoCtx, http.StatusUnauthorized, "Invalid code")
+		return nil
+	}
+	if err := h.attemptRepo.Reset(ctx, mfaKey); err != nil {
+		log.Printf("Failed to reset login attempts: %v", err)
+	}
+
+	h.respondWithNewTokens(echoCtx, user, req.DeviceName)
+	return nil
+}
+
+// verifySecondFactor accepts either a current TOTP code, which may only be used once,
+// or an unused recovery code.
+func (h *AuthHandler) verifySecondFactor(ctx context.Context, userId int, secret string, code string) (bool, error) {
+	if step, ok := helpers.ValidateTOTPCode(secret, code, time.Now()); ok {
+		return h.userRepo.UseTOTPStep(ctx, userId, step)
+	}
+	return h.userRepo.UseRecoveryCode(ctx, userId, helpers.HashToken(helpers.NormalizeRecoveryCode(code)))
+}
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"encoding/json"
+	"main/config"
+	"main/entity"
+	"main/helpers"
+	"main/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func newMFATestHandler(t *testing.T, mockUserRepo *MockUserRepository, mockTokenRepo *MockTokenRepository) (*AuthHandler, *config.Config) {
+	cfg := &config.Config{
+		JWT: config.JWT{Secret: "secret", Expiry: 3600, MFAExpiry: 300},
+	}
+	keys, _ := helpers.NewKeySet(&cfg.JWT)
+	return NewAuthHandler(mockUserRepo, mockTokenRepo, new(MockSessionRepository), repository.NewMemoryLoginAttemptRepository(), keys, new(MockMailer), cfg), cfg
+}
+
+func TestLoginRequiresMFA(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	handler, _ := newMFATestHandler(t, mockUserRepo, mockTokenRepo)
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"john@example.com","password":"password"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	password, _ := helpers.HashPassword("password")
+	enabledAt := time.Now()
+	user := &entity.User{ID: 1, Email: "john@example.com", Password: *password, TOTPSecret: testTOTPSecret, TOTPEnabledAt: &enabledAt}
+	mockUserRepo.On("FindByEmail", "john@example.com").Return(user, nil)
+
+	if assert.NoError(t, handler.Login(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "mfa_token")
+		assert.NotContains(t, rec.Body.String(), "access_token")
+	}
+	mockTokenRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestLoginMFA(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	handler, cfg := newMFATestHandler(t, mockUserRepo, mockTokenRepo)
+
+	enabledAt := time.Now()
+	user := &entity.User{ID: 1, Email: "john@example.com", TOTPSecret: testTOTPSecret, TOTPEnabledAt: &enabledAt}
+	mfaToken, _ := helpers.GenerateMFAToken(user, &cfg.JWT, handler.keys)
+	step := time.Now().Unix() / 30
+	code, _ := helpers.GenerateTOTPCode(testTOTPSecret, time.Unix(step*30, 0))
+
+	req := httptest.NewRequest(http.MethodPost, "/login/2fa", strings.NewReader(`{"mfa_token":"`+*mfaToken+`","code":"`+code+`"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockUserRepo.On("FindByID", 1).Return(user, nil)
+	mockUserRepo.On("UseTOTPStep", 1, step).Return(true, nil)
+	handler.sessionRepo.(*MockSessionRepository).On("Create", mock.AnythingOfType("*entity.Session")).Return(nil)
+	mockTokenRepo.On("Create", mock.AnythingOfType("*entity.RefreshToken")).Return(nil)
+
+	if assert.NoError(t, handler.LoginMFA(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "access_token")
+	}
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestLoginMFARecoveryCode(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	handler, cfg := newMFATestHandler(t, mockUserRepo, mockTokenRepo)
+
+	enabledAt := time.Now()
+	user := &entity.User{ID: 1, Email: "john@example.com", TOTPSecret: testTOTPSecret, TOTPEnabledAt: &enabledAt}
+	mfaToken, _ := helpers.GenerateMFAToken(user, &cfg.JWT, handler.keys)
+
+	req := httptest.NewRequest(http.MethodPost, "/login/2fa", strings.NewReader(`{"mfa_token":"`+*mfaToken+`","code":"abcde-fghij"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockUserRepo.On("FindByID", 1).Return(user, nil)
+	mockUserRepo.On("UseRecoveryCode", 1, helpers.HashToken("ABCDEFGHIJ")).Return(true, nil)
+	handler.sessionRepo.(*MockSessionRepository).On("Create", mock.AnythingOfType("*entity.Session")).Return(nil)
+	mockTokenRepo.On("Create", mock.AnythingOfType("*entity.RefreshToken")).Return(nil)
+
+	if assert.NoError(t, handler.LoginMFA(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "access_token")
+	}
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestLoginMFAInvalidCode(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	handler, cfg := newMFATestHandler(t, mockUserRepo, mockTokenRepo)
+
+	enabledAt := time.Now()
+	user := &entity.User{ID: 1, Email: "john@example.com", TOTPSecret: testTOTPSecret, TOTPEnabledAt: &enabledAt}
+	mfaToken, _ := helpers.GenerateMFAToken(user, &cfg.JWT, handler.keys)
+
+	req := httptest.NewRequest(http.MethodPost, "/login/2fa", strings.NewReader(`{"mfa_token":"`+*mfaToken+`","code":"wrong"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	mockUserRepo.On("FindByID", 1).Return(user, nil)
+	mockUserRepo.On("UseRecoveryCode", 1, helpers.HashToken("WRONG")).Return(false, nil)
+
+	if assert.NoError(t, handler.LoginMFA(c)) {
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+	mockTokenRepo.AssertNotCalled(t, "Create", mock.Anything)
+}
+
+func TestLoginMFARejectsAccessToken(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	handler, cfg := newMFATestHandler(t, mockUserRepo, new(MockTokenRepository))
+
+	accessToken, _ := helpers.GenerateAccessToken(&entity.User{ID: 1}, "session", &cfg.JWT, handler.keys)
+
+	req := httptest.NewRequest(http.MethodPost, "/login/2fa", strings.NewReader(`{"mfa_token":"`+*accessToken+`","code":"123456"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	if assert.NoError(t, handler.LoginMFA(c)) {
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+	mockUserRepo.AssertNotCalled(t, "FindByID", mock.Anything)
+}
+
+func TestEnrollMFA(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	handler, _ := newMFATestHandler(t, mockUserRepo, new(MockTokenRepository))
+
+	req := httptest.NewRequest(http.MethodPost, "/me/2fa/enroll", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	authenticate(c, 1, 2)
+
+	mockUserRepo.On("FindByID", 1).Return(&entity.User{ID: 1, Email: "john@example.com"}, nil)
+	mockUserRepo.On("SetTOTPSecret", 1, mock.AnythingOfType("string")).Return(nil)
+
+	if assert.NoError(t, handler.EnrollMFA(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "otpauth://totp/")
+	}
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestConfirmMFA(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	handler, _ := newMFATestHandler(t, mockUserRepo, new(MockTokenRepository))
+
+	code, _ := helpers.GenerateTOTPCode(testTOTPSecret, time.Now())
+	req := httptest.NewRequest(http.MethodPost, "/me/2fa/confirm", strings.NewReader(`{"code":"`+code+`"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	authenticate(c, 1, 2)
+
+	mockUserRepo.On("FindByID", 1).Return(&entity.User{ID: 1, TOTPSecret: testTOTPSecret}, nil)
+	mockUserRepo.On("EnableTOTP", 1, mock.MatchedBy(func(hashes []string) bool {
+		return len(hashes) == recoveryCodeCount
+	})).Return(nil)
+
+	if assert.NoError(t, handler.ConfirmMFA(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var body struct {
+			Data struct {
+				RecoveryCodes []string `json:"recovery_codes"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		assert.Len(t, body.Data.RecoveryCodes, recoveryCodeCount)
+	}
+	mockUserRepo.AssertExpectations(t)
+}
+
+func TestDisableMFA(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	handler, _ := newMFATestHandler(t, mockUserRepo, new(MockTokenRepository))
+
+	code, _ := helpers.GenerateTOTPCode(testTOTPSecret, time.Now())
+	req := httptest.NewRequest(http.MethodPost, "/me/2fa/disable", strings.NewReader(`{"password":"password","code":"`+code+`"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	authenticate(c, 1, 2)
+
+	password, _ := helpers.HashPassword("password")
+	enabledAt := time.Now()
+	mockUserRepo.On("FindByID", 1).Return(&entity.User{ID: 1, Password: *password, TOTPSecret: testTOTPSecret, TOTPEnabledAt: &enabledAt}, nil)
+	mockUserRepo.On("UseTOTPStep", 1, mock.AnythingOfType("int64")).Return(true, nil)
+	mockUserRepo.On("DisableTOTP", 1).Return(nil)
+
+	if assert.NoError(t, handler.DisableMFA(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	mockUserRepo.AssertExpectations(t)
+}
@@ -0,0 +1,200 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"main/entity"
+	"main/helpers"
+	"main/oidc"
+	"main/repository"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const oauthStateExpiry = 10 * time.Minute
+
+var (
+	errUnverifiedIdentityEmail = errors.New("identity email not verified")
+	errUnverifiedAccount       = errors.New("account email not verified")
+)
+
+type OAuthHandler struct {
+	auth         *AuthHandler
+	identityRepo repository.IdentityRepositoryInterface
+	providers    map[string]oidc.ProviderInterface
+}
+
+func NewOAuthHandler(auth *AuthHandler, identityRepo repository.IdentityRepositoryInterface, providers map[string]oidc.ProviderInterface) *OAuthHandler {
+	return &OAuthHandler{
+		auth:         auth,
+		identityRepo: identityRepo,
+		providers:    providers,
+	}
+}
+
+// Authorize redirects to the provider's authorization endpoint. The state, nonce and PKCE
+// verifier are kept server side until the callback.
+func (h *OAuthHandler) Authorize(echoCtx echo.Context) error {
+	providerName := echoCtx.Param("provider")
+	provider, ok := h.providers[providerName]
+	if !ok {
+		helpers.ResponseWithError(echoCtx, http.StatusNotFound, "Unknown provider")
+		return nil
+	}
+
+	secrets := make([]string, 3)
+	for i := range secrets {
+		secret, err := helpers.GenerateRandomToken(32)
+		if err != nil {
+			helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+			return nil
+		}
+		secrets[i] = secret
+	}
+	state, nonce, codeVerifier := secrets[0], secrets[1], secrets[2]
+
+	authURL, err := provider.AuthCodeURL(state, nonce, oidc.GeneratePKCEChallenge(codeVerifier))
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusBadGateway, "Provider unavailable")
+		return nil
+	}
+	if err := h.identityRepo.CreateState(echoCtx.Request().Context(), &entity.OAuthState{
+		StateHash:    helpers.HashToken(state),
+		Provider:     providerName,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+		ExpiresAt:    time.Now().Add(oauthStateExpiry),
+	}); err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+
+	return echoCtx.Redirect(http.StatusFound, authURL)
+}
+
+// Callback completes the authorization code flow and signs the user in. A new identity is linked to
+// the account with the same verified email, or a verified account with an empty profile is created.
+func (h *OAuthHandler) Callback(echoCtx echo.Context) error {
+	ctx := echoCtx.Request().Context()
+	providerName := echoCtx.Param("provider")
+	provider, ok := h.providers[providerName]
+	if !ok {
+		helpers.ResponseWithError(echoCtx, http.StatusNotFound, "Unknown provider")
+		return nil
+	}
+	if echoCtx.QueryParam("error") != "" {
+		helpers.ResponseWithError(echoCtx, http.StatusUnauthorized, "Authorization denied")
+		return nil
+	}
+	code := echoCtx.QueryParam("code")
+	stateParam := echoCtx.QueryParam("state")
+	if code == "" || stateParam == "" {
+		helpers.ResponseWithError(echoCtx, http.StatusBadRequest, "Invalid request")
+		return nil
+	}
+
+	state, err := h.identityRepo.ConsumeState(ctx, helpers.HashToken(stateParam), providerName)
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if state == nil {
+		helpers.ResponseWithError(echoCtx, http.StatusBadRequest, "Invalid or expired state")
+		return nil
+	}
+
+	identity, err := provider.Exchange(ctx, code, state.CodeVerifier, state.Nonce)
+	if err != nil {
+		log.Printf("Failed to exchange %s authorization code: %v", providerName, err)
+		helpers.ResponseWithError(echoCtx, http.StatusUnauthorized, "Social login failed")
+		return nil
+	}
+
+	user, err := h.findOrCreateUser(ctx, providerName, identity)
+	if err != nil {
+		if errors.Is(err, errUnverifiedIdentityEmail) {
+			helpers.ResponseWithError(echoCtx, http.StatusForbidden, "Email not verified by provider")
+			return nil
+		}
+		if errors.Is(err, errUnverifiedAccount) {
+			helpers.ResponseWithError(echoCtx, http.StatusConflict, "Verify the email of the existing account before using social login")
+			return nil
+		}
+		if errors.Is(err, repository.ErrDuplicateEmail) {
+			helpers.ResponseWithError(echoCtx, http.StatusConflict, "Email already registered")
+			return nil
+		}
+		log.Printf("Failed to sign in with %s: %v", providerName, err)
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+
+	h.auth.completeLogin(echoCtx, user, "")
+	return nil
+}
+
+func (h *OAuthHandler) findOrCreateUser(ctx context.Context, providerName string, identity *oidc.Identity) (*entity.User, error) {
+	linked, err := h.identityRepo.FindIdentity(ctx, providerName, identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if linked != nil {
+		return h.auth.userRepo.FindByID(ctx, int(linked.UserID))
+	}
+
+	// linking by email is only safe when the provider vouches for it
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, errUnverifiedIdentityEmail
+	}
+
+	email := helpers.NormalizeEmail(identity.Email)
+	user, err := h.auth.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		user, err = h.createUser(ctx, email, identity.Name)
+		if err != nil {
+			return nil, err
+		}
+	} else if !user.IsVerified() {
+		// whoever registered the unverified account may not own the email
+		return nil, errUnverifiedAccount
+	}
+
+	if err := h.identityRepo.CreateIdentity(ctx, &entity.UserIdentity{
+		UserID:   user.ID,
+		Provider: providerName,
+		Subject:  identity.Subject,
+		Email:    email,
+	}); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// createUser registers a verified user for a first-time social login. The random password is never
+// disclosed; the user can set one with the forgot password flow.
+func (h *OAuthHandler) createUser(ctx context.Context, email string, name string) (*entity.User, error) {
+	password, err := helpers.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	if name == "" {
+		name = email
+	}
+	user, err := h.auth.userRepo.Save(ctx, &entity.User{
+		Name:     name,
+		Email:    email,
+		Password: password,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := h.auth.userRepo.Verify(ctx, int(user.ID)); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	user.VerifiedAt = &now
+	return user, nil
+}
@@ -0,0 +1,248 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"main/entity"
+	"main/helpers"
+	"main/oidc"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockIdentityRepository struct {
+	mock.Mock
+}
+
+func (m *MockIdentityRepository) FindIdentity(_ context.Context, provider string, subject string) (*entity.UserIdentity, error) {
+	args := m.Called(provider, subject)
+	return args.Get(0).(*entity.UserIdentity), args.Error(1)
+}
+
+func (m *MockIdentityRepository) CreateIdentity(_ context.Context, identity *entity.UserIdentity) error {
+	args := m.Called(identity)
+	return args.Error(0)
+}
+
+func (m *MockIdentityRepository) CreateState(_ context.Context, state *entity.OAuthState) error {
+	args := m.Called(state)
+	return args.Error(0)
+}
+
+func (m *MockIdentityRepository) ConsumeState(_ context.Context, hash string, provider string) (*entity.OAuthState, error) {
+	args := m.Called(hash, provider)
+	return args.Get(0).(*entity.OAuthState), args.Error(1)
+}
+
+type MockProvider struct {
+	mock.Mock
+}
+
+func (m *MockProvider) AuthCodeURL(state, nonce, codeChallenge string) (string, error) {
+	args := m.Called(state, nonce, codeChallenge)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockProvider) Exchange(ctx context.Context, code, codeVerifier, nonce string) (*oidc.Identity, error) {
+	args := m.Called(ctx, code, codeVerifier, nonce)
+	return args.Get(0).(*oidc.Identity), args.Error(1)
+}
+
+func newOAuthTestHandler(t *testing.T, mockUserRepo *MockUserRepository, mockTokenRepo *MockTokenRepository, mockIdentityRepo *MockIdentityRepository, provider *MockProvider) *OAuthHandler {
+	authHandler, _ := newMFATestHandler(t, mockUserRepo, mockTokenRepo)
+	return NewOAuthHandler(authHandler, mockIdentityRepo, map[string]oidc.ProviderInterface{"google": provider})
+}
+
+func newCallbackContext(e *echo.Echo, rec *httptest.ResponseRecorder) echo.Context {
+	req := httptest.NewRequest(http.MethodGet, "/oauth/google/callback?code=code&state=state", nil)
+	c := e.NewContext(req, rec)
+	c.SetParamNames("provider")
+	c.SetParamValues("google")
+	return c
+}
+
+func TestOAuthAuthorize(t *testing.T) {
+	e := newTestEcho()
+	mockIdentityRepo := new(MockIdentityRepository)
+	provider := new(MockProvider)
+	handler := newOAuthTestHandler(t, new(MockUserRepository), new(MockTokenRepository), mockIdentityRepo, provider)
+
+	req := httptest.NewRequest(http.MethodGet, "/oauth/google/authorize", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("provider")
+	c.SetParamValues("google")
+
+	var savedState *entity.OAuthState
+	provider.On("AuthCodeURL", mock.Anything, mock.Anything, mock.Anything).Return("https://issuer.example/authorize?state=x", nil)
+	mockIdentityRepo.On("CreateState", mock.AnythingOfType("*entity.OAuthState")).Run(func(args mock.Arguments) {
+		savedState = args.Get(0).(*entity.OAuthState)
+	}).Return(nil)
+
+	if assert.NoError(t, handler.Authorize(c)) {
+		assert.Equal(t, http.StatusFound, rec.Code)
+		assert.Equal(t, "https://issuer.example/authorize?state=x", rec.Header().Get(echo.HeaderLocation))
+	}
+	call := provider.Calls[0]
+	assert.Equal(t, oidc.GeneratePKCEChallenge(savedState.CodeVerifier), call.Arguments.String(2))
+	assert.Equal(t, savedState.Nonce, call.Arguments.String(1))
+	assert.Equal(t, helpers.HashToken(call.Arguments.String(0)), savedState.StateHash)
+}
+
+func TestOAuthAuthorizeUnknownProvider(t *testing.T) {
+	e := newTestEcho()
+	handler := newOAuthTestHandler(t, new(MockUserRepository), new(MockTokenRepository), new(MockIdentityRepository), new(MockProvider))
+
+	req := httptest.NewRequest(http.MethodGet, "/oauth/unknown/authorize", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("provider")
+	c.SetParamValues("unknown")
+
+	if assert.NoError(t, handler.Authorize(c)) {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestOAuthCallbackLinkedIdentity(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	mockIdentityRepo := new(MockIdentityRepository)
+	provider := new(MockProvider)
+	handler := newOAuthTestHandler(t, mockUserRepo, mockTokenRepo, mockIdentityRepo, provider)
+	rec := httptest.NewRecorder()
+	c := newCallbackContext(e, rec)
+
+	state := &entity.OAuthState{Provider: "google", Nonce: "nonce", CodeVerifier: "verifier"}
+	mockIdentityRepo.On("ConsumeState", mock.Anything, "google").Return(state, nil)
+	provider.On("Exchange", mock.Anything, "code", "verifier", "nonce").Return(&oidc.Identity{Subject: "sub-1"}, nil)
+	mockIdentityRepo.On("FindIdentity", "google", "sub-1").Return(&entity.UserIdentity{UserID: 1}, nil)
+	mockUserRepo.On("FindByID", 1).Return(&entity.User{ID: 1, Email: "john@example.com"}, nil)
+	handler.auth.sessionRepo.(*MockSessionRepository).On("Create", mock.AnythingOfType("*entity.Session")).Return(nil)
+	mockTokenRepo.On("Create", mock.AnythingOfType("*entity.RefreshToken")).Return(nil)
+
+	if assert.NoError(t, handler.Callback(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "access_token")
+	}
+	mockIdentityRepo.AssertNotCalled(t, "CreateIdentity", mock.Anything)
+}
+
+func TestOAuthCallbackLinksVerifiedEmail(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	mockIdentityRepo := new(MockIdentityRepository)
+	provider := new(MockProvider)
+	handler := newOAuthTestHandler(t, mockUserRepo, mockTokenRepo, mockIdentityRepo, provider)
+	rec := httptest.NewRecorder()
+	c := newCallbackContext(e, rec)
+
+	verifiedAt := time.Now()
+	mockIdentityRepo.On("ConsumeState", mock.Anything, "google").Return(&entity.OAuthState{Nonce: "nonce", CodeVerifier: "verifier"}, nil)
+	provider.On("Exchange", mock.Anything, "code", "verifier", "nonce").Return(&oidc.Identity{Subject: "sub-1", Email: "John@Example.com", EmailVerified: true}, nil)
+	mockIdentityRepo.On("FindIdentity", "google", "sub-1").Return((*entity.UserIdentity)(nil), nil)
+	mockUserRepo.On("FindByEmail", "john@example.com").Return(&entity.User{ID: 1, Email: "john@example.com", VerifiedAt: &verifiedAt}, nil)
+	mockIdentityRepo.On("CreateIdentity", &entity.UserIdentity{UserID: 1, Provider: "google", Subject: "sub-1", Email: "john@example.com"}).Return(nil)
+	handler.auth.sessionRepo.(*MockSessionRepository).On("Create", mock.AnythingOfType("*entity.Session")).Return(nil)
+	mockTokenRepo.On("Create", mock.AnythingOfType("*entity.RefreshToken")).Return(nil)
+
+	if assert.NoError(t, handler.Callback(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "access_token")
+	}
+	mockIdentityRepo.AssertExpectations(t)
+	mockUserRepo.AssertNotCalled(t, "Save", mock.Anything)
+}
+
+func TestOAuthCallbackCreatesUser(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockTokenRepo := new(MockTokenRepository)
+	mockIdentityRepo := new(MockIdentityRepository)
+	provider := new(MockProvider)
+	handler := newOAuthTestHandler(t, mockUserRepo, mockTokenRepo, mockIdentityRepo, provider)
+	rec := httptest.NewRecorder()
+	c := newCallbackContext(e, rec)
+
+	mockIdentityRepo.On("ConsumeState", mock.Anything, "google").Return(&entity.OAuthState{Nonce: "nonce", CodeVerifier: "verifier"}, nil)
+	provider.On("Exchange", mock.Anything, "code", "verifier", "nonce").Return(&oidc.Identity{Subject: "sub-1", Email: "jane@example.com", EmailVerified: true, Name: "Jane"}, nil)
+	mockIdentityRepo.On("FindIdentity", "google", "sub-1").Return((*entity.UserIdentity)(nil), nil)
+	mockUserRepo.On("FindByEmail", "jane@example.com").Return(&entity.User{}, errors.New("record not found"))
+	mockUserRepo.On("Save", mock.MatchedBy(func(user *entity.User) bool {
+		return user.Name == "Jane" && user.Email == "jane@example.com" && user.Password != ""
+	})).Return(&entity.User{ID: 2, Name: "Jane", Email: "jane@example.com"}, nil)
+	mockUserRepo.On("Verify", 2).Return(nil)
+	mockIdentityRepo.On("CreateIdentity", mock.AnythingOfType("*entity.UserIdentity")).Return(nil)
+	handler.auth.sessionRepo.(*MockSessionRepository).On("Create", mock.AnythingOfType("*entity.Session")).Return(nil)
+	mockTokenRepo.On("Create", mock.AnythingOfType("*entity.RefreshToken")).Return(nil)
+
+	if assert.NoError(t, handler.Callback(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), "access_token")
+	}
+	mockUserRepo.AssertExpectations(t)
+	mockIdentityRepo.AssertExpectations(t)
+}
+
+func TestOAuthCallbackUnverifiedEmail(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockIdentityRepo := new(MockIdentityRepository)
+	provider := new(MockProvider)
+	handler := newOAuthTestHandler(t, mockUserRepo, new(MockTokenRepository), mockIdentityRepo, provider)
+	rec := httptest.NewRecorder()
+	c := newCallbackContext(e, rec)
+
+	mockIdentityRepo.On("ConsumeState", mock.Anything, "google").Return(&entity.OAuthState{Nonce: "nonce", CodeVerifier: "verifier"}, nil)
+	provider.On("Exchange", mock.Anything, "code", "verifier", "nonce").Return(&oidc.Identity{Subject: "sub-1", Email: "john@example.com"}, nil)
+	mockIdentityRepo.On("FindIdentity", "google", "sub-1").Return((*entity.UserIdentity)(nil), nil)
+
+	if assert.NoError(t, handler.Callback(c)) {
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+	}
+	mockUserRepo.AssertNotCalled(t, "FindByEmail", mock.Anything)
+}
+
+func TestOAuthCallbackUnverifiedAccount(t *testing.T) {
+	e := newTestEcho()
+	mockUserRepo := new(MockUserRepository)
+	mockIdentityRepo := new(MockIdentityRepository)
+	provider := new(MockProvider)
+	handler := newOAuthTestHandler(t, mockUserRepo, new(MockTokenRepository), mockIdentityRepo, provider)
+	rec := httptest.NewRecorder()
+	c := newCallbackContext(e, rec)
+
+	mockIdentityRepo.On("ConsumeState", mock.Anything, "google").Return(&entity.OAuthState{Nonce: "nonce", CodeVerifier: "verifier"}, nil)
+	provider.On("Exchange", mock.Anything, "code", "verifier", "nonce").Return(&oidc.Identity{Subject: "sub-1", Email: "john@example.com", EmailVerified: true}, nil)
+	mockIdentityRepo.On("FindIdentity", "google", "sub-1").Return((*entity.UserIdentity)(nil), nil)
+	mockUserRepo.On("FindByEmail", "john@example.com").Return(&entity.User{ID: 1, Email: "john@example.com"}, nil)
+
+	if assert.NoError(t, handler.Callback(c)) {
+		assert.Equal(t, http.StatusConflict, rec.Code)
+	}
+	mockIdentityRepo.AssertNotCalled(t, "CreateIdentity", mock.Anything)
+}
+
+func TestOAuthCallbackInvalidState(t *testing.T) {
+	e := newTestEcho()
+	mockIdentityRepo := new(MockIdentityRepository)
+	provider := new(MockProvider)
+	handler := newOAuthTestHandler(t, new(MockUserRepository), new(MockTokenRepository), mockIdentityRepo, provider)
+	rec := httptest.NewRecorder()
+	c := newCallbackContext(e, rec)
+
+	mockIdentityRepo.On("ConsumeState", mock.Anything, "google").Return((*entity.OAuthState)(nil), nil)
+
+	if assert.NoError(t, handler.Callback(c)) {
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+	}
+	provider.AssertNotCalled(t, "Exchange", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,235 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"main/config"
+	"main/entity"
+	"main/helpers"
+	"main/repository"
+	"main/storage"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	thumbnailSize = 320
+	fullSize      = 1280
+)
+
+var allowedPhotoTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+}
+
+type PhotoOrderRequest struct {
+	PhotoIDs []uint `json:"photo_ids" validate:"required,min=1,dive,required"`
+}
+
+type PhotoHandler struct {
+	photoRepo repository.PhotoRepositoryInterface
+	store     storage.BlobStore
+	cfg       *config.Photo
+}
+
+func NewPhotoHandler(photoRepo repository.PhotoRepositoryInterface, store storage.BlobStore, cfg *config.Photo) *PhotoHandler {
+	return &PhotoHandler{
+		photoRepo: photoRepo,
+		store:     store,
+		cfg:       cfg,
+	}
+}
+
+// ListPhotos returns the gallery of the authenticated user in display order.
+func (h *PhotoHandler) ListPhotos(c echo.Context) error {
+	profileId := helpers.CurrentPrincipal(c).ProfileID
+	photos, err := h.photoRepo.ListByProfileID(c.Request().Context(), profileId)
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if photos == nil {
+		photos = []entity.ProfilePhoto{}
+	}
+	helpers.ResponseWithSuccess(c, http.StatusOK, photos)
+	return nil
+}
+
+// UploadPhoto adds the JPEG or PNG image sent in the photo form field to the end of the gallery.
+// The image is stored as a thumbnail and a full size JPEG; the original file is not kept.
+func (h *PhotoHandler) UploadPhoto(c echo.Context) error {
+	profileId := helpers.CurrentPrincipal(c).ProfileID
+
+	fileHeader, err := c.FormFile("photo")
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusBadRequest, "Photo is required")
+		return nil
+	}
+	if fileHeader.Size > h.cfg.MaxSize {
+		helpers.ResponseWithError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("Photo must be at most %d bytes", h.cfg.MaxSize))
+		return nil
+	}
+	if !allowedPhotoTypes[fileHeader.Header.Get("Content-Type")] {
+		helpers.ResponseWithError(c, http.StatusUnsupportedMediaType, "Photo must be a JPEG or PNG image")
+		return nil
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusBadRequest, "Invalid request")
+		return nil
+	}
+	defer file.Close()
+	data, err := io.ReadAll(io.LimitReader(file, h.cfg.MaxSize+1))
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusBadRequest, "Invalid request")
+		return nil
+	}
+	if int64(len(data)) > h.cfg.MaxSize {
+		helpers.ResponseWithError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("Photo must be at most %d bytes", h.cfg.MaxSize))
+		return nil
+	}
+	// the declared content type is only a hint, the content decides
+	if !allowedPhotoTypes[http.DetectContentType(data)] {
+		helpers.ResponseWithError(c, http.StatusUnsupportedMediaType, "Photo must be a JPEG or PNG image")
+		return nil
+	}
+
+	photos, err := h.photoRepo.ListByProfileID(c.Request().Context(), profileId)
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if len(photos) >= h.cfg.MaxCount {
+		helpers.ResponseWithError(c, http.StatusConflict, fmt.Sprintf("A profile can have at most %d photos", h.cfg.MaxCount))
+		return nil
+	}
+
+	img, err := helpers.DecodeImage(data)
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusUnprocessableEntity, "Photo could not be read")
+		return nil
+	}
+	thumbnail, err := helpers.ResizeToJPEG(img, thumbnailSize)
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	full, err := helpers.ResizeToJPEG(img, fullSize)
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+
+	name, err := helpers.GenerateRandomToken(16)
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	photo := &entity.ProfilePhoto{
+		ProfileID:    uint(profileId),
+		ThumbnailKey: fmt.Sprintf("photos/%d/%s-thumb.jpg", profileId, name),
+		FullKey:      fmt.Sprintf("photos/%d/%s-full.jpg", profileId, name),
+	}
+	photo.ThumbnailURL = h.store.URL(photo.ThumbnailKey)
+	photo.FullURL = h.store.URL(photo.FullKey)
+
+	ctx := c.Request().Context()
+	if err := h.store.Put(ctx, photo.ThumbnailKey, bytes.NewReader(thumbnail), "image/jpeg"); err != nil {
+		log.Printf("Failed to store photo: %v", err)
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if err := h.store.Put(ctx, photo.FullKey, bytes.NewReader(full), "image/jpeg"); err != nil {
+		log.Printf("Failed to store photo: %v", err)
+		h.deleteBlobs(photo)
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+
+	if err := h.photoRepo.Create(c.Request().Context(), photo, h.cfg.MaxCount); err != nil {
+		h.deleteBlobs(photo)
+		if errors.Is(err, repository.ErrPhotoLimitReached) {
+			helpers.ResponseWithError(c, http.StatusConflict, fmt.Sprintf("A profile can have at most %d photos", h.cfg.MaxCount))
+			return nil
+		}
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+
+	helpers.ResponseWithSuccess(c, http.StatusCreated, photo)
+	return nil
+}
+
+// ReorderPhotos sets the display order of the gallery. Every photo must be listed exactly once.
+func (h *PhotoHandler) ReorderPhotos(c echo.Context) error {
+	var req PhotoOrderRequest
+	if err := c.Bind(&req); err != nil {
+		helpers.ResponseWithError(c, http.StatusBadRequest, "Invalid request")
+		return nil
+	}
+	if err := c.Validate(&req); err != nil {
+		helpers.ResponseWithValidationError(c, err)
+		return nil
+	}
+
+	profileId := helpers.CurrentPrincipal(c).ProfileID
+	if err := h.photoRepo.Reorder(c.Request().Context(), profileId, req.PhotoIDs); err != nil {
+		if errors.Is(err, repository.ErrInvalidPhotoOrder) {
+			helpers.ResponseWithError(c, http.StatusBadRequest, "Photo order must list every photo exactly once")
+			return nil
+		}
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	return h.ListPhotos(c)
+}
+
+// SetPrimaryPhoto makes the photo the one shown first and used as the profile picture.
+func (h *PhotoHandler) SetPrimaryPhoto(c echo.Context) error {
+	profileId := helpers.CurrentPrincipal(c).ProfileID
+	id := helpers.ConvertStringToInt(c.Param("id"))
+	found, err := h.photoRepo.SetPrimary(c.Request().Context(), profileId, id)
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if !found {
+		helpers.ResponseWithError(c, http.StatusNotFound, "Photo not found")
+		return nil
+	}
+	return h.ListPhotos(c)
+}
+
+// DeletePhoto removes the photo from the gallery and the blob store.
+func (h *PhotoHandler) DeletePhoto(c echo.Context) error {
+	profileId := helpers.CurrentPrincipal(c).ProfileID
+	id := helpers.ConvertStringToInt(c.Param("id"))
+	photo, err := h.photoRepo.Delete(c.Request().Context(), profileId, id)
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if photo == nil {
+		helpers.ResponseWithError(c, http.StatusNotFound, "Photo not found")
+		return nil
+	}
+	h.deleteBlobs(photo)
+
+	helpers.ResponseWithSuccess(c, http.StatusOK, map[string]interface{}{"message": "Photo deleted"})
+	return nil
+}
+
+// deleteBlobs removes both variants of a photo. The request may already be cancelled, so a fresh
+// context is used, and failures are only logged.
+func (h *PhotoHandler) deleteBlobs(photo *entity.ProfilePhoto) {
+	for _, key := range []string{photo.ThumbnailKey, photo.FullKey} {
+		if err := h.store.Delete(context.Background(), key); err != nil {
+			log.Printf("Failed to delete blob %s: %v", key, err)
+		}
+	}
+}
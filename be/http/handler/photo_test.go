@@ -0,0 +1,211 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"main/config"
+	"main/entity"
+	"main/repository"
+	"main/storage"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPhotoRepository struct {
+	mock.Mock
+}
+
+func (m *MockPhotoRepository) ListByProfileID(_ context.Context, profileID int) ([]entity.ProfilePhoto, error) {
+	args := m.Called(profileID)
+	return args.Get(0).([]entity.ProfilePhoto), args.Error(1)
+}
+
+func (m *MockPhotoRepository) Create(_ context.Context, photo *entity.ProfilePhoto, maxCount int) error {
+	args := m.Called(photo, maxCount)
+	return args.Error(0)
+}
+
+func (m *MockPhotoRepository) Delete(_ context.Context, profileID int, id int) (*entity.ProfilePhoto, error) {
+	args := m.Called(profileID, id)
+	return args.Get(0).(*entity.ProfilePhoto), args.Error(1)
+}
+
+func (m *MockPhotoRepository) Reorder(_ context.Context, profileID int, ids []uint) error {
+	args := m.Called(profileID, ids)
+	return args.Error(0)
+}
+
+func (m *MockPhotoRepository) SetPrimary(_ context.Context, profileID int, id int) (bool, error) {
+	args := m.Called(profileID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+var testPhotoConfig = &config.Photo{MaxSize: 1 << 20, MaxCount: 6}
+
+func newTestStore(t *testing.T) storage.BlobStore {
+	store, err := storage.NewLocalStore(t.TempDir(), "http://localhost:7000/media")
+	assert.NoError(t, err)
+	return store
+}
+
+func testPNG(t *testing.T) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 600, 400))))
+	return buf.Bytes()
+}
+
+func newUploadContext(t *testing.T, contentType string, data []byte) (echo.Context, *httptest.ResponseRecorder) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", `form-data; name="photo"; filename="photo.png"`)
+	header.Set("Content-Type", contentType)
+	part, err := writer.CreatePart(header)
+	assert.NoError(t, err)
+	_, _ = part.Write(data)
+	assert.NoError(t, writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/me/photos", &body)
+	req.Header.Set(echo.HeaderContentType, writer.FormDataContentType())
+	rec := httptest.NewRecorder()
+	c := newTestEcho().NewContext(req, rec)
+	authenticate(c, 70, 7)
+	return c, rec
+}
+
+func TestUploadPhoto(t *testing.T) {
+	c, rec := newUploadContext(t, "image/png", testPNG(t))
+	store := newTestStore(t)
+	photoRepo := new(MockPhotoRepository)
+	photoRepo.On("ListByProfileID", 7).Return([]entity.ProfilePhoto{}, nil)
+	var created *entity.ProfilePhoto
+	photoRepo.On("Create", mock.AnythingOfType("*entity.ProfilePhoto"), 6).Run(func(args mock.Arguments) {
+		created = args.Get(0).(*entity.ProfilePhoto)
+	}).Return(nil)
+
+	err := NewPhotoHandler(photoRepo, store, testPhotoConfig).UploadPhoto(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, uint(7), created.ProfileID)
+	assert.True(t, strings.HasPrefix(created.FullKey, "photos/7/"))
+	assert.Equal(t, "http://localhost:7000/media/"+created.ThumbnailKey, created.ThumbnailURL)
+	for _, key := range []string{created.ThumbnailKey, created.FullKey} {
+		r, err := store.Get(context.Background(), key)
+		assert.NoError(t, err)
+		r.Close()
+	}
+	assert.NotContains(t, rec.Body.String(), "thumbnail_key")
+}
+
+func TestUploadPhotoRejectsContent(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		data        []byte
+		code        int
+	}{
+		{"declared type not allowed", "image/gif", []byte("GIF89a"), http.StatusUnsupportedMediaType},
+		{"content is not an image", "image/png", []byte("<html>not a photo</html>"), http.StatusUnsupportedMediaType},
+		{"corrupt image", "image/png", []byte("\x89PNG\r\n\x1a\n broken"), http.StatusUnprocessableEntity},
+		{"too large", "image/png", bytes.Repeat([]byte("a"), int(testPhotoConfig.MaxSize)+1), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, rec := newUploadContext(t, tt.contentType, tt.data)
+			photoRepo := new(MockPhotoRepository)
+			photoRepo.On("ListByProfileID", 7).Return([]entity.ProfilePhoto{}, nil)
+
+			err := NewPhotoHandler(photoRepo, newTestStore(t), testPhotoConfig).UploadPhoto(c)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.code, rec.Code)
+			photoRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestUploadPhotoLimitReached(t *testing.T) {
+	c, rec := newUploadContext(t, "image/png", testPNG(t))
+	store := newTestStore(t)
+	photoRepo := new(MockPhotoRepository)
+	photoRepo.On("ListByProfileID", 7).Return([]entity.ProfilePhoto{}, nil)
+	// another upload filled the gallery in the meantime
+	var created *entity.ProfilePhoto
+	photoRepo.On("Create", mock.AnythingOfType("*entity.ProfilePhoto"), 6).Run(func(args mock.Arguments) {
+		created = args.Get(0).(*entity.ProfilePhoto)
+	}).Return(repository.ErrPhotoLimitReached)
+
+	err := NewPhotoHandler(photoRepo, store, testPhotoConfig).UploadPhoto(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	_, err = store.Get(context.Background(), created.FullKey)
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
+
+func TestReorderPhotosInvalid(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "/me/photos/order", strings.NewReader(`{"photo_ids": [1, 1]}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := newTestEcho().NewContext(req, rec)
+	authenticate(c, 70, 7)
+
+	photoRepo := new(MockPhotoRepository)
+	photoRepo.On("Reorder", 7, []uint{1, 1}).Return(repository.ErrInvalidPhotoOrder)
+
+	err := NewPhotoHandler(photoRepo, newTestStore(t), testPhotoConfig).ReorderPhotos(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestSetPrimaryPhotoNotFound(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPut, "/me/photos/3/primary", nil)
+	rec := httptest.NewRecorder()
+	c := newTestEcho().NewContext(req, rec)
+	authenticate(c, 70, 7)
+	c.SetParamNames("id")
+	c.SetParamValues("3")
+
+	photoRepo := new(MockPhotoRepository)
+	photoRepo.On("SetPrimary", 7, 3).Return(false, nil)
+
+	err := NewPhotoHandler(photoRepo, newTestStore(t), testPhotoConfig).SetPrimaryPhoto(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestDeletePhoto(t *testing.T) {
+	req := httptest.NewRequest(http.MethodDelete, "/me/photos/3", nil)
+	rec := httptest.NewRecorder()
+	c := newTestEcho().NewContext(req, rec)
+	authenticate(c, 70, 7)
+	c.SetParamNames("id")
+	c.SetParamValues("3")
+
+	store := newTestStore(t)
+	for _, key := range []string{"photos/7/a-thumb.jpg", "photos/7/a-full.jpg"} {
+		assert.NoError(t, store.Put(context.Background(), key, strings.NewReader("photo"), "image/jpeg"))
+	}
+	photoRepo := new(MockPhotoRepository)
+	photoRepo.On("Delete", 7, 3).Return(&entity.ProfilePhoto{ID: 3, ProfileID: 7, ThumbnailKey: "photos/7/a-thumb.jpg", FullKey: "photos/7/a-full.jpg"}, nil)
+
+	err := NewPhotoHandler(photoRepo, store, testPhotoConfig).DeletePhoto(c)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	_, err = store.Get(context.Background(), "photos/7/a-full.jpg")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+}
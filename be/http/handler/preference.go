@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"main/entity"
+	"main/helpers"
+	"main/repository"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type PreferenceRequest struct {
+	MinAge        int      `json:"min_age" validate:"required,min=18,max=99"`
+	MaxAge        int      `json:"max_age" validate:"required,gtefield=MinAge,max=99"`
+	Genders       []string `json:"genders" validate:"max=3,dive,oneof=male female non_binary"`
+	MaxDistanceKm *int     `json:"max_distance_km" validate:"omitempty,min=1,max=500"`
+	OnlyWithPhoto bool     `json:"only_with_photo"`
+}
+
+type PreferenceHandler struct {
+	preferenceRepo repository.PreferenceRepositoryInterface
+}
+
+func NewPreferenceHandler(preferenceRepo repository.PreferenceRepositoryInterface) *PreferenceHandler {
+	return &PreferenceHandler{
+		preferenceRepo: preferenceRepo,
+	}
+}
+
+// GetPreferences returns the discovery preferences of the authenticated user, or the defaults when none are set.
+func (h *PreferenceHandler) GetPreferences(c echo.Context) error {
+	userId := helpers.CurrentPrincipal(c).UserID
+	preference, err := h.preferenceRepo.FindByUserID(c.Request().Context(), userId)
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if preference == nil {
+		preference = entity.DefaultDiscoveryPreference(uint(userId))
+	}
+	helpers.ResponseWithSuccess(c, http.StatusOK, preference)
+	return nil
+}
+
+// UpdatePreferences replaces the discovery preferences of the authenticated user.
+func (h *PreferenceHandler) UpdatePreferences(c echo.Context) error {
+	var req PreferenceRequest
+	if err := c.Bind(&req); err != nil {
+		helpers.ResponseWithError(c, http.StatusBadRequest, "Invalid request")
+		return nil
+	}
+	if err := c.Validate(&req); err != nil {
+		helpers.ResponseWithValidationError(c, err)
+		return nil
+	}
+
+	userId := helpers.CurrentPrincipal(c).UserID
+	preference := &entity.DiscoveryPreference{
+		UserID:        uint(userId),
+		MinAge:        req.MinAge,
+		MaxAge:        req.MaxAge,
+		Genders:       uniqueTags(req.Genders),
+		MaxDistanceKm: req.MaxDistanceKm,
+		OnlyWithPhoto: req.OnlyWithPhoto,
+	}
+	if err := h.preferenceRepo.Save(c.Request().Context(), preference); err != nil {
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+
+	helpers.ResponseWithSuccess(c, http.StatusOK, preference)
+	return nil
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"main/entity"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPreferenceRepository struct {
+	mock.Mock
+}
+
+func (m *MockPreferenceRepository) FindByUserID(_ context.Context, userID int) (*entity.DiscoveryPreference, error) {
+	args := m.Called(userID)
+	return args.Get(0).(*entity.DiscoveryPreference), args.Error(1)
+}
+
+func (m *MockPreferenceRepository) Save(_ context.Context, preference *entity.DiscoveryPreference) error {
+	args := m.Called(preference)
+	return args.Error(0)
+}
+
+func TestGetPreferencesDefaults(t *testing.T) {
+	e := newTestEcho()
+	req := httptest.NewRequest(http.MethodGet, "/me/preferences", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	authenticate(c, 1, 2)
+
+	preferenceRepo := new(MockPreferenceRepository)
+	preferenceRepo.On("FindByUserID", 1).Return((*entity.DiscoveryPreference)(nil), nil)
+
+	err := NewPreferenceHandler(preferenceRepo).GetPreferences(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"min_age":18`)
+	assert.Contains(t, rec.Body.String(), `"max_age":99`)
+	assert.Contains(t, rec.Body.String(), `"genders":[]`)
+}
+
+func TestUpdatePreferences(t *testing.T) {
+	e := newTestEcho()
+	payload := `{"min_age": 25, "max_age": 35, "genders": ["female", "female"], "max_distance_km": 50, "only_with_photo": true}`
+	req := httptest.NewRequest(http.MethodPut, "/me/preferences", strings.NewReader(payload))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	authenticate(c, 1, 2)
+
+	preferenceRepo := new(MockPreferenceRepository)
+	preferenceRepo.On("Save", mock.MatchedBy(func(p *entity.DiscoveryPreference) bool {
+		return p.UserID == 1 && p.MinAge == 25 && p.MaxAge == 35 &&
+			assert.ObjectsAreEqual([]string{"female"}, p.Genders) &&
+			*p.MaxDistanceKm == 50 && p.OnlyWithPhoto
+	})).Return(nil)
+
+	err := NewPreferenceHandler(preferenceRepo).UpdatePreferences(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	preferenceRepo.AssertExpectations(t)
+}
+
+func TestUpdatePreferencesValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		field   string
+	}{
+		{"under minimum age", `{"min_age": 16, "max_age": 30}`, "min_age"},
+		{"inverted range", `{"min_age": 30, "max_age": 25}`, "max_age"},
+		{"unknown gender", `{"min_age": 18, "max_age": 30, "genders": ["robot"]}`, "genders[0]"},
+		{"distance out of range", `{"min_age": 18, "max_age": 30, "max_distance_km": 0}`, "max_distance_km"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEcho()
+			req := httptest.NewRequest(http.MethodPut, "/me/preferences", strings.NewReader(tt.payload))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			authenticate(c, 1, 2)
+
+			preferenceRepo := new(MockPreferenceRepository)
+			err := NewPreferenceHandler(preferenceRepo).UpdatePreferences(c)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"`+tt.field+`"`)
+			preferenceRepo.AssertNotCalled(t, "Save", mock.Anything)
+		})
+	}
+}
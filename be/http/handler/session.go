@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"main/entity"
+	"main/helpers"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type SessionResponse struct {
+	entity.Session
+	Current bool `json:"current"`
+}
+
+// ListSessions returns the active sessions of the authenticated user and marks the one making the request.
+func (h *AuthHandler) ListSessions(echoCtx echo.Context) error {
+	principal := helpers.CurrentPrincipal(echoCtx)
+	sessions, err := h.sessionRepo.ListActive(echoCtx.Request().Context(), principal.UserID)
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+
+	responseData := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		responseData = append(responseData, SessionResponse{
+			Session: session,
+			Current: session.ID == principal.SessionID,
+		})
+	}
+	helpers.ResponseWithSuccess(echoCtx, http.StatusOK, responseData)
+	return nil
+}
+
+// RevokeSession signs a device out. Its refresh tokens are revoked and its access tokens are
+// rejected from the next request on.
+func (h *AuthHandler) RevokeSession(echoCtx echo.Context) error {
+	userId := helpers.CurrentPrincipal(echoCtx).UserID
+	revoked, err := h.sessionRepo.Revoke(echoCtx.Request().Context(), userId, echoCtx.Param("id"))
+	if err != nil {
+		helpers.ResponseWithError(echoCtx, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if !revoked {
+		helpers.ResponseWithError(echoCtx, http.StatusNotFound, "Session not found")
+		return nil
+	}
+
+	helpers.ResponseWithSuccess(echoCtx, http.StatusOK, map[string]interface{}{"message": "Session revoked"})
+	return nil
+}
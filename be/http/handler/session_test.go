@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"main/entity"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockSessionRepository struct {
+	mock.Mock
+}
+
+func (m *MockSessionRepository) Create(_ context.Context, session *entity.Session) error {
+	args := m.Called(session)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) FindByID(_ context.Context, id string) (*entity.Session, error) {
+	args := m.Called(id)
+	return args.Get(0).(*entity.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) ListActive(_ context.Context, userID int) ([]entity.Session, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]entity.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) Touch(_ context.Context, id string, ip string, seenAt time.Time) error {
+	args := m.Called(id, ip, seenAt)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) Revoke(_ context.Context, userID int, id string) (bool, error) {
+	args := m.Called(userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func TestListSessions(t *testing.T) {
+	e := newTestEcho()
+	mockSessionRepo := new(MockSessionRepository)
+	handler := NewAuthHandler(new(MockUserRepository), new(MockTokenRepository), mockSessionRepo, nil, newTestKeySet(t), new(MockMailer), nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/me/sessions", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	authenticate(c, 1, 2)
+
+	mockSessionRepo.On("ListActive", 1).Return([]entity.Session{
+		{ID: "current", UserID: 1, DeviceName: "Phone", LastSeenAt: time.Now()},
+		{ID: "other", UserID: 1, DeviceName: "Laptop", LastSeenAt: time.Now().Add(-time.Hour)},
+	}, nil)
+
+	if assert.NoError(t, handler.ListSessions(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"id":"current","device_name":"Phone"`)
+		assert.Contains(t, rec.Body.String(), `"current":true`)
+		assert.Contains(t, rec.Body.String(), `"current":false`)
+	}
+}
+
+func TestRevokeSession(t *testing.T) {
+	e := newTestEcho()
+	mockSessionRepo := new(MockSessionRepository)
+	handler := NewAuthHandler(new(MockUserRepository), new(MockTokenRepository), mockSessionRepo, nil, newTestKeySet(t), new(MockMailer), nil)
+
+	req := httptest.NewRequest(http.MethodDelete, "/me/sessions/other", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("other")
+	authenticate(c, 1, 2)
+
+	mockSessionRepo.On("Revoke", 1, "other").Return(true, nil)
+
+	if assert.NoError(t, handler.RevokeSession(c)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+	mockSessionRepo.AssertExpectations(t)
+}
+
+func TestRevokeSessionNotFound(t *testing.T) {
+	e := newTestEcho()
+	mockSessionRepo := new(MockSessionRepository)
+	handler := NewAuthHandler(new(MockUserRepository), new(MockTokenRepository), mockSessionRepo, nil, newTestKeySet(t), new(MockMailer), nil)
+
+	req := httptest.NewRequest(http.MethodDelete, "/me/sessions/someone-else", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	c.SetParamNames("id")
+	c.SetParamValues("someone-else")
+	authenticate(c, 1, 2)
+
+	mockSessionRepo.On("Revoke", 1, "someone-else").Return(false, nil)
+
+	if assert.NoError(t, handler.RevokeSession(c)) {
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	}
+}
@@ -1,52 +1,105 @@
 package handler
 
 import (
+	"main/config"
+	"main/entity"
 	"main/helpers"
 	"main/repository"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 )
 
+const minimumAge = 18
+
 type ProfileRequest struct {
-	Description *string `json:"description,omitempty"`
-	Picture     *string `json:"picture,omitempty"`
+	Description  *string   `json:"description,omitempty"`
+	Birthdate    *string   `json:"birthdate,omitempty" validate:"omitempty,datetime=2006-01-02"`
+	Gender       *string   `json:"gender,omitempty" validate:"omitempty,oneof=male female non_binary"`
+	InterestedIn *[]string `json:"interested_in,omitempty" validate:"omitempty,max=3,dive,oneof=male female non_binary"`
+	City         *string   `json:"city,omitempty" validate:"omitempty,max=100"`
+	Latitude     *float64  `json:"latitude,omitempty" validate:"required_with=Longitude,omitempty,latitude"`
+	Longitude    *float64  `json:"longitude,omitempty" validate:"required_with=Latitude,omitempty,longitude"`
+	HeightCm     *int      `json:"height_cm,omitempty" validate:"omitempty,min=100,max=250"`
+	Job          *string   `json:"job,omitempty" validate:"omitempty,max=100"`
+	Interests    *[]string `json:"interests,omitempty" validate:"omitempty,max=10,dive,min=1,max=30"`
+}
+
+type LocationRequest struct {
+	Latitude  *float64 `json:"latitude" validate:"required,latitude"`
+	Longitude *float64 `json:"longitude" validate:"required,longitude"`
+}
+
+// MeResponse is the current user with the superlikes they can still send today.
+type MeResponse struct {
+	*entity.User
+	Superlikes *entity.SuperlikeQuota `json:"superlikes"`
 }
 
 type UserHandler struct {
 	userRepository    repository.UserRepositoryInterface
 	profileRepository repository.ProfileRepositoryInterface
+	matchRepository   repository.MatchRepositoryInterface
+	cfg               *config.Discovery
 }
 
-func NewUserHandler(userRepository repository.UserRepositoryInterface, profileRepository repository.ProfileRepositoryInterface) *UserHandler {
+func NewUserHandler(userRepository repository.UserRepositoryInterface, profileRepository repository.ProfileRepositoryInterface, matchRepository repository.MatchRepositoryInterface, cfg *config.Discovery) *UserHandler {
 	return &UserHandler{
 		userRepository,
 		profileRepository,
+		matchRepository,
+		cfg,
 	}
 }
 
 func (h *UserHandler) Me(c echo.Context) error {
-	userId := c.Get("user_id").(int)
-	user, err := h.userRepository.FindByID(userId)
+	principal := helpers.CurrentPrincipal(c)
+	user, err := h.userRepository.FindByID(c.Request().Context(), principal.UserID)
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	quota, err := h.matchRepository.SuperlikeQuota(c.Request().Context(), principal.UserID, principal.ProfileID, h.cfg.SuperlikeDailyLimit, h.cfg.SuperlikePremiumDailyLimit)
 	if err != nil {
 		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
 		return nil
 	}
 
-	helpers.ResponseWithSuccess(c, http.StatusOK, user)
+	helpers.ResponseWithSuccess(c, http.StatusOK, MeResponse{User: user, Superlikes: quota})
 	return nil
 }
 
 func (h *UserHandler) UpdateProfile(c echo.Context) error {
-	userId := c.Get("user_id").(int)
+	userId := helpers.CurrentPrincipal(c).UserID
 
 	var profileRequest ProfileRequest
 	if err := c.Bind(&profileRequest); err != nil {
 		helpers.ResponseWithError(c, http.StatusBadRequest, "Invalid request")
 		return nil
 	}
+	if err := c.Validate(&profileRequest); err != nil {
+		helpers.ResponseWithValidationError(c, err)
+		return nil
+	}
+	if profileRequest.isEmpty() {
+		helpers.ResponseWithError(c, http.StatusBadRequest, "Nothing to update")
+		return nil
+	}
 
-	profile, err := h.profileRepository.FindByUserID(userId)
+	var birthdate time.Time
+	if profileRequest.Birthdate != nil {
+		// the format was checked by the validator
+		birthdate, _ = time.Parse("2006-01-02", *profileRequest.Birthdate)
+		age := (&entity.Profile{Birthdate: &birthdate}).AgeAt(time.Now())
+		if *age < minimumAge {
+			helpers.ResponseWithValidationError(c, helpers.ValidationErrors{"birthdate": "must be at least 18 years ago"})
+			return nil
+		}
+	}
+
+	profile, err := h.profileRepository.FindByUserID(c.Request().Context(), userId)
 	if err != nil {
 		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
 		return nil
@@ -56,18 +109,37 @@ func (h *UserHandler) UpdateProfile(c echo.Context) error {
 		return nil
 	}
 
-	if profileRequest.Description == nil && profileRequest.Picture == nil {
-		helpers.ResponseWithError(c, http.StatusBadRequest, "Nothing to update")
-		return nil
-	}
-
 	if profileRequest.Description != nil {
 		profile.Description = *profileRequest.Description
 	}
-	if profileRequest.Picture != nil {
-		profile.Picture = *profileRequest.Picture
+	if profileRequest.Birthdate != nil {
+		profile.Birthdate = &birthdate
+	}
+	if profileRequest.Gender != nil {
+		profile.Gender = *profileRequest.Gender
 	}
-	_, err = h.profileRepository.Save(profile)
+	if profileRequest.InterestedIn != nil {
+		profile.InterestedIn = uniqueTags(*profileRequest.InterestedIn)
+	}
+	if profileRequest.City != nil {
+		profile.City = strings.TrimSpace(*profileRequest.City)
+	}
+	if profileRequest.Latitude != nil {
+		now := time.Now()
+		profile.Latitude = profileRequest.Latitude
+		profile.Longitude = profileRequest.Longitude
+		profile.LocationUpdatedAt = &now
+	}
+	if profileRequest.HeightCm != nil {
+		profile.HeightCm = profileRequest.HeightCm
+	}
+	if profileRequest.Job != nil {
+		profile.Job = strings.TrimSpace(*profileRequest.Job)
+	}
+	if profileRequest.Interests != nil {
+		profile.Interests = uniqueTags(*profileRequest.Interests)
+	}
+	_, err = h.profileRepository.Save(c.Request().Context(), profile)
 	if err != nil {
 		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
 		return nil
@@ -77,9 +149,69 @@ func (h *UserHandler) UpdateProfile(c echo.Context) error {
 	return nil
 }
 
+// UpdateLocation stores the last known location of the authenticated user, which limits discovery
+// to people nearby. The coordinates are never shown to other users.
+func (h *UserHandler) UpdateLocation(c echo.Context) error {
+	userId := helpers.CurrentPrincipal(c).UserID
+
+	var req LocationRequest
+	if err := c.Bind(&req); err != nil {
+		helpers.ResponseWithError(c, http.StatusBadRequest, "Invalid request")
+		return nil
+	}
+	if err := c.Validate(&req); err != nil {
+		helpers.ResponseWithValidationError(c, err)
+		return nil
+	}
+
+	profile, err := h.profileRepository.FindByUserID(c.Request().Context(), userId)
+	if err != nil {
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+	if profile == nil {
+		helpers.ResponseWithError(c, http.StatusNotFound, "Profile not found")
+		return nil
+	}
+
+	now := time.Now()
+	profile.Latitude = req.Latitude
+	profile.Longitude = req.Longitude
+	profile.LocationUpdatedAt = &now
+	if _, err := h.profileRepository.Save(c.Request().Context(), profile); err != nil {
+		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+		return nil
+	}
+
+	helpers.ResponseWithSuccess(c, http.StatusOK, map[string]interface{}{"message": "Location updated"})
+	return nil
+}
+
+func (r *ProfileRequest) isEmpty() bool {
+	return r.Description == nil && r.Birthdate == nil && r.Gender == nil &&
+		r.InterestedIn == nil && r.City == nil && r.Latitude == nil && r.HeightCm == nil &&
+		r.Job == nil && r.Interests == nil
+}
+
+// uniqueTags lowercases and trims the tags and drops blanks and duplicates, keeping the first occurrence.
+func uniqueTags(tags []string) []string {
+	result := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		result = append(result, tag)
+	}
+	return result
+}
+
 func (h *UserHandler) PurchasePremium(c echo.Context) error {
+	userId := helpers.CurrentPrincipal(c).UserID
 
-	checkActiveSubscription, err := h.userRepository.CheckSubscription(c)
+	checkActiveSubscription, err := h.userRepository.CheckSubscription(c.Request().Context(), userId)
 	if err != nil {
 		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
 		return nil
@@ -90,7 +222,7 @@ func (h *UserHandler) PurchasePremium(c echo.Context) error {
 		return nil
 	}
 
-	user, err := h.userRepository.Subscribe(c)
+	user, err := h.userRepository.Subscribe(c.Request().Context(), userId)
 	if err != nil {
 		helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
 		return nil
@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"context"
+	"main/config"
 	"main/entity"
 	"net/http"
 	"net/http/httptest"
@@ -18,74 +20,127 @@ type MockUserRepository struct {
 }
 
 // FindByEmail implements repository.UserRepositoryInterface.
-func (m *MockUserRepository) FindByEmail(email string) (*entity.User, error) {
+func (m *MockUserRepository) FindByEmail(_ context.Context, email string) (*entity.User, error) {
 	args := m.Called(email)
 	return args.Get(0).(*entity.User), args.Error(1)
 }
 
 // Save implements repository.UserRepositoryInterface.
-func (m *MockUserRepository) Save(user *entity.User) (*entity.User, error) {
+func (m *MockUserRepository) Save(_ context.Context, user *entity.User) (*entity.User, error) {
 	args := m.Called(user)
 	return args.Get(0).(*entity.User), args.Error(1)
 }
 
 // Update implements repository.UserRepositoryInterface.
-func (m *MockUserRepository) Update(user *entity.User) (*entity.User, error) {
+func (m *MockUserRepository) Update(_ context.Context, user *entity.User) (*entity.User, error) {
 	args := m.Called(user)
 	return args.Get(0).(*entity.User), args.Error(1)
 }
 
-func (m *MockUserRepository) FindByID(id int) (*entity.User, error) {
+func (m *MockUserRepository) FindByID(_ context.Context, id int) (*entity.User, error) {
 	args := m.Called(id)
 	return args.Get(0).(*entity.User), args.Error(1)
 }
 
-func (m *MockUserRepository) CheckSubscription(c echo.Context) (bool, error) {
-	args := m.Called(c)
+func (m *MockUserRepository) CheckSubscription(_ context.Context, userID int) (bool, error) {
+	args := m.Called(userID)
 	return args.Bool(0), args.Error(1)
 }
 
-func (m *MockUserRepository) Subscribe(c echo.Context) (*entity.User, error) {
-	args := m.Called(c)
+func (m *MockUserRepository) Subscribe(_ context.Context, userID int) (*entity.User, error) {
+	args := m.Called(userID)
 	return args.Get(0).(*entity.User), args.Error(1)
 }
 
+func (m *MockUserRepository) Verify(_ context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UpdatePassword(_ context.Context, id int, password string) error {
+	args := m.Called(id, password)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) SetTOTPSecret(_ context.Context, id int, secret string) error {
+	args := m.Called(id, secret)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) EnableTOTP(_ context.Context, id int, recoveryCodeHashes []string) error {
+	args := m.Called(id, recoveryCodeHashes)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) DisableTOTP(_ context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) UseTOTPStep(_ context.Context, id int, step int64) (bool, error) {
+	args := m.Called(id, step)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) UseRecoveryCode(_ context.Context, id int, codeHash string) (bool, error) {
+	args := m.Called(id, codeHash)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockUserRepository) ScheduleDeletion(_ context.Context, id int, at time.Time) error {
+	args := m.Called(id, at)
+	return args.Error(0)
+}
+
+func (m *MockUserRepository) CancelDeletion(_ context.Context, id int) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
 func TestUserHandler_Me(t *testing.T) {
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodGet, "/me", nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
-	c.Set("user_id", 1)
+	authenticate(c, 1, 2)
 
 	mockUserRepo := new(MockUserRepository)
 	mockProfileRepo := new(MockProfileRepository)
-	handler := NewUserHandler(mockUserRepo, mockProfileRepo)
+	mockMatchRepo := new(MockMatchRepository)
+	handler := NewUserHandler(mockUserRepo, mockProfileRepo, mockMatchRepo, &config.Discovery{SuperlikeDailyLimit: 1, SuperlikePremiumDailyLimit: 5})
 
 	user := &entity.User{ID: 1, Name: "John Doe"}
 	mockUserRepo.On("FindByID", 1).Return(user, nil)
+	mockMatchRepo.On("SuperlikeQuota", 1, 2, 1, 5).Return(&entity.SuperlikeQuota{Limit: 1, Used: 1, Remaining: 0}, nil)
 
 	err := handler.Me(c)
 	assert.NoError(t, err)
 	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"name":"John Doe"`)
+	assert.Contains(t, rec.Body.String(), `"superlikes":{"limit":1,"used":1,"remaining":0`)
 	mockUserRepo.AssertExpectations(t)
+	mockMatchRepo.AssertExpectations(t)
 }
 
 func TestUserHandler_UpdateProfile(t *testing.T) {
-	e := echo.New()
+	e := newTestEcho()
 	payload := `{"description": "New Description", "picture": "new.jpg"}`
 	req := httptest.NewRequest(http.MethodPut, "/profile", strings.NewReader(payload))
 	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
-	c.Set("user_id", 1)
+	authenticate(c, 1, 2)
 
 	mockUserRepo := new(MockUserRepository)
 	mockProfileRepo := new(MockProfileRepository)
-	handler := NewUserHandler(mockUserRepo, mockProfileRepo)
+	handler := NewUserHandler(mockUserRepo, mockProfileRepo, new(MockMatchRepository), &config.Discovery{})
 
 	profile := &entity.Profile{UserID: 1, Description: "Old Description", Picture: "old.jpg"}
 	mockProfileRepo.On("FindByUserID", 1).Return(profile, nil)
-	mockProfileRepo.On("Save", mock.AnythingOfType("*entity.Profile")).Return(profile, nil)
+	// the picture is only set from the photo gallery
+	mockProfileRepo.On("Save", mock.MatchedBy(func(p *entity.Profile) bool {
+		return p.Description == "New Description" && p.Picture == "old.jpg"
+	})).Return(profile, nil)
 
 	err := handler.UpdateProfile(c)
 	assert.NoError(t, err)
@@ -94,19 +149,146 @@ func TestUserHandler_UpdateProfile(t *testing.T) {
 	mockProfileRepo.AssertExpectations(t)
 }
 
+func TestUserHandler_UpdateProfileDatingFields(t *testing.T) {
+	e := newTestEcho()
+	payload := `{"birthdate": "1995-04-20", "gender": "female", "interested_in": ["male", "non_binary"], "city": " Jakarta ",
+		"latitude": -6.2, "longitude": 106.8, "height_cm": 165, "job": "Engineer", "interests": ["Hiking", "coffee", " hiking "]}`
+	req := httptest.NewRequest(http.MethodPut, "/profile", strings.NewReader(payload))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	authenticate(c, 1, 2)
+
+	mockUserRepo := new(MockUserRepository)
+	mockProfileRepo := new(MockProfileRepository)
+	handler := NewUserHandler(mockUserRepo, mockProfileRepo, new(MockMatchRepository), &config.Discovery{})
+
+	profile := &entity.Profile{UserID: 1, Description: "Old Description"}
+	mockProfileRepo.On("FindByUserID", 1).Return(profile, nil)
+	mockProfileRepo.On("Save", mock.MatchedBy(func(p *entity.Profile) bool {
+		return p.Birthdate.Format("2006-01-02") == "1995-04-20" &&
+			p.Gender == entity.GenderFemale &&
+			assert.ObjectsAreEqual([]string{"male", "non_binary"}, p.InterestedIn) &&
+			p.City == "Jakarta" &&
+			*p.Latitude == -6.2 && *p.Longitude == 106.8 &&
+			*p.HeightCm == 165 &&
+			p.Job == "Engineer" &&
+			assert.ObjectsAreEqual([]string{"hiking", "coffee"}, p.Interests) &&
+			p.Description == "Old Description"
+	})).Return(profile, nil)
+
+	err := handler.UpdateProfile(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockProfileRepo.AssertExpectations(t)
+}
+
+func TestUserHandler_UpdateProfileValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		field   string
+	}{
+		{"invalid birthdate", `{"birthdate": "20-04-1995"}`, "birthdate"},
+		{"underage", `{"birthdate": "` + time.Now().AddDate(-17, 0, 0).Format("2006-01-02") + `"}`, "birthdate"},
+		{"unknown gender", `{"gender": "robot"}`, "gender"},
+		{"unknown interested in", `{"interested_in": ["male", "robot"]}`, "interested_in[1]"},
+		{"latitude out of range", `{"latitude": 91, "longitude": 0}`, "latitude"},
+		{"latitude without longitude", `{"latitude": -6.2}`, "longitude"},
+		{"height out of range", `{"height_cm": 20}`, "height_cm"},
+		{"too many interests", `{"interests": ["a","b","c","d","e","f","g","h","i","j","k"]}`, "interests"},
+		{"interest too long", `{"interests": ["` + strings.Repeat("a", 31) + `"]}`, "interests[0]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEcho()
+			req := httptest.NewRequest(http.MethodPut, "/profile", strings.NewReader(tt.payload))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			authenticate(c, 1, 2)
+
+			mockProfileRepo := new(MockProfileRepository)
+			handler := NewUserHandler(new(MockUserRepository), mockProfileRepo, new(MockMatchRepository), &config.Discovery{})
+
+			err := handler.UpdateProfile(c)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"`+tt.field+`"`)
+			mockProfileRepo.AssertNotCalled(t, "Save", mock.Anything)
+		})
+	}
+}
+
+func TestUserHandler_UpdateLocation(t *testing.T) {
+	e := newTestEcho()
+	payload := `{"latitude": -6.2088, "longitude": 106.8456}`
+	req := httptest.NewRequest(http.MethodPut, "/me/location", strings.NewReader(payload))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+	authenticate(c, 1, 2)
+
+	mockProfileRepo := new(MockProfileRepository)
+	handler := NewUserHandler(new(MockUserRepository), mockProfileRepo, new(MockMatchRepository), &config.Discovery{})
+
+	profile := &entity.Profile{UserID: 1}
+	mockProfileRepo.On("FindByUserID", 1).Return(profile, nil)
+	mockProfileRepo.On("Save", mock.MatchedBy(func(p *entity.Profile) bool {
+		return *p.Latitude == -6.2088 && *p.Longitude == 106.8456 && p.LocationUpdatedAt != nil
+	})).Return(profile, nil)
+
+	err := handler.UpdateLocation(c)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	mockProfileRepo.AssertExpectations(t)
+}
+
+func TestUserHandler_UpdateLocationValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		field   string
+	}{
+		{"missing longitude", `{"latitude": -6.2}`, "longitude"},
+		{"latitude out of range", `{"latitude": -91, "longitude": 106.8}`, "latitude"},
+		{"longitude out of range", `{"latitude": -6.2, "longitude": 181}`, "longitude"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEcho()
+			req := httptest.NewRequest(http.MethodPut, "/me/location", strings.NewReader(tt.payload))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			c := e.NewContext(req, rec)
+			authenticate(c, 1, 2)
+
+			mockProfileRepo := new(MockProfileRepository)
+			handler := NewUserHandler(new(MockUserRepository), mockProfileRepo, new(MockMatchRepository), &config.Discovery{})
+
+			err := handler.UpdateLocation(c)
+			assert.NoError(t, err)
+			assert.Equal(t, http.StatusBadRequest, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"`+tt.field+`"`)
+			mockProfileRepo.AssertNotCalled(t, "Save", mock.Anything)
+		})
+	}
+}
+
 func TestUserHandler_PurchasePremium(t *testing.T) {
 	e := echo.New()
 	req := httptest.NewRequest(http.MethodPost, "/purchase", nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
+	authenticate(c, 1, 2)
 
 	mockUserRepo := new(MockUserRepository)
 	mockProfileRepo := new(MockProfileRepository)
-	handler := NewUserHandler(mockUserRepo, mockProfileRepo)
+	handler := NewUserHandler(mockUserRepo, mockProfileRepo, new(MockMatchRepository), &config.Discovery{})
 
-	mockUserRepo.On("CheckSubscription", c).Return(false, nil)
+	mockUserRepo.On("CheckSubscription", 1).Return(false, nil)
 	user := &entity.User{ID: 1, Subscription: entity.Subscription{ValidUntil: time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)}}
-	mockUserRepo.On("Subscribe", c).Return(user, nil)
+	mockUserRepo.On("Subscribe", 1).Return(user, nil)
 
 	err := handler.PurchasePremium(c)
 	assert.NoError(t, err)
@@ -119,12 +301,13 @@ func TestUserHandler_PurchasePremiumAlreadyActive(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/purchase", nil)
 	rec := httptest.NewRecorder()
 	c := e.NewContext(req, rec)
+	authenticate(c, 1, 2)
 
 	mockUserRepo := new(MockUserRepository)
 	mockProfileRepo := new(MockProfileRepository)
-	handler := NewUserHandler(mockUserRepo, mockProfileRepo)
+	handler := NewUserHandler(mockUserRepo, mockProfileRepo, new(MockMatchRepository), &config.Discovery{})
 
-	mockUserRepo.On("CheckSubscription", c).Return(true, nil)
+	mockUserRepo.On("CheckSubscription", 1).Return(true, nil)
 
 	_ = handler.PurchasePremium(c)
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
@@ -3,15 +3,21 @@ package middleware
 // parse the token from the request
 
 import (
+	"log"
 	"main/helpers"
+	"main/repository"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 )
 
-func AuthMiddleware(JWTSecret string) echo.MiddlewareFunc {
+// sessionTouchInterval limits how often the last seen time of a session is written.
+const sessionTouchInterval = time.Minute
+
+func AuthMiddleware(keys *helpers.KeySet, sessionRepo repository.SessionRepositoryInterface) echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
 			token := c.Request().Header.Get("Authorization")
@@ -25,7 +31,7 @@ func AuthMiddleware(JWTSecret string) echo.MiddlewareFunc {
 				helpers.ResponseWithError(c, http.StatusUnauthorized, "Unauthorized")
 				return nil
 			}
-			tokenValidated, err := helpers.ValidateToken(parts[1], JWTSecret)
+			tokenValidated, err := helpers.ValidateToken(parts[1], keys)
 			if err != nil {
 				helpers.ResponseWithError(c, http.StatusUnauthorized, "Unauthorized")
 				return nil
@@ -36,6 +42,11 @@ func AuthMiddleware(JWTSecret string) echo.MiddlewareFunc {
 			}
 
 			claims := tokenValidated.Claims.(jwt.MapClaims)
+			// purpose tokens such as email verification or MFA pending tokens are not access tokens
+			if _, ok := claims["purpose"]; ok {
+				helpers.ResponseWithError(c, http.StatusUnauthorized, "Unauthorized")
+				return nil
+			}
 			userID, ok := claims["user_id"].(float64)
 			if !ok {
 				helpers.ResponseWithError(c, http.StatusUnauthorized, "Unauthorized")
@@ -46,10 +57,34 @@ func AuthMiddleware(JWTSecret string) echo.MiddlewareFunc {
 				helpers.ResponseWithError(c, http.StatusUnauthorized, "Unauthorized")
 				return nil
 			}
-			c.Set("user_id", int(userID))
-			c.Set("profile_id", int(profileId))
-			c.Set("name", claims["name"])
-			c.Set("email", claims["email"])
+			// every access token belongs to a session so it dies when the session is revoked
+			sessionID, ok := claims["sid"].(string)
+			if !ok {
+				helpers.ResponseWithError(c, http.StatusUnauthorized, "Unauthorized")
+				return nil
+			}
+			session, err := sessionRepo.FindByID(c.Request().Context(), sessionID)
+			if err != nil {
+				helpers.ResponseWithError(c, http.StatusInternalServerError, "Internal server error")
+				return nil
+			}
+			if session == nil || session.IsRevoked() || session.UserID != uint(userID) {
+				helpers.ResponseWithError(c, http.StatusUnauthorized, "Unauthorized")
+				return nil
+			}
+			if time.Since(session.LastSeenAt) > sessionTouchInterval {
+				if err := sessionRepo.Touch(c.Request().Context(), sessionID, c.RealIP(), time.Now()); err != nil {
+					log.Printf("Failed to update session last seen time: %v", err)
+				}
+			}
+			principal := &helpers.Principal{
+				UserID:    int(userID),
+				ProfileID: int(profileId),
+				SessionID: sessionID,
+			}
+			principal.Name, _ = claims["name"].(string)
+			principal.Email, _ = claims["email"].(string)
+			helpers.SetPrincipal(c, principal)
 			return next(c)
 		}
 	}
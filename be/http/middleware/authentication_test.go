@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"context"
+	"main/config"
+	"main/entity"
+	"main/helpers"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockSessionRepository struct {
+	mock.Mock
+}
+
+func (m *MockSessionRepository) Create(_ context.Context, session *entity.Session) error {
+	args := m.Called(session)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) FindByID(_ context.Context, id string) (*entity.Session, error) {
+	args := m.Called(id)
+	return args.Get(0).(*entity.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) ListActive(_ context.Context, userID int) ([]entity.Session, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]entity.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) Touch(_ context.Context, id string, ip string, seenAt time.Time) error {
+	args := m.Called(id, ip, seenAt)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) Revoke(_ context.Context, userID int, id string) (bool, error) {
+	args := m.Called(userID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func serveWithToken(t *testing.T, sessionRepo *MockSessionRepository, token string) (*httptest.ResponseRecorder, echo.Context) {
+	keys, err := helpers.NewKeySet(&config.JWT{Secret: "secret"})
+	assert.NoError(t, err)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	handler := AuthMiddleware(keys, sessionRepo)(func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	})
+	assert.NoError(t, handler(c))
+	return rec, c
+}
+
+func newAccessToken(t *testing.T, sessionID string) string {
+	keys, _ := helpers.NewKeySet(&config.JWT{Secret: "secret"})
+	user := &entity.User{ID: 1, Profile: entity.Profile{ID: 2}}
+	token, err := helpers.GenerateAccessToken(user, sessionID, &config.JWT{Expiry: 3600}, keys)
+	assert.NoError(t, err)
+	return *token
+}
+
+func TestAuthMiddlewareActiveSession(t *testing.T) {
+	sessionRepo := new(MockSessionRepository)
+	sessionRepo.On("FindByID", "session").Return(&entity.Session{ID: "session", UserID: 1, LastSeenAt: time.Now()}, nil)
+
+	rec, c := serveWithToken(t, sessionRepo, newAccessToken(t, "session"))
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	principal := helpers.CurrentPrincipal(c)
+	if assert.NotNil(t, principal) {
+		assert.Equal(t, 1, principal.UserID)
+		assert.Equal(t, 2, principal.ProfileID)
+		assert.Equal(t, "session", principal.SessionID)
+	}
+	sessionRepo.AssertNotCalled(t, "Touch", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAuthMiddlewareTouchesIdleSession(t *testing.T) {
+	sessionRepo := new(MockSessionRepository)
+	sessionRepo.On("FindByID", "session").Return(&entity.Session{ID: "session", UserID: 1, LastSeenAt: time.Now().Add(-time.Hour)}, nil)
+	sessionRepo.On("Touch", "session", mock.Anything, mock.AnythingOfType("time.Time")).Return(nil)
+
+	rec, _ := serveWithToken(t, sessionRepo, newAccessToken(t, "session"))
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	sessionRepo.AssertExpectations(t)
+}
+
+func TestAuthMiddlewareRevokedSession(t *testing.T) {
+	sessionRepo := new(MockSessionRepository)
+	revokedAt := time.Now()
+	sessionRepo.On("FindByID", "session").Return(&entity.Session{ID: "session", UserID: 1, RevokedAt: &revokedAt}, nil)
+
+	rec, _ := serveWithToken(t, sessionRepo, newAccessToken(t, "session"))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAuthMiddlewareUnknownSession(t *testing.T) {
+	sessionRepo := new(MockSessionRepository)
+	sessionRepo.On("FindByID", "session").Return((*entity.Session)(nil), nil)
+
+	rec, _ := serveWithToken(t, sessionRepo, newAccessToken(t, "session"))
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
+
+func TestAuthMiddlewareTokenWithoutSession(t *testing.T) {
+	keys, _ := helpers.NewKeySet(&config.JWT{Secret: "secret"})
+	token, _ := keys.Sign(jwt.MapClaims{
+		"user_id":    1,
+		"profile_id": 2,
+		"exp":        time.Now().Add(time.Hour).Unix(),
+	})
+	sessionRepo := new(MockSessionRepository)
+
+	rec, _ := serveWithToken(t, sessionRepo, token)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	sessionRepo.AssertNotCalled(t, "FindByID", mock.Anything)
+}
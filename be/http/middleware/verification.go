@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"main/helpers"
+	"main/repository"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// VerifiedMiddleware rejects users who have not verified their email address yet.
+// It must run after AuthMiddleware.
+func VerifiedMiddleware(userRepo repository.UserRepositoryInterface) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal := helpers.CurrentPrincipal(c)
+			if principal == nil {
+				helpers.ResponseWithError(c, http.StatusUnauthorized, "Unauthorized")
+				return nil
+			}
+
+			user, err := userRepo.FindByID(c.Request().Context(), principal.UserID)
+			if err != nil {
+				helpers.ResponseWithError(c, http.StatusUnauthorized, "Unauthorized")
+				return nil
+			}
+			if !user.IsVerified() {
+				helpers.ResponseWithError(c, http.StatusForbidden, "Email not verified")
+				return nil
+			}
+			return next(c)
+		}
+	}
+}
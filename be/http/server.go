@@ -2,50 +2,84 @@ package http
 
 import (
 	"main/config"
+	"main/helpers"
 	"main/http/handler"
 	"main/http/middleware"
+	"main/mailer"
+	"main/oidc"
 	"main/repository"
+	"main/storage"
 
 	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
 
 type Route struct {
-	Method  string
-	Path    string
-	Handler echo.HandlerFunc
-	IsAuth  bool
+	Method     string
+	Path       string
+	Handler    echo.HandlerFunc
+	IsAuth     bool
+	IsVerified bool
 }
 
-func BuildServer(e *echo.Echo, db *gorm.DB, cfg *config.Config) {
+func BuildServer(e *echo.Echo, db *gorm.DB, cfg *config.Config, keys *helpers.KeySet, mail mailer.Mailer, store storage.BlobStore) {
 	routes := []Route{}
-
-	// init middleware
-	middlewareAuth := middleware.AuthMiddleware(cfg.JWT.Secret)
+	e.Validator = helpers.NewValidator(cfg.PasswordPolicy)
 
 	// init repository
 	userRepository := repository.NewUserRepository(db)
 	profileRepository := repository.NewProfileRepository(db)
 	matchRepository := repository.NewMatchRepository(db)
+	tokenRepository := repository.NewTokenRepository(db)
+	loginAttemptRepository := repository.NewLoginAttemptRepository(db)
+	identityRepository := repository.NewIdentityRepository(db)
+	sessionRepository := repository.NewSessionRepository(db)
+	exportRepository := repository.NewExportRepository(db)
+	preferenceRepository := repository.NewPreferenceRepository(db)
+	photoRepository := repository.NewPhotoRepository(db)
+
+	// init middleware
+	middlewareAuth := middleware.AuthMiddleware(keys, sessionRepository)
+	middlewareVerified := middleware.VerifiedMiddleware(userRepository)
 
 	// init handler
-	authHandler := handler.NewAuthHandler(userRepository, cfg)
-	datingHandler := handler.NewDatingHandler(profileRepository, matchRepository)
-	userHandler := handler.NewUserHandler(userRepository, profileRepository)
+	authHandler := handler.NewAuthHandler(userRepository, tokenRepository, sessionRepository, loginAttemptRepository, keys, mail, cfg)
+	oauthHandler := handler.NewOAuthHandler(authHandler, identityRepository, oidc.NewProviders(cfg.OIDCProviders))
+	datingHandler := handler.NewDatingHandler(profileRepository, matchRepository, &cfg.Discovery)
+	userHandler := handler.NewUserHandler(userRepository, profileRepository, matchRepository, &cfg.Discovery)
+	exportHandler := handler.NewExportHandler(exportRepository)
+	preferenceHandler := handler.NewPreferenceHandler(preferenceRepository)
+	photoHandler := handler.NewPhotoHandler(photoRepository, store, &cfg.Photo)
 
 	// init routes
 	authRoutes := routeAuth(authHandler)
+	oauthRoutes := routeOAuth(oauthHandler)
 	datingRoutes := routeDating(datingHandler)
 	profileRoutes := routeProfile(userHandler)
+	exportRoutes := routeExport(exportHandler)
+	preferenceRoutes := routePreference(preferenceHandler)
+	photoRoutes := routePhoto(photoHandler)
 	routes = append(routes, (*authRoutes)...)
+	routes = append(routes, (*oauthRoutes)...)
 	routes = append(routes, (*datingRoutes)...)
 	routes = append(routes, (*profileRoutes)...)
+	routes = append(routes, (*exportRoutes)...)
+	routes = append(routes, (*preferenceRoutes)...)
+	routes = append(routes, (*photoRoutes)...)
 	for _, route := range routes {
+		middlewares := []echo.MiddlewareFunc{}
 		if route.IsAuth {
-			e.Add(route.Method, route.Path, route.Handler, middlewareAuth)
-		} else {
-			e.Add(route.Method, route.Path, route.Handler)
+			middlewares = append(middlewares, middlewareAuth)
+		}
+		if route.IsVerified {
+			middlewares = append(middlewares, middlewareVerified)
 		}
+		e.Add(route.Method, route.Path, route.Handler, middlewares...)
+	}
+
+	// uploads of the local blob store are served by the application
+	if cfg.Storage.Driver == "" || cfg.Storage.Driver == "local" {
+		e.Static("/media", cfg.Storage.Dir)
 	}
 }
 
@@ -65,10 +99,135 @@ func routeAuth(h *handler.AuthHandler) *[]Route {
 		Handler: h.Register,
 	}
 
-	authRoutes = append(authRoutes, loginRoute, registerRoute)
+	refreshTokenRoute := Route{
+		Method:  "POST",
+		IsAuth:  false,
+		Path:    "/token/refresh",
+		Handler: h.RefreshToken,
+	}
+
+	logoutRoute := Route{
+		Method:  "POST",
+		IsAuth:  false,
+		Path:    "/logout",
+		Handler: h.Logout,
+	}
+
+	jwksRoute := Route{
+		Method:  "GET",
+		IsAuth:  false,
+		Path:    "/.well-known/jwks.json",
+		Handler: h.JWKS,
+	}
+
+	verifyEmailRoute := Route{
+		Method:  "GET",
+		IsAuth:  false,
+		Path:    "/verify-email",
+		Handler: h.VerifyEmail,
+	}
+
+	resendVerificationRoute := Route{
+		Method:  "POST",
+		IsAuth:  true,
+		Path:    "/verify-email/resend",
+		Handler: h.ResendVerification,
+	}
+
+	forgotPasswordRoute := Route{
+		Method:  "POST",
+		IsAuth:  false,
+		Path:    "/password/forgot",
+		Handler: h.ForgotPassword,
+	}
+
+	resetPasswordRoute := Route{
+		Method:  "POST",
+		IsAuth:  false,
+		Path:    "/password/reset",
+		Handler: h.ResetPassword,
+	}
+
+	changePasswordRoute := Route{
+		Method:  "PUT",
+		IsAuth:  true,
+		Path:    "/me/password",
+		Handler: h.ChangePassword,
+	}
+
+	loginMFARoute := Route{
+		Method:  "POST",
+		IsAuth:  false,
+		Path:    "/login/2fa",
+		Handler: h.LoginMFA,
+	}
+
+	enrollMFARoute := Route{
+		Method:  "POST",
+		IsAuth:  true,
+		Path:    "/me/2fa/enroll",
+		Handler: h.EnrollMFA,
+	}
+
+	confirmMFARoute := Route{
+		Method:  "POST",
+		IsAuth:  true,
+		Path:    "/me/2fa/confirm",
+		Handler: h.ConfirmMFA,
+	}
+
+	disableMFARoute := Route{
+		Method:  "POST",
+		IsAuth:  true,
+		Path:    "/me/2fa/disable",
+		Handler: h.DisableMFA,
+	}
+
+	listSessionsRoute := Route{
+		Method:  "GET",
+		IsAuth:  true,
+		Path:    "/me/sessions",
+		Handler: h.ListSessions,
+	}
+
+	revokeSessionRoute := Route{
+		Method:  "DELETE",
+		IsAuth:  true,
+		Path:    "/me/sessions/:id",
+		Handler: h.RevokeSession,
+	}
+
+	deleteAccountRoute := Route{
+		Method:  "DELETE",
+		IsAuth:  true,
+		Path:    "/me",
+		Handler: h.DeleteAccount,
+	}
+
+	authRoutes = append(authRoutes, loginRoute, registerRoute, refreshTokenRoute, logoutRoute, jwksRoute, verifyEmailRoute, resendVerificationRoute, forgotPasswordRoute, resetPasswordRoute, changePasswordRoute, loginMFARoute, enrollMFARoute, confirmMFARoute, disableMFARoute, listSessionsRoute, revokeSessionRoute, deleteAccountRoute)
 	return &authRoutes
 }
 
+func routeOAuth(h *handler.OAuthHandler) *[]Route {
+	oauthRoutes := []Route{}
+	authorizeRoute := Route{
+		Method:  "GET",
+		IsAuth:  false,
+		Path:    "/oauth/:provider/authorize",
+		Handler: h.Authorize,
+	}
+
+	callbackRoute := Route{
+		Method:  "GET",
+		IsAuth:  false,
+		Path:    "/oauth/:provider/callback",
+		Handler: h.Callback,
+	}
+
+	oauthRoutes = append(oauthRoutes, authorizeRoute, callbackRoute)
+	return &oauthRoutes
+}
+
 func routeProfile(h *handler.UserHandler) *[]Route {
 	profileRoutes := []Route{}
 	meRoute := Route{
@@ -85,6 +244,13 @@ func routeProfile(h *handler.UserHandler) *[]Route {
 		Handler: h.UpdateProfile,
 	}
 
+	updateLocationRoute := Route{
+		Method:  "PUT",
+		IsAuth:  true,
+		Path:    "/me/location",
+		Handler: h.UpdateLocation,
+	}
+
 	purchasePremiumRoute := Route{
 		Method:  "POST",
 		IsAuth:  true,
@@ -92,33 +258,157 @@ func routeProfile(h *handler.UserHandler) *[]Route {
 		Handler: h.PurchasePremium,
 	}
 
-	profileRoutes = append(profileRoutes, meRoute, purchasePremiumRoute, updateMeRoute)
+	profileRoutes = append(profileRoutes, meRoute, purchasePremiumRoute, updateMeRoute, updateLocationRoute)
 	return &profileRoutes
 }
 
-func routeDating(h *handler.DatingHandler) *[]Route {
-	datingRoutes := []Route{}
-	profileRoute := Route{
+func routeExport(h *handler.ExportHandler) *[]Route {
+	exportRoutes := []Route{}
+	requestExportRoute := Route{
+		Method:  "POST",
+		IsAuth:  true,
+		Path:    "/me/export",
+		Handler: h.RequestExport,
+	}
+
+	getExportRoute := Route{
 		Method:  "GET",
 		IsAuth:  true,
-		Path:    "/profile",
-		Handler: h.Profile,
+		Path:    "/me/export/:id",
+		Handler: h.GetExport,
 	}
 
-	swipedProfileRoute := Route{
-		Method:  "POST",
+	exportRoutes = append(exportRoutes, requestExportRoute, getExportRoute)
+	return &exportRoutes
+}
+
+func routePreference(h *handler.PreferenceHandler) *[]Route {
+	preferenceRoutes := []Route{}
+	getPreferencesRoute := Route{
+		Method:  "GET",
 		IsAuth:  true,
-		Path:    "/swipe",
-		Handler: h.SwipedProfile,
+		Path:    "/me/preferences",
+		Handler: h.GetPreferences,
 	}
 
-	matchRoute := Route{
+	updatePreferencesRoute := Route{
+		Method:  "PUT",
+		IsAuth:  true,
+		Path:    "/me/preferences",
+		Handler: h.UpdatePreferences,
+	}
+
+	preferenceRoutes = append(preferenceRoutes, getPreferencesRoute, updatePreferencesRoute)
+	return &preferenceRoutes
+}
+
+func routePhoto(h *handler.PhotoHandler) *[]Route {
+	photoRoutes := []Route{}
+	listPhotosRoute := Route{
 		Method:  "GET",
 		IsAuth:  true,
-		Path:    "/match",
-		Handler: h.MatchList,
+		Path:    "/me/photos",
+		Handler: h.ListPhotos,
+	}
+
+	uploadPhotoRoute := Route{
+		Method:  "POST",
+		IsAuth:  true,
+		Path:    "/me/photos",
+		Handler: h.UploadPhoto,
+	}
+
+	reorderPhotosRoute := Route{
+		Method:  "PUT",
+		IsAuth:  true,
+		Path:    "/me/photos/order",
+		Handler: h.ReorderPhotos,
+	}
+
+	setPrimaryPhotoRoute := Route{
+		Method:  "PUT",
+		IsAuth:  true,
+		Path:    "/me/photos/:id/primary",
+		Handler: h.SetPrimaryPhoto,
+	}
+
+	deletePhotoRoute := Route{
+		Method:  "DELETE",
+		IsAuth:  true,
+		Path:    "/me/photos/:id",
+		Handler: h.DeletePhoto,
+	}
+
+	photoRoutes = append(photoRoutes, listPhotosRoute, uploadPhotoRoute, reorderPhotosRoute, setPrimaryPhotoRoute, deletePhotoRoute)
+	return &photoRoutes
+}
+
+func routeDating(h *handler.DatingHandler) *[]Route {
+	datingRoutes := []Route{}
+	profileRoute := Route{
+		Method:     "GET",
+		IsAuth:     true,
+		IsVerified: true,
+		Path:       "/profile",
+		Handler:    h.Profile,
+	}
+
+	swipedProfileRoute := Route{
+		Method:     "POST",
+		IsAuth:     true,
+		IsVerified: true,
+		Path:       "/swipe",
+		Handler:    h.SwipedProfile,
+	}
+
+	undoSwipeRoute := Route{
+		Method:     "POST",
+		IsAuth:     true,
+		IsVerified: true,
+		Path:       "/swipe/undo",
+		Handler:    h.UndoSwipe,
+	}
+
+	receivedLikesRoute := Route{
+		Method:     "GET",
+		IsAuth:     true,
+		IsVerified: true,
+		Path:       "/likes/received",
+		Handler:    h.ReceivedLikes,
+	}
+
+	answerLikeRoute := Route{
+		Method:     "POST",
+		IsAuth:     true,
+		IsVerified: true,
+		Path:       "/likes/received/:id",
+		Handler:    h.AnswerLike,
+	}
+
+	matchRoute := Route{
+		Method:     "GET",
+		IsAuth:     true,
+		IsVerified: true,
+		Path:       "/match",
+		Handler:    h.MatchList,
+	}
+
+	openMatchRoute := Route{
+		Method:     "GET",
+		IsAuth:     true,
+		IsVerified: true,
+		Path:       "/match/:id",
+		Handler:    h.OpenMatch,
+	}
+
+	unmatchRoute := Route{
+		Method:     "DELETE",
+		IsAuth:     true,
+		IsVerified: true,
+		Path:       "/match/:id",
+		Handler:    h.Unmatch,
 	}
 
-	datingRoutes = append(datingRoutes, profileRoute, swipedProfileRoute, matchRoute)
+	datingRoutes = append(datingRoutes, profileRoute, swipedProfileRoute, undoSwipeRoute, receivedLikesRoute, answerLikeRoute, matchRoute, openMatchRoute, unmatchRoute)
 	return &datingRoutes
 }
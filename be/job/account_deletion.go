@@ -0,0 +1,103 @@
+package job
+
+import (
+	"context"
+	"log"
+	"main/config"
+	"main/repository"
+	"main/storage"
+	"os"
+	"time"
+)
+
+// accountDeletionBatchSize bounds the number of accounts erased per run.
+const accountDeletionBatchSize = 100
+
+// AccountDeletionJob erases the accounts whose deletion grace period has ended.
+type AccountDeletionJob struct {
+	erasureRepo repository.ErasureRepositoryInterface
+	store       storage.BlobStore
+	interval    time.Duration
+}
+
+func NewAccountDeletionJob(erasureRepo repository.ErasureRepositoryInterface, store storage.BlobStore, cfg *config.AccountDeletion) *AccountDeletionJob {
+	return &AccountDeletionJob{
+		erasureRepo: erasureRepo,
+		store:       store,
+		interval:    time.Second * time.Duration(cfg.JobIntervalSeconds),
+	}
+}
+
+// Start runs the job immediately and then every interval until ctx is cancelled.
+func (j *AccountDeletionJob) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := j.Run(ctx, time.Now()); err != nil {
+			log.Printf("Failed to erase deleted accounts: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run erases every account due for deletion at now and returns how many were erased.
+func (j *AccountDeletionJob) Run(ctx context.Context, now time.Time) (int, error) {
+	erased := 0
+	for {
+		ids, err := j.erasureRepo.FindDue(ctx, now, accountDeletionBatchSize)
+		if err != nil {
+			return erased, err
+		}
+		progressed := false
+		for _, id := range ids {
+			// the keys and paths are read first because Erase removes the rows that reference them
+			keys, err := j.erasureRepo.FindBlobKeys(ctx, id)
+			if err != nil {
+				return erased, err
+			}
+			exports, err := j.erasureRepo.FindExportFiles(ctx, id)
+			if err != nil {
+				return erased, err
+			}
+			ok, err := j.erasureRepo.Erase(ctx, id, now)
+			if err != nil {
+				return erased, err
+			}
+			if ok {
+				erased++
+				progressed = true
+				j.deleteBlobs(ctx, keys)
+				deleteExportFiles(exports)
+				log.Printf("Erased account %d", id)
+			}
+		}
+		if len(ids) < accountDeletionBatchSize || !progressed {
+			return erased, nil
+		}
+	}
+}
+
+// deleteBlobs removes the uploaded files of an erased account. A failure is only logged since the
+// rows referencing the files are already gone, and cancellation is ignored for the same reason.
+func (j *AccountDeletionJob) deleteBlobs(ctx context.Context, keys []string) {
+	ctx = context.WithoutCancel(ctx)
+	for _, key := range keys {
+		if err := j.store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete blob %s: %v", key, err)
+		}
+	}
+}
+
+// deleteExportFiles removes the data export archives of an erased account. As with the blobs, a
+// failure is only logged.
+func deleteExportFiles(paths []string) {
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to delete data export %s: %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"main/config"
+	"main/storage"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockErasureRepository struct {
+	mock.Mock
+}
+
+func (m *MockErasureRepository) FindDue(_ context.Context, now time.Time, limit int) ([]int, error) {
+	args := m.Called(now, limit)
+	return args.Get(0).([]int), args.Error(1)
+}
+
+func (m *MockErasureRepository) Erase(_ context.Context, userID int, now time.Time) (bool, error) {
+	args := m.Called(userID, now)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockErasureRepository) FindBlobKeys(_ context.Context, userID int) ([]string, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockErasureRepository) FindExportFiles(_ context.Context, userID int) ([]string, error) {
+	args := m.Called(userID)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func newTestBlobStore(t *testing.T, keys ...string) storage.BlobStore {
+	store, err := storage.NewLocalStore(t.TempDir(), "http://localhost:7000/media")
+	assert.NoError(t, err)
+	for _, key := range keys {
+		assert.NoError(t, store.Put(context.Background(), key, strings.NewReader("photo"), "image/jpeg"))
+	}
+	return store
+}
+
+func TestAccountDeletionJobRun(t *testing.T) {
+	now := time.Now()
+	store := newTestBlobStore(t, "photos/1/a-full.jpg", "photos/2/b-full.jpg")
+	erasureRepo := new(MockErasureRepository)
+	erasureRepo.On("FindDue", now, accountDeletionBatchSize).Return([]int{1, 2}, nil)
+	erasureRepo.On("FindBlobKeys", 1).Return([]string{"photos/1/a-full.jpg"}, nil)
+	erasureRepo.On("FindBlobKeys", 2).Return([]string{"photos/2/b-full.jpg"}, nil)
+	exportOne, exportTwo := filepath.Join(t.TempDir(), "export-1.zip"), filepath.Join(t.TempDir(), "export-2.zip")
+	assert.NoError(t, os.WriteFile(exportOne, []byte("zip"), 0o600))
+	assert.NoError(t, os.WriteFile(exportTwo, []byte("zip"), 0o600))
+	erasureRepo.On("FindExportFiles", 1).Return([]string{exportOne}, nil)
+	erasureRepo.On("FindExportFiles", 2).Return([]string{exportTwo}, nil)
+	erasureRepo.On("Erase", 1, now).Return(true, nil)
+	// the deletion of user 2 was cancelled by a login after FindDue
+	erasureRepo.On("Erase", 2, now).Return(false, nil)
+
+	erased, err := NewAccountDeletionJob(erasureRepo, store, &config.AccountDeletion{JobIntervalSeconds: 60}).Run(context.Background(), now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, erased)
+	erasureRepo.AssertExpectations(t)
+	_, err = store.Get(context.Background(), "photos/1/a-full.jpg")
+	assert.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = store.Get(context.Background(), "photos/2/b-full.jpg")
+	assert.NoError(t, err)
+	assert.NoFileExists(t, exportOne)
+	assert.FileExists(t, exportTwo)
+}
+
+func TestAccountDeletionJobRunStopsOnError(t *testing.T) {
+	now := time.Now()
+	erasureRepo := new(MockErasureRepository)
+	erasureRepo.On("FindDue", now, accountDeletionBatchSize).Return([]int{1, 2}, nil)
+	erasureRepo.On("FindBlobKeys", 1).Return([]string{}, nil)
+	erasureRepo.On("FindExportFiles", 1).Return([]string{}, nil)
+	erasureRepo.On("Erase", 1, now).Return(false, errors.New("db down"))
+
+	erased, err := NewAccountDeletionJob(erasureRepo, newTestBlobStore(t), &config.AccountDeletion{JobIntervalSeconds: 60}).Run(context.Background(), now)
+
+	assert.Error(t, err)
+	assert.Equal(t, 0, erased)
+	erasureRepo.AssertNotCalled(t, "Erase", 2, now)
+}
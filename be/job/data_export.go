@@ -0,0 +1,160 @@
+package job
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"main/config"
+	"main/entity"
+	"main/helpers"
+	"main/repository"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DataExportJob builds the ZIP archives of requested data exports and removes them once expired.
+type DataExportJob struct {
+	exportRepo repository.ExportRepositoryInterface
+	dir        string
+	expiry     time.Duration
+	interval   time.Duration
+	timeout    time.Duration
+}
+
+func NewDataExportJob(exportRepo repository.ExportRepositoryInterface, cfg *config.DataExport) *DataExportJob {
+	return &DataExportJob{
+		exportRepo: exportRepo,
+		dir:        cfg.Dir,
+		expiry:     time.Second * time.Duration(cfg.ExpirySeconds),
+		interval:   time.Second * time.Duration(cfg.JobIntervalSeconds),
+		timeout:    time.Second * time.Duration(cfg.ProcessingTimeoutSeconds),
+	}
+}
+
+// Start runs the job immediately and then every interval until ctx is cancelled.
+func (j *DataExportJob) Start(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := j.Run(ctx, time.Now()); err != nil {
+			log.Printf("Failed to process data exports: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run builds every pending or abandoned export, removes the expired archives and returns how many exports were built.
+func (j *DataExportJob) Run(ctx context.Context, now time.Time) (int, error) {
+	if err := j.removeExpired(ctx, now); err != nil {
+		return 0, err
+	}
+
+	built := 0
+	for {
+		export, err := j.exportRepo.ClaimPending(ctx, now.Add(-j.timeout))
+		if err != nil {
+			return built, err
+		}
+		if export == nil {
+			return built, nil
+		}
+
+		filePath, err := j.build(ctx, export)
+		if err != nil {
+			log.Printf("Failed to build data export %d: %v", export.ID, err)
+			if err := j.exportRepo.Fail(ctx, export.ID); err != nil {
+				return built, err
+			}
+			continue
+		}
+		if err := j.exportRepo.Complete(ctx, export.ID, filePath, now.Add(j.expiry)); err != nil {
+			return built, err
+		}
+		built++
+	}
+}
+
+func (j *DataExportJob) build(ctx context.Context, export *entity.DataExport) (string, error) {
+	data, err := j.exportRepo.LoadUserData(ctx, int(export.UserID))
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(j.dir, 0o700); err != nil {
+		return "", err
+	}
+	// the random part keeps archive names unguessable
+	suffix, err := helpers.GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+	filePath := filepath.Join(j.dir, fmt.Sprintf("export-%d-%s.zip", export.ID, suffix))
+
+	file, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+	if err := writeExportArchive(file, data); err != nil {
+		file.Close()
+		os.Remove(filePath)
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(filePath)
+		return "", err
+	}
+	return filePath, nil
+}
+
+func (j *DataExportJob) removeExpired(ctx context.Context, now time.Time) error {
+	exports, err := j.exportRepo.FindExpired(ctx, now)
+	if err != nil {
+		return err
+	}
+	for _, export := range exports {
+		if err := os.Remove(export.FilePath); err != nil && !os.IsNotExist(err) {
+			log.Printf("Failed to remove data export %d: %v", export.ID, err)
+			continue
+		}
+		if err := j.exportRepo.MarkExpired(ctx, export.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeExportArchive writes the user data as a ZIP archive with one JSON file per kind of record.
+func writeExportArchive(w io.Writer, data *entity.UserData) error {
+	archive := zip.NewWriter(w)
+	files := []struct {
+		name    string
+		content interface{}
+	}{
+		{"user.json", data.User},
+		{"profile_views_made.json", data.ViewsMade},
+		{"profile_views_received.json", data.ViewsReceived},
+		{"swipes.json", data.SwipesMade},
+		{"matches.json", data.Matches},
+		{"subscriptions.json", data.Subscriptions},
+		{"discovery_preferences.json", data.Preference},
+	}
+	for _, f := range files {
+		entry, err := archive.Create(f.name)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(f.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
@@ -0,0 +1,168 @@
+package job
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"main/config"
+	"main/entity"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockExportRepository struct {
+	mock.Mock
+}
+
+func (m *MockExportRepository) Create(_ context.Context, export *entity.DataExport) error {
+	args := m.Called(export)
+	return args.Error(0)
+}
+
+func (m *MockExportRepository) FindByID(_ context.Context, userID int, id int) (*entity.DataExport, error) {
+	args := m.Called(userID, id)
+	return args.Get(0).(*entity.DataExport), args.Error(1)
+}
+
+func (m *MockExportRepository) FindInProgress(_ context.Context, userID int) (*entity.DataExport, error) {
+	args := m.Called(userID)
+	return args.Get(0).(*entity.DataExport), args.Error(1)
+}
+
+func (m *MockExportRepository) ClaimPending(_ context.Context, staleBefore time.Time) (*entity.DataExport, error) {
+	args := m.Called(staleBefore)
+	return args.Get(0).(*entity.DataExport), args.Error(1)
+}
+
+func (m *MockExportRepository) Complete(_ context.Context, id uint, filePath string, expiresAt time.Time) error {
+	args := m.Called(id, filePath, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockExportRepository) Fail(_ context.Context, id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockExportRepository) FindExpired(_ context.Context, now time.Time) ([]entity.DataExport, error) {
+	args := m.Called(now)
+	return args.Get(0).([]entity.DataExport), args.Error(1)
+}
+
+func (m *MockExportRepository) MarkExpired(_ context.Context, id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockExportRepository) LoadUserData(_ context.Context, userID int) (*entity.UserData, error) {
+	args := m.Called(userID)
+	return args.Get(0).(*entity.UserData), args.Error(1)
+}
+
+func readArchive(t *testing.T, filePath string) map[string]string {
+	archive, err := zip.OpenReader(filePath)
+	assert.NoError(t, err)
+	defer archive.Close()
+	files := map[string]string{}
+	for _, f := range archive.File {
+		r, err := f.Open()
+		assert.NoError(t, err)
+		content, _ := io.ReadAll(r)
+		r.Close()
+		files[f.Name] = string(content)
+	}
+	return files
+}
+
+func TestDataExportJobRun(t *testing.T) {
+	now := time.Now()
+	dir := t.TempDir()
+	exportRepo := new(MockExportRepository)
+	exportRepo.On("FindExpired", now).Return([]entity.DataExport{}, nil)
+	exportRepo.On("ClaimPending", mock.Anything).Return(&entity.DataExport{ID: 1, UserID: 5}, nil).Once()
+	exportRepo.On("ClaimPending", mock.Anything).Return((*entity.DataExport)(nil), nil)
+	exportRepo.On("LoadUserData", 5).Return(&entity.UserData{
+		User:          entity.User{ID: 5, Email: "john@example.com", Password: "hash", Profile: entity.Profile{ID: 9, UserID: 5}},
+		ViewsMade:     []entity.ProfileViewLog{{ID: 1, ViewerID: 9, ProfileID: 10}},
+		ViewsReceived: []entity.ProfileViewLog{{ID: 2, ViewerID: 10, ProfileID: 9}},
+		SwipesMade:    []entity.Swipe{{ID: 6, ProfileID: 9, TargetID: 11, Direction: entity.SwipePass}},
+		Matches:       []entity.Match{{ID: 3, ProfileID: 9, PartnerID: 10, Status: entity.StatusAccepted}},
+		Subscriptions: []entity.Subscription{{ID: 4, UserID: 5}},
+		Preference:    &entity.DiscoveryPreference{UserID: 5, MinAge: 25, MaxAge: 35},
+	}, nil)
+	var filePath string
+	exportRepo.On("Complete", uint(1), mock.AnythingOfType("string"), now.Add(time.Hour)).Run(func(args mock.Arguments) {
+		filePath = args.String(1)
+	}).Return(nil)
+
+	built, err := NewDataExportJob(exportRepo, &config.DataExport{Dir: dir, ExpirySeconds: 3600, JobIntervalSeconds: 10}).Run(context.Background(), now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, built)
+	assert.Equal(t, dir, filepath.Dir(filePath))
+	files := readArchive(t, filePath)
+	assert.Len(t, files, 7)
+	assert.Contains(t, files["user.json"], `"email": "john@example.com"`)
+	assert.NotContains(t, files["user.json"], "hash")
+	assert.Contains(t, files["profile_views_made.json"], `"profile_id": 10`)
+	assert.Contains(t, files["profile_views_received.json"], `"viewer_id": 10`)
+	assert.Contains(t, files["swipes.json"], `"direction": "pass"`)
+	assert.Contains(t, files["matches.json"], `"status": "accepted"`)
+	assert.NotContains(t, files["matches.json"], `"profile":`)
+	assert.Contains(t, files["discovery_preferences.json"], `"min_age": 25`)
+
+	var subscriptions []entity.Subscription
+	assert.NoError(t, json.Unmarshal([]byte(files["subscriptions.json"]), &subscriptions))
+	assert.Len(t, subscriptions, 1)
+}
+
+func TestDataExportJobRunFailure(t *testing.T) {
+	now := time.Now()
+	exportRepo := new(MockExportRepository)
+	exportRepo.On("FindExpired", now).Return([]entity.DataExport{}, nil)
+	exportRepo.On("ClaimPending", mock.Anything).Return(&entity.DataExport{ID: 1, UserID: 5}, nil).Once()
+	exportRepo.On("ClaimPending", mock.Anything).Return((*entity.DataExport)(nil), nil)
+	exportRepo.On("LoadUserData", 5).Return((*entity.UserData)(nil), errors.New("db down"))
+	exportRepo.On("Fail", uint(1)).Return(nil)
+
+	built, err := NewDataExportJob(exportRepo, &config.DataExport{Dir: t.TempDir(), ExpirySeconds: 3600, JobIntervalSeconds: 10}).Run(context.Background(), now)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, built)
+	exportRepo.AssertExpectations(t)
+}
+
+func TestDataExportJobRunReclaimsAbandoned(t *testing.T) {
+	now := time.Now()
+	exportRepo := new(MockExportRepository)
+	exportRepo.On("FindExpired", now).Return([]entity.DataExport{}, nil)
+	exportRepo.On("ClaimPending", now.Add(-time.Hour)).Return((*entity.DataExport)(nil), nil)
+
+	_, err := NewDataExportJob(exportRepo, &config.DataExport{ExpirySeconds: 3600, JobIntervalSeconds: 10, ProcessingTimeoutSeconds: 3600}).Run(context.Background(), now)
+
+	assert.NoError(t, err)
+	exportRepo.AssertExpectations(t)
+}
+
+func TestDataExportJobRemovesExpired(t *testing.T) {
+	now := time.Now()
+	filePath := filepath.Join(t.TempDir(), "export.zip")
+	assert.NoError(t, os.WriteFile(filePath, []byte("zip"), 0o600))
+	exportRepo := new(MockExportRepository)
+	exportRepo.On("FindExpired", now).Return([]entity.DataExport{{ID: 1, FilePath: filePath}}, nil)
+	exportRepo.On("MarkExpired", uint(1)).Return(nil)
+	exportRepo.On("ClaimPending", mock.Anything).Return((*entity.DataExport)(nil), nil)
+
+	_, err := NewDataExportJob(exportRepo, &config.DataExport{ExpirySeconds: 3600, JobIntervalSeconds: 10}).Run(context.Background(), now)
+
+	assert.NoError(t, err)
+	assert.NoFileExists(t, filePath)
+	exportRepo.AssertExpectations(t)
+}
@@ -0,0 +1,68 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"main/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// New returns the mailer selected by the MAIL_DRIVER config.
+func New(cfg *config.Mail) (Mailer, error) {
+	switch cfg.Driver {
+	case "", "log":
+		return NewLogMailer(cfg.From), nil
+	case "file":
+		return NewFileMailer(cfg.From, cfg.Dir)
+	}
+	return nil, fmt.Errorf("unsupported mail driver %q", cfg.Driver)
+}
+
+// LogMailer writes every message to the application log. Meant for local development.
+type LogMailer struct {
+	from string
+}
+
+func NewLogMailer(from string) Mailer {
+	return &LogMailer{
+		from: from,
+	}
+}
+
+func (m *LogMailer) Send(to, subject, body string) error {
+	log.Printf("Sending mail from %s to %s: %s\n%s", m.from, to, subject, body)
+	return nil
+}
+
+// FileMailer writes every message as a separate file in dir so it can be inspected in development and tests.
+type FileMailer struct {
+	from string
+	dir  string
+}
+
+func NewFileMailer(from, dir string) (Mailer, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileMailer{
+		from: from,
+		dir:  dir,
+	}, nil
+}
+
+func (m *FileMailer) Send(to, subject, body string) error {
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), strings.ReplaceAll(to, "/", "_"))
+	content := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\n\r\n%s\r\n", m.from, to, subject, body)
+	if err := os.WriteFile(filepath.Join(m.dir, name), []byte(content), 0644); err != nil {
+		log.Printf("Failed to write mail: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"main/config"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mails")
+	m, err := NewFileMailer("no-reply@example.com", dir)
+	assert.NoError(t, err)
+
+	err = m.Send("john@example.com", "Hello", "Body")
+	assert.NoError(t, err)
+
+	files, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	if assert.Len(t, files, 1) {
+		content, err := os.ReadFile(filepath.Join(dir, files[0].Name()))
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "To: john@example.com")
+		assert.Contains(t, string(content), "Subject: Hello")
+		assert.Contains(t, string(content), "Body")
+	}
+}
+
+func TestNew(t *testing.T) {
+	m, err := New(&config.Mail{Driver: "log"})
+	assert.NoError(t, err)
+	assert.IsType(t, &LogMailer{}, m)
+
+	m, err = New(&config.Mail{Driver: "file", Dir: t.TempDir()})
+	assert.NoError(t, err)
+	assert.IsType(t, &FileMailer{}, m)
+
+	_, err = New(&config.Mail{Driver: "smtp"})
+	assert.Error(t, err)
+}
@@ -1,10 +1,16 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"main/config"
+	"main/helpers"
 	"main/http"
+	"main/job"
+	"main/mailer"
+	"main/repository"
+	"main/storage"
 	"time"
 
 	"github.com/labstack/echo/v4"
@@ -22,7 +28,14 @@ func main() {
 		log.Printf("Failed to connect to db: %v", err)
 		panic(err)
 	}
-	http.BuildServer(e, db, config)
+	keys := buildKeySet(config)
+	mail := buildMailer(config)
+	store := buildBlobStore(config)
+	http.BuildServer(e, db, config, keys, mail, store)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	startJobs(ctx, db, store, config)
 
 	if err := (e.Start(fmt.Sprintf(":%s", config.PORT))); err != nil {
 		e.Logger.Fatal(err)
@@ -37,6 +50,35 @@ func buildEnv(env string) *config.Config {
 	return cfg
 }
 
+func buildKeySet(cfg *config.Config) *helpers.KeySet {
+	keys, err := helpers.NewKeySet(&cfg.JWT)
+	if err != nil {
+		panic(err)
+	}
+	return keys
+}
+
+func buildMailer(cfg *config.Config) mailer.Mailer {
+	mail, err := mailer.New(&cfg.Mail)
+	if err != nil {
+		panic(err)
+	}
+	return mail
+}
+
+func buildBlobStore(cfg *config.Config) storage.BlobStore {
+	store, err := storage.New(&cfg.Storage)
+	if err != nil {
+		panic(err)
+	}
+	return store
+}
+
+func startJobs(ctx context.Context, db *gorm.DB, store storage.BlobStore, cfg *config.Config) {
+	go job.NewAccountDeletionJob(repository.NewErasureRepository(db), store, &cfg.Deletion).Start(ctx)
+	go job.NewDataExportJob(repository.NewExportRepository(db), &cfg.Export).Start(ctx)
+}
+
 func buildDB(cfg *config.Config) (*gorm.DB, error) {
 
 	maxIdleConns := 10
@@ -56,6 +98,7 @@ func buildDB(cfg *config.Config) (*gorm.DB, error) {
 
 	db, err := gorm.Open(postgres.Open(sqlCfg), &gorm.Config{
 		// Logger: logger.Default.LogMode(logger.Silent),
+		TranslateError: true,
 	})
 	if err != nil {
 		return nil, err
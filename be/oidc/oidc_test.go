@@ -0,0 +1,151 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"main/config"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeIssuer is a minimal OpenID Connect provider that issues an ID token for a fixed authorization code.
+type fakeIssuer struct {
+	server        *httptest.Server
+	key           *rsa.PrivateKey
+	codeChallenge string
+	nonce         string
+	claims        jwt.MapClaims
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	issuer := &fakeIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer.server.URL,
+			"authorization_endpoint": issuer.server.URL + "/authorize",
+			"token_endpoint":         issuer.server.URL + "/token",
+			"jwks_uri":               issuer.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "code" || GeneratePKCEChallenge(r.Form.Get("code_verifier")) != issuer.codeChallenge {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, issuer.claims)
+		token.Header["kid"] = "key-1"
+		idToken, _ := token.SignedString(key)
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken})
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (f *fakeIssuer) provider() ProviderInterface {
+	return NewProvider(config.OIDCProvider{
+		Name:        "fake",
+		Issuer:      f.server.URL,
+		ClientID:    "client",
+		RedirectURL: "http://localhost/oauth/fake/callback",
+		Scopes:      []string{"openid", "email"},
+	}, f.server.Client())
+}
+
+// authorize mimics the user agent: it reads the challenge and nonce from the authorization URL.
+func (f *fakeIssuer) authorize(t *testing.T, provider ProviderInterface, verifier string) {
+	authURL, err := provider.AuthCodeURL("state", "nonce", GeneratePKCEChallenge(verifier))
+	assert.NoError(t, err)
+	parsed, err := url.Parse(authURL)
+	assert.NoError(t, err)
+	assert.Equal(t, f.server.URL+"/authorize", parsed.Scheme+"://"+parsed.Host+parsed.Path)
+	assert.Equal(t, "S256", parsed.Query().Get("code_challenge_method"))
+	assert.Equal(t, "openid email", parsed.Query().Get("scope"))
+	f.codeChallenge = parsed.Query().Get("code_challenge")
+	f.nonce = parsed.Query().Get("nonce")
+}
+
+func (f *fakeIssuer) validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            f.server.URL,
+		"aud":            "client",
+		"sub":            "subject-1",
+		"exp":            time.Now().Add(time.Minute).Unix(),
+		"nonce":          f.nonce,
+		"email":          "john@example.com",
+		"email_verified": "true",
+		"name":           "John",
+	}
+}
+
+func TestExchange(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	provider := issuer.provider()
+	issuer.authorize(t, provider, "verifier-verifier-verifier-verifier-verifier")
+	issuer.claims = issuer.validClaims()
+
+	identity, err := provider.Exchange(context.Background(), "code", "verifier-verifier-verifier-verifier-verifier", "nonce")
+	if assert.NoError(t, err) {
+		assert.Equal(t, &Identity{Subject: "subject-1", Email: "john@example.com", EmailVerified: true, Name: "John"}, identity)
+	}
+}
+
+func TestExchangeWrongVerifier(t *testing.T) {
+	issuer := newFakeIssuer(t)
+	provider := issuer.provider()
+	issuer.authorize(t, provider, "verifier-verifier-verifier-verifier-verifier")
+	issuer.claims = issuer.validClaims()
+
+	_, err := provider.Exchange(context.Background(), "code", "another-verifier", "nonce")
+	assert.Error(t, err)
+}
+
+func TestExchangeRejectsInvalidIDToken(t *testing.T) {
+	tests := map[string]func(claims jwt.MapClaims){
+		"nonce mismatch":  func(claims jwt.MapClaims) { claims["nonce"] = "other" },
+		"wrong audience":  func(claims jwt.MapClaims) { claims["aud"] = "other-client" },
+		"wrong issuer":    func(claims jwt.MapClaims) { claims["iss"] = "https://evil.example" },
+		"expired":         func(claims jwt.MapClaims) { claims["exp"] = time.Now().Add(-time.Minute).Unix() },
+		"missing subject": func(claims jwt.MapClaims) { delete(claims, "sub") },
+	}
+	for name, mutate := range tests {
+		t.Run(name, func(t *testing.T) {
+			issuer := newFakeIssuer(t)
+			provider := issuer.provider()
+			issuer.authorize(t, provider, "verifier-verifier-verifier-verifier-verifier")
+			issuer.claims = issuer.validClaims()
+			mutate(issuer.claims)
+
+			_, err := provider.Exchange(context.Background(), "code", "verifier-verifier-verifier-verifier-verifier", "nonce")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestGeneratePKCEChallenge(t *testing.T) {
+	// RFC 7636 appendix B
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", GeneratePKCEChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+}
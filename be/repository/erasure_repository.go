@@ -0,0 +1,135 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"main/entity"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ErasureRepositoryInterface interface {
+	FindDue(ctx context.Context, now time.Time, limit int) ([]int, error)
+	Erase(ctx context.Context, userID int, now time.Time) (bool, error)
+	FindBlobKeys(ctx context.Context, userID int) ([]string, error)
+	FindExportFiles(ctx context.Context, userID int) ([]string, error)
+}
+
+type ErasureRepository struct {
+	db *gorm.DB
+}
+
+func NewErasureRepository(db *gorm.DB) ErasureRepositoryInterface {
+	return &ErasureRepository{
+		db: db,
+	}
+}
+
+// FindDue returns the IDs of users whose deletion grace period ended before now.
+func (r *ErasureRepository) FindDue(ctx context.Context, now time.Time, limit int) ([]int, error) {
+	var ids []int
+	if err := r.db.WithContext(ctx).Model(&entity.User{}).
+		Where("deletion_scheduled_at <= ?", now).
+		Order("deletion_scheduled_at").
+		Limit(limit).
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// Erase deletes the user and every row that belongs to them in a single transaction. The user row is
+// locked and the schedule checked again, so a login that cancelled the deletion in the meantime wins.
+// false is returned when the user is no longer due for deletion.
+func (r *ErasureRepository) Erase(ctx context.Context, userID int, now time.Time) (bool, error) {
+	erased := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user entity.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND deletion_scheduled_at <= ?", userID, now).
+			First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return err
+		}
+
+		profileIDs := tx.Model(&entity.Profile{}).Select("id").Where("user_id = ?", userID)
+		if err := tx.Where("profile_id IN (?) OR viewer_id IN (?)", profileIDs, profileIDs).Delete(&entity.ProfileViewLog{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("profile_id IN (?) OR target_id IN (?)", profileIDs, profileIDs).Delete(&entity.Swipe{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("profile_id IN (?) OR partner_id IN (?)", profileIDs, profileIDs).Delete(&entity.Match{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("profile_id IN (?)", profileIDs).Delete(&entity.ProfilePhoto{}).Error; err != nil {
+			return err
+		}
+
+		owned := []interface{}{
+			&entity.Subscription{},
+			&entity.RefreshToken{},
+			&entity.PasswordResetToken{},
+			&entity.MFARecoveryCode{},
+			&entity.UserIdentity{},
+			&entity.Session{},
+			&entity.DataExport{},
+			&entity.DiscoveryPreference{},
+			&entity.Profile{},
+		}
+		for _, model := range owned {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		// throttling keys contain the email address
+		keys := []string{"account:" + user.Email, "mfa:" + strconv.Itoa(userID)}
+		if err := tx.Where("key IN ?", keys).Delete(&entity.LoginAttempt{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("key IN ?", keys).Delete(&entity.LoginLockout{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&entity.User{}, userID).Error; err != nil {
+			return err
+		}
+		erased = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return erased, nil
+}
+
+// FindBlobKeys returns the keys of the files the user uploaded, which Erase leaves in the blob store.
+func (r *ErasureRepository) FindBlobKeys(ctx context.Context, userID int) ([]string, error) {
+	var photos []entity.ProfilePhoto
+	if err := r.db.WithContext(ctx).Where("profile_id IN (?)", r.db.Model(&entity.Profile{}).Select("id").Where("user_id = ?", userID)).
+		Find(&photos).Error; err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(photos)*2)
+	for _, photo := range photos {
+		keys = append(keys, photo.ThumbnailKey, photo.FullKey)
+	}
+	return keys, nil
+}
+
+// FindExportFiles returns the paths of the user's data export archives, which Erase leaves on disk.
+func (r *ErasureRepository) FindExportFiles(ctx context.Context, userID int) ([]string, error) {
+	var paths []string
+	if err := r.db.WithContext(ctx).Model(&entity.DataExport{}).
+		Where("user_id = ? AND file_path <> ''", userID).
+		Pluck("file_path", &paths).Error; err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
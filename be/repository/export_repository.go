@@ -0,0 +1,162 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"main/entity"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ExportRepositoryInterface interface {
+	Create(ctx context.Context, export *entity.DataExport) error
+	FindByID(ctx context.Context, userID int, id int) (*entity.DataExport, error)
+	FindInProgress(ctx context.Context, userID int) (*entity.DataExport, error)
+	ClaimPending(ctx context.Context, staleBefore time.Time) (*entity.DataExport, error)
+	Complete(ctx context.Context, id uint, filePath string, expiresAt time.Time) error
+	Fail(ctx context.Context, id uint) error
+	FindExpired(ctx context.Context, now time.Time) ([]entity.DataExport, error)
+	MarkExpired(ctx context.Context, id uint) error
+	LoadUserData(ctx context.Context, userID int) (*entity.UserData, error)
+}
+
+type ExportRepository struct {
+	db *gorm.DB
+}
+
+func NewExportRepository(db *gorm.DB) ExportRepositoryInterface {
+	return &ExportRepository{
+		db: db,
+	}
+}
+
+func (r *ExportRepository) Create(ctx context.Context, export *entity.DataExport) error {
+	if err := r.db.WithContext(ctx).Create(export).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindByID returns the export of the user with the given ID, or nil when the user has no such export.
+func (r *ExportRepository) FindByID(ctx context.Context, userID int, id int) (*entity.DataExport, error) {
+	var export entity.DataExport
+	if err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&export).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &export, nil
+}
+
+// FindInProgress returns the pending or processing export of the user, if any.
+func (r *ExportRepository) FindInProgress(ctx context.Context, userID int) (*entity.DataExport, error) {
+	var export entity.DataExport
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND status IN ?", userID, []string{entity.ExportStatusPending, entity.ExportStatusProcessing}).
+		First(&export).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &export, nil
+}
+
+// ClaimPending marks the oldest pending export as processing and returns it. An export still
+// processing since before staleBefore was abandoned by a worker that stopped and is claimed again.
+// Rows being claimed by another worker are skipped. nil is returned when nothing is pending.
+func (r *ExportRepository) ClaimPending(ctx context.Context, staleBefore time.Time) (*entity.DataExport, error) {
+	var exports []entity.DataExport
+	result := r.db.WithContext(ctx).Model(&exports).
+		Clauses(clause.Returning{}).
+		Where("id = (?)", r.db.Model(&entity.DataExport{}).
+			Select("id").
+			Where("status = ? OR (status = ? AND updated_at < ?)", entity.ExportStatusPending, entity.ExportStatusProcessing, staleBefore).
+			Order("id").
+			Limit(1).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})).
+		Updates(map[string]interface{}{"status": entity.ExportStatusProcessing, "updated_at": time.Now()})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(exports) == 0 {
+		return nil, nil
+	}
+	return &exports[0], nil
+}
+
+func (r *ExportRepository) Complete(ctx context.Context, id uint, filePath string, expiresAt time.Time) error {
+	if err := r.db.WithContext(ctx).Model(&entity.DataExport{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"status":       entity.ExportStatusReady,
+			"file_path":    filePath,
+			"expires_at":   expiresAt,
+			"completed_at": time.Now(),
+		}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *ExportRepository) Fail(ctx context.Context, id uint) error {
+	if err := r.db.WithContext(ctx).Model(&entity.DataExport{}).
+		Where("id = ?", id).
+		Update("status", entity.ExportStatusFailed).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// FindExpired returns the ready exports whose archive expired before now.
+func (r *ExportRepository) FindExpired(ctx context.Context, now time.Time) ([]entity.DataExport, error) {
+	var exports []entity.DataExport
+	if err := r.db.WithContext(ctx).Where("status = ? AND expires_at <= ?", entity.ExportStatusReady, now).Find(&exports).Error; err != nil {
+		return nil, err
+	}
+	return exports, nil
+}
+
+func (r *ExportRepository) MarkExpired(ctx context.Context, id uint) error {
+	if err := r.db.WithContext(ctx).Model(&entity.DataExport{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"status": entity.ExportStatusExpired, "file_path": ""}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// LoadUserData reads the user with their profile, the profile views they made and received,
+// their swipes, their matches, their whole subscription history and their discovery preferences.
+func (r *ExportRepository) LoadUserData(ctx context.Context, userID int) (*entity.UserData, error) {
+	var data entity.UserData
+	if err := r.db.WithContext(ctx).Preload("Profile").Preload("Profile.Photos", orderedPhotos).Preload("Subscription").First(&data.User, userID).Error; err != nil {
+		return nil, err
+	}
+	profileID := data.User.Profile.ID
+	if err := r.db.WithContext(ctx).Where("viewer_id = ?", profileID).Order("id").Find(&data.ViewsMade).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.WithContext(ctx).Where("profile_id = ?", profileID).Order("id").Find(&data.ViewsReceived).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.WithContext(ctx).Where("profile_id = ?", profileID).Order("id").Find(&data.SwipesMade).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.WithContext(ctx).Where("profile_id = ? OR partner_id = ?", profileID, profileID).Order("id").Find(&data.Matches).Error; err != nil {
+		return nil, err
+	}
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&data.Subscriptions).Error; err != nil {
+		return nil, err
+	}
+	var preference entity.DiscoveryPreference
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&preference).Error; err == nil {
+		data.Preference = &preference
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	return &data, nil
+}
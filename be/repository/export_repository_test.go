@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimPendingReclaimsAbandonedExports(t *testing.T) {
+	staleBefore := time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC)
+	db, fake := newFakeDB(t, nil)
+	repo := NewExportRepository(db)
+
+	export, err := repo.ClaimPending(context.Background(), staleBefore)
+
+	assert.NoError(t, err)
+	assert.Nil(t, export)
+	query := fake.statement(t, `UPDATE "data_exports"`)
+	assert.Contains(t, query, "status = 'pending' OR (status = 'processing' AND updated_at < '2024-12-01 10:00:00')")
+	assert.Contains(t, query, "FOR UPDATE SKIP LOCKED")
+}
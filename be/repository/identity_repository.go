@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"main/entity"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdentityRepositoryInterface interface {
+	FindIdentity(ctx context.Context, provider string, subject string) (*entity.UserIdentity, error)
+	CreateIdentity(ctx context.Context, identity *entity.UserIdentity) error
+	CreateState(ctx context.Context, state *entity.OAuthState) error
+	ConsumeState(ctx context.Context, hash string, provider string) (*entity.OAuthState, error)
+}
+
+type IdentityRepository struct {
+	db *gorm.DB
+}
+
+func NewIdentityRepository(db *gorm.DB) IdentityRepositoryInterface {
+	return &IdentityRepository{
+		db: db,
+	}
+}
+
+func (r *IdentityRepository) FindIdentity(ctx context.Context, provider string, subject string) (*entity.UserIdentity, error) {
+	var identity entity.UserIdentity
+	if err := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &identity, nil
+}
+
+func (r *IdentityRepository) CreateIdentity(ctx context.Context, identity *entity.UserIdentity) error {
+	if err := r.db.WithContext(ctx).Create(identity).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *IdentityRepository) CreateState(ctx context.Context, state *entity.OAuthState) error {
+	if err := r.db.WithContext(ctx).Create(state).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ConsumeState atomically deletes an unexpired authorization state of the provider and returns it,
+// so a callback can only be completed once. nil is returned when no such state exists.
+func (r *IdentityRepository) ConsumeState(ctx context.Context, hash string, provider string) (*entity.OAuthState, error) {
+	var states []entity.OAuthState
+	result := r.db.WithContext(ctx).Clauses(clause.Returning{}).
+		Where("state_hash = ? AND provider = ? AND expires_at > ?", hash, provider, time.Now()).
+		Delete(&states)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(states) == 0 {
+		return nil, nil
+	}
+	return &states[0], nil
+}
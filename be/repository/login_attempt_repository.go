@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"main/entity"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LoginAttemptRepositoryInterface interface {
+	Find(ctx context.Context, key string) (*entity.LoginAttempt, error)
+	RecordFailure(ctx context.Context, key string, window time.Duration) (*entity.LoginAttempt, error)
+	Lock(ctx context.Context, key string, until time.Time, lockout *entity.LoginLockout) error
+	Reset(ctx context.Context, key string) error
+}
+
+type LoginAttemptRepository struct {
+	db *gorm.DB
+}
+
+func NewLoginAttemptRepository(db *gorm.DB) LoginAttemptRepositoryInterface {
+	return &LoginAttemptRepository{
+		db: db,
+	}
+}
+
+func (r *LoginAttemptRepository) Find(ctx context.Context, key string) (*entity.LoginAttempt, error) {
+	var attempt entity.LoginAttempt
+	if err := r.db.WithContext(ctx).Where("key = ?", key).First(&attempt).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// RecordFailure increments the failure counter of key, starting over when the previous
+// failure is older than window, and returns the updated attempt.
+func (r *LoginAttemptRepository) RecordFailure(ctx context.Context, key string, window time.Duration) (*entity.LoginAttempt, error) {
+	now := time.Now()
+	attempt := entity.LoginAttempt{
+		Key:          key,
+		Failures:     1,
+		LastFailedAt: now,
+		UpdatedAt:    now,
+	}
+	if err := r.db.WithContext(ctx).Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "key"}},
+			DoUpdates: clause.Set{
+				{Column: clause.Column{Name: "failures"}, Value: gorm.Expr("CASE WHEN login_attempts.last_failed_at < ? THEN 1 ELSE login_attempts.failures + 1 END", now.Add(-window))},
+				{Column: clause.Column{Name: "last_failed_at"}, Value: now},
+				{Column: clause.Column{Name: "updated_at"}, Value: now},
+			},
+		},
+		clause.Returning{},
+	).Create(&attempt).Error; err != nil {
+		return nil, err
+	}
+	return &attempt, nil
+}
+
+// Lock locks key until the given time and writes the lockout audit record.
+func (r *LoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time, lockout *entity.LoginLockout) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&entity.LoginAttempt{}).
+			Where("key = ?", key).
+			Updates(map[string]interface{}{"locked_until": until, "updated_at": time.Now()}).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(lockout).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+func (r *LoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	if err := r.db.WithContext(ctx).Where("key = ?", key).Delete(&entity.LoginAttempt{}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// MemoryLoginAttemptRepository keeps login attempts in memory. It is meant for tests and single instance development setups.
+type MemoryLoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]entity.LoginAttempt
+	Lockouts []entity.LoginLockout
+}
+
+func NewMemoryLoginAttemptRepository() *MemoryLoginAttemptRepository {
+	return &MemoryLoginAttemptRepository{
+		attempts: map[string]entity.LoginAttempt{},
+	}
+}
+
+func (r *MemoryLoginAttemptRepository) Find(ctx context.Context, key string) (*entity.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt, ok := r.attempts[key]
+	if !ok {
+		return nil, nil
+	}
+	return &attempt, nil
+}
+
+func (r *MemoryLoginAttemptRepository) RecordFailure(ctx context.Context, key string, window time.Duration) (*entity.LoginAttempt, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	attempt, ok := r.attempts[key]
+	if !ok || attempt.LastFailedAt.Before(now.Add(-window)) {
+		attempt.Failures = 0
+	}
+	attempt.Key = key
+	attempt.Failures++
+	attempt.LastFailedAt = now
+	attempt.UpdatedAt = now
+	r.attempts[key] = attempt
+	return &attempt, nil
+}
+
+func (r *MemoryLoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time, lockout *entity.LoginLockout) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	attempt := r.attempts[key]
+	attempt.Key = key
+	attempt.LockedUntil = &until
+	attempt.UpdatedAt = time.Now()
+	r.attempts[key] = attempt
+	lockout.CreatedAt = attempt.UpdatedAt
+	r.Lockouts = append(r.Lockouts, *lockout)
+	return nil
+}
+
+func (r *MemoryLoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.attempts, key)
+	return nil
+}
@@ -1,21 +1,44 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"main/entity"
+	"time"
 
-	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrProfileNotFound = errors.New("profile not found")
+	ErrAlreadySwiped   = errors.New("profile already swiped")
+	ErrAlreadyMatched  = errors.New("profiles already matched")
+	ErrSuperlikeLimit  = errors.New("daily superlike limit reached")
+	ErrNothingToUndo   = errors.New("no swipe to undo")
+	ErrMatchNotFound   = errors.New("match not found")
+)
+
+// SwipeResult tells what a swipe did.
+type SwipeResult string
+
+const (
+	SwipeLiked   SwipeResult = "liked"
+	SwipeMatched SwipeResult = "matched"
+	SwipePassed  SwipeResult = "passed"
 )
 
 type MatchRepositoryInterface interface {
-	FindMatchByProfileID(profileID int) ([]*entity.Profile, error)
-	CheckMatch(profileID, partnerID int) (*entity.Match, error)
-	CheckPendingMatch(profileID, partnerID int) (*entity.Match, error)
-	AcceptMatch(profileID, partnerID int) error
-	RejectMatch(profileID, partnerID int) error
-	CreateMatch(profileID, partnerID int) error
-	CheckDailyLimit(ctx echo.Context) (bool, error)
+	FindMatches(ctx context.Context, profileID, afterMatchID, limit int) ([]entity.MatchListItem, error)
+	OpenMatch(ctx context.Context, matchID, profileID int) (*entity.MatchListItem, error)
+	Swipe(ctx context.Context, profileID, targetID int, direction string, superlikeLimit int) (SwipeResult, error)
+	CheckDailyLimit(ctx context.Context, userId, profileId int) (bool, error)
+	SuperlikeQuota(ctx context.Context, userId, profileId, limit, premiumLimit int) (*entity.SuperlikeQuota, error)
+	UndoSwipe(ctx context.Context, profileID int, since time.Time) (*entity.Swipe, error)
+	IsPremium(ctx context.Context, userId int) (bool, error)
+	FindReceivedLikes(ctx context.Context, profileID int) ([]entity.Swipe, error)
+	FindReceivedLike(ctx context.Context, profileID, likerID int) (*entity.Swipe, error)
+	Unmatch(ctx context.Context, matchID, profileID int) (*entity.Match, error)
 }
 
 type MatchRepository struct {
@@ -28,106 +51,368 @@ func NewMatchRepository(db *gorm.DB) MatchRepositoryInterface {
 	}
 }
 
-func (r *MatchRepository) FindMatchByProfileID(profileID int) ([]*entity.Profile, error) {
-	var matches []*entity.Profile
-	var matchesAsInitiator []entity.Match
-	if err := r.db.Preload("Partner").Where("profile_id = ? AND status = ?", profileID, entity.StatusAccepted).Find(&matchesAsInitiator).Error; err != nil {
+// matchRow is a match of a profile read with the partner's profile and name.
+type matchRow struct {
+	MatchID   int
+	MatchedAt time.Time
+	IsNew     bool
+	// Initiated tells that the profile reading the match liked first
+	Initiated bool
+	Name      string
+	entity.Profile
+}
+
+func (row *matchRow) item() entity.MatchListItem {
+	return entity.MatchListItem{
+		MatchID:   row.MatchID,
+		MatchedAt: row.MatchedAt,
+		IsNew:     row.IsNew,
+		Name:      row.Name,
+		Partner:   row.Profile.Public(),
+	}
+}
+
+// activeMatchesOf selects the active matches of profileID joined with the partner's profile and user,
+// whichever side of the match the partner is on.
+func (r *MatchRepository) activeMatchesOf(ctx context.Context, profileID int) *gorm.DB {
+	return r.db.WithContext(ctx).Table("matches").
+		Select("matches.id AS match_id, matches.created_at AS matched_at, "+
+			"(CASE WHEN matches.profile_id = ? THEN matches.profile_opened_at ELSE matches.partner_opened_at END) IS NULL AS is_new, "+
+			"matches.profile_id = ? AS initiated, users.name, profiles.*", profileID, profileID).
+		Joins("JOIN profiles ON profiles.id = CASE WHEN matches.profile_id = ? THEN matches.partner_id ELSE matches.profile_id END", profileID).
+		Joins("JOIN users ON users.id = profiles.user_id").
+		Where("(matches.profile_id = ? OR matches.partner_id = ?) AND matches.status = ?", profileID, profileID, entity.StatusAccepted)
+}
+
+// FindMatches returns up to limit active matches of profileID in a single query, the most recent
+// first. A non-zero afterMatchID continues after that match.
+func (r *MatchRepository) FindMatches(ctx context.Context, profileID, afterMatchID, limit int) ([]entity.MatchListItem, error) {
+	query := r.activeMatchesOf(ctx, profileID)
+	if afterMatchID != 0 {
+		query = query.Where("(matches.created_at, matches.id) < (?)", r.db.Table("matches").Select("created_at, id").Where("id = ?", afterMatchID))
+	}
+	var rows []matchRow
+	if err := query.Order("matches.created_at DESC, matches.id DESC").Limit(limit).Scan(&rows).Error; err != nil {
 		return nil, err
 	}
-	for _, match := range matchesAsInitiator {
-		matches = append(matches, &match.Partner)
+	items := make([]entity.MatchListItem, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, row.item())
 	}
+	return items, nil
+}
 
-	var matchesAsPartner []entity.Match
-	if err := r.db.Preload("Profile").Where("partner_id = ? AND status = ?", profileID, entity.StatusAccepted).Find(&matchesAsPartner).Error; err != nil {
+// OpenMatch returns an active match of profileID with the partner's whole gallery and records that
+// profileID opened it, so it is no longer new. It returns nil when there is no such match.
+func (r *MatchRepository) OpenMatch(ctx context.Context, matchID, profileID int) (*entity.MatchListItem, error) {
+	var row matchRow
+	if err := r.activeMatchesOf(ctx, profileID).Where("matches.id = ?", matchID).Limit(1).Scan(&row).Error; err != nil {
 		return nil, err
 	}
-	for _, match := range matchesAsPartner {
-		matches = append(matches, &match.Profile)
+	if row.MatchID == 0 {
+		return nil, nil
 	}
+	if row.IsNew {
+		column := "partner_opened_at"
+		if row.Initiated {
+			column = "profile_opened_at"
+		}
+		if err := r.db.WithContext(ctx).Model(&entity.Match{}).
+			Where("id = ? AND "+column+" IS NULL", matchID).
+			Update(column, time.Now()).Error; err != nil {
+			return nil, err
+		}
+		row.IsNew = false
+	}
+	if err := r.db.WithContext(ctx).Scopes(orderedPhotos).Where("profile_id = ?", row.Profile.ID).Find(&row.Profile.Photos).Error; err != nil {
+		return nil, err
+	}
+	item := row.item()
+	return &item, nil
+}
 
-	return matches, nil
+// Unmatch ends an active match of profileID, recording that profileID ended it. The match leaves
+// both match lists and the pair can no longer swipe or be shown to each other. ErrMatchNotFound is
+// returned when the match does not involve profileID or has already ended.
+func (r *MatchRepository) Unmatch(ctx context.Context, matchID, profileID int) (*entity.Match, error) {
+	var match entity.Match
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&match, matchID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrMatchNotFound
+			}
+			return err
+		}
+		if !match.Involves(profileID) || !match.IsActive() {
+			return ErrMatchNotFound
+		}
+		now := time.Now()
+		match.Status = entity.StatusUnmatched
+		match.UnmatchedBy = &profileID
+		match.UnmatchedAt = &now
+		return tx.Model(&match).Updates(map[string]interface{}{
+			"status":       match.Status,
+			"unmatched_by": profileID,
+			"unmatched_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &match, nil
 }
 
-func (r *MatchRepository) CheckMatch(profileID, partnerID int) (*entity.Match, error) {
+// FindReceivedLikes returns the likes of other profiles on profileID it has not answered yet, with
+// the liker's profile. Superlikes come first, then the latest likes.
+func (r *MatchRepository) FindReceivedLikes(ctx context.Context, profileID int) ([]entity.Swipe, error) {
+	var likes []entity.Swipe
+	if err := r.db.WithContext(ctx).Preload("Profile").Preload("Profile.Photos", orderedPhotos).
+		Scopes(awaitingAnswerOf(profileID)).
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "swipes.direction = ? DESC, swipes.updated_at DESC, swipes.id DESC", Vars: []interface{}{entity.SwipeSuperlike}}}).
+		Find(&likes).Error; err != nil {
+		return nil, err
+	}
+	return likes, nil
+}
 
-	var match entity.Match
-	if err := r.db.Where("profile_id = ? AND partner_id = ? AND status != ?", profileID, partnerID, entity.StatusRejected).First(&match).Error; err != nil {
+// FindReceivedLike returns the like of likerID on profileID if it is waiting for an answer, or nil.
+func (r *MatchRepository) FindReceivedLike(ctx context.Context, profileID, likerID int) (*entity.Swipe, error) {
+	var like entity.Swipe
+	if err := r.db.WithContext(ctx).Scopes(awaitingAnswerOf(profileID)).
+		Where("swipes.profile_id = ?", likerID).
+		First(&like).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
 		return nil, err
 	}
-	return &match, nil
+	return &like, nil
 }
 
-func (r *MatchRepository) AcceptMatch(profileID, partnerID int) error {
-	match, err := r.CheckMatch(partnerID, profileID)
-	if err != nil {
-		return err
+// awaitingAnswerOf keeps the likes on profileID that it has not swiped back, leaving out the
+// profiles of accounts pending deletion.
+func awaitingAnswerOf(profileID int) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		newDB := db.Session(&gorm.Session{NewDB: true})
+		answered := newDB.Table("swipes").Select("target_id").Where("profile_id = ?", profileID)
+		deleted := newDB.Table("profiles").Select("profiles.id").
+			Joins("JOIN users ON users.id = profiles.user_id").
+			Where("users.deletion_scheduled_at IS NOT NULL")
+		return db.Where("swipes.target_id = ? AND swipes.direction IN ?", profileID, []string{entity.SwipeLike, entity.SwipeSuperlike}).
+			Where("swipes.profile_id NOT IN (?)", answered).
+			Where("swipes.profile_id NOT IN (?)", deleted)
 	}
-	if match != nil {
-		if err := r.db.Model(&entity.Match{}).Where("profile_id = ? AND partner_id = ?", partnerID, profileID).Update("status", entity.StatusAccepted).Error; err != nil {
+}
+
+// Swipe records a swipe of profileID on targetID in a single transaction and creates the match when
+// a like meets a like of the target. Both profiles are locked in ID order so concurrent swipes on the
+// same pair are decided one after the other and two likes always end in a single match. Only a pass
+// can be swiped again, which turns it into a like or restarts its resurfacing window. A superlike is
+// refused once profileID sent superlikeLimit superlikes today.
+func (r *MatchRepository) Swipe(ctx context.Context, profileID, targetID int, direction string, superlikeLimit int) (SwipeResult, error) {
+	var result SwipeResult
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockPair(tx, profileID, targetID); err != nil {
+			return err
+		}
+		if err := checkNotMatched(tx, profileID, targetID); err != nil {
+			return err
+		}
+		sent, err := findSwipe(tx, profileID, targetID)
+		if err != nil {
 			return err
 		}
-		return nil
+		if sent != nil && sent.Direction != entity.SwipePass {
+			return ErrAlreadySwiped
+		}
+		if direction == entity.SwipeSuperlike {
+			// the swiper's profile is locked, so concurrent superlikes are counted one after the other
+			used, err := countSuperlikesSince(tx, profileID, startOfDay(time.Now()))
+			if err != nil {
+				return err
+			}
+			if used >= superlikeLimit {
+				return ErrSuperlikeLimit
+			}
+		}
+
+		swipe := &entity.Swipe{ProfileID: profileID, TargetID: targetID, Direction: direction}
+		if sent != nil {
+			swipe = sent
+			if err := tx.Model(swipe).Updates(map[string]interface{}{"direction": direction, "updated_at": time.Now()}).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Create(swipe).Error; err != nil {
+			return err
+		}
+		if !swipe.IsLike() {
+			result = SwipePassed
+			return nil
+		}
+
+		received, err := findSwipe(tx, targetID, profileID)
+		if err != nil {
+			return err
+		}
+		if received == nil || !received.IsLike() {
+			result = SwipeLiked
+			return nil
+		}
+		result = SwipeMatched
+		return tx.Create(&entity.Match{ProfileID: targetID, PartnerID: profileID, Status: entity.StatusAccepted}).Error
+	})
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return "", ErrAlreadySwiped
 	}
-	return nil
+	if err != nil {
+		return "", err
+	}
+	return result, nil
 }
 
-// CheckPendingMatch checks if there is a pending match between two profiles
-func (r *MatchRepository) CheckPendingMatch(profileID, partnerID int) (*entity.Match, error) {
-	var match entity.Match
-	if err := r.db.Where("profile_id = ? AND partner_id = ? AND status = ?", profileID, partnerID, entity.StatusPending).First(&match).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil
+// UndoSwipe deletes the most recent swipe of profileID if it was made after since, together with
+// today's view of the target so discovery serves it again. Deleting a pass also brings back the
+// target's like waiting for an answer. A swipe that produced a match cannot be undone.
+func (r *MatchRepository) UndoSwipe(ctx context.Context, profileID int, since time.Time) (*entity.Swipe, error) {
+	var swipe entity.Swipe
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// every swipe involving the profile locks it, so the last swipe cannot change meanwhile
+		var profile entity.Profile
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&profile, profileID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNothingToUndo
+			}
+			return err
+		}
+		if err := tx.Where("profile_id = ?", profileID).Order("updated_at DESC, id DESC").First(&swipe).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNothingToUndo
+			}
+			return err
 		}
+		if swipe.UpdatedAt.Before(since) {
+			return ErrNothingToUndo
+		}
+		if err := checkNotMatched(tx, profileID, swipe.TargetID); err != nil {
+			return err
+		}
+		if err := tx.Delete(&swipe).Error; err != nil {
+			return err
+		}
+		return tx.Where("viewer_id = ? AND profile_id = ? AND DATE(created_at) = DATE(NOW())", profileID, swipe.TargetID).
+			Delete(&entity.ProfileViewLog{}).Error
+	})
+	if err != nil {
 		return nil, err
 	}
-	return &match, nil
+	return &swipe, nil
 }
 
-func (r *MatchRepository) RejectMatch(profileID, partnerID int) error {
-	match, err := r.CheckMatch(partnerID, profileID)
-	if err != nil {
+// lockPair locks both profiles of a swipe. They are always locked in ID order so two opposite
+// swipes wait for each other instead of deadlocking.
+func lockPair(tx *gorm.DB, profileID, targetID int) error {
+	var profiles []entity.Profile
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+		Where("id IN ?", []int{profileID, targetID}).Order("id").Find(&profiles).Error; err != nil {
 		return err
 	}
-	if match != nil {
-		if err := r.db.Model(&entity.Match{}).Where("profile_id = ? AND partner_id = ?", partnerID, profileID).Update("status", entity.StatusRejected).Error; err != nil {
-			return err
-		}
-		return nil
+	if profileID == targetID || len(profiles) != 2 {
+		return ErrProfileNotFound
 	}
 	return nil
 }
 
-func (r *MatchRepository) CreateMatch(profileID, partnerID int) error {
-	match := &entity.Match{
-		ProfileID: profileID,
-		PartnerID: partnerID,
-		Status:    entity.StatusPending,
-	}
-	if err := r.db.Create(match).Error; err != nil {
+// checkNotMatched returns ErrAlreadyMatched when the two profiles matched.
+func checkNotMatched(tx *gorm.DB, profileID, partnerID int) error {
+	var matched int64
+	if err := tx.Model(&entity.Match{}).
+		Where("(profile_id = ? AND partner_id = ?) OR (profile_id = ? AND partner_id = ?)", profileID, partnerID, partnerID, profileID).
+		Count(&matched).Error; err != nil {
 		return err
 	}
+	if matched > 0 {
+		return ErrAlreadyMatched
+	}
 	return nil
 }
 
-func (r *MatchRepository) CheckDailyLimit(ctx echo.Context) (bool, error) {
-	var isPremium bool
-	query := "SELECT EXISTS(SELECT 1 FROM subscriptions WHERE user_id = ? AND valid_until > NOW()) AS isPremium"
-	if err := r.db.Raw(query, ctx.Get("user_id").(int)).Scan(&isPremium).Error; err != nil {
+// findSwipe returns the swipe of profileID on targetID, or nil when there is none.
+func findSwipe(tx *gorm.DB, profileID, targetID int) (*entity.Swipe, error) {
+	var swipe entity.Swipe
+	if err := tx.Where("profile_id = ? AND target_id = ?", profileID, targetID).First(&swipe).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &swipe, nil
+}
+
+// countSuperlikesSince counts the superlikes sent by profileID since the given time. A pass turned
+// into a superlike counts from the time it was changed.
+func countSuperlikesSince(tx *gorm.DB, profileID int, since time.Time) (int, error) {
+	var count int64
+	if err := tx.Model(&entity.Swipe{}).
+		Where("profile_id = ? AND direction = ? AND updated_at >= ?", profileID, entity.SwipeSuperlike, since).
+		Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return int(count), nil
+}
+
+// startOfDay returns midnight of the day of now, when the daily quotas reset.
+func startOfDay(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
+
+// CheckDailyLimit reports whether the user may view another profile today. Subscriptions belong to
+// the user while view logs are recorded for the profile.
+func (r *MatchRepository) CheckDailyLimit(ctx context.Context, userId, profileId int) (bool, error) {
+	isPremium, err := r.IsPremium(ctx, userId)
+	if err != nil {
 		return false, err
 	}
 	if isPremium {
 		return true, nil
 	}
-	viewerId := ctx.Get("profile_id").(int)
 	var count int64
-	if err := r.db.Model(&entity.ProfileViewLog{}).
-		Where("viewer_id = ? AND DATE(created_at) = DATE(NOW())", viewerId).
+	if err := r.db.WithContext(ctx).Model(&entity.ProfileViewLog{}).
+		Where("viewer_id = ? AND DATE(created_at) = DATE(NOW())", profileId).
 		Count(&count).Error; err != nil {
 		return false, err
 	}
 	return count < 10, nil
 }
+
+// SuperlikeQuota returns the superlikes the user's profile sent today out of limit, or premiumLimit
+// with an active subscription.
+func (r *MatchRepository) SuperlikeQuota(ctx context.Context, userId, profileId, limit, premiumLimit int) (*entity.SuperlikeQuota, error) {
+	isPremium, err := r.IsPremium(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if isPremium {
+		limit = premiumLimit
+	}
+	today := startOfDay(time.Now())
+	used, err := countSuperlikesSince(r.db.WithContext(ctx), profileId, today)
+	if err != nil {
+		return nil, err
+	}
+	return &entity.SuperlikeQuota{
+		Limit:     limit,
+		Used:      used,
+		Remaining: max(limit-used, 0),
+		ResetsAt:  today.AddDate(0, 0, 1),
+	}, nil
+}
+
+// IsPremium reports whether the user has an active subscription.
+func (r *MatchRepository) IsPremium(ctx context.Context, userId int) (bool, error) {
+	var isPremium bool
+	query := "SELECT EXISTS(SELECT 1 FROM subscriptions WHERE user_id = ? AND valid_until > NOW()) AS isPremium"
+	if err := r.db.WithContext(ctx).Raw(query, userId).Scan(&isPremium).Error; err != nil {
+		return false, err
+	}
+	return isPremium, nil
+}
@@ -0,0 +1,431 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"main/entity"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func TestCheckDailyLimitUsesUserAndProfileIDs(t *testing.T) {
+	db, fake := newFakeDB(t, nil)
+	repo := NewMatchRepository(db)
+
+	allowed, err := repo.CheckDailyLimit(context.Background(), 10, 1)
+
+	assert.NoError(t, err)
+	assert.True(t, allowed)
+	assert.Contains(t, fake.statement(t, "subscriptions"), "user_id = 10 ")
+	assert.Contains(t, fake.statement(t, "profile_view_logs"), "viewer_id = 1 ")
+}
+
+func TestFindReceivedLikesLeavesOutAnswered(t *testing.T) {
+	db, fake := newFakeDB(t, nil)
+	repo := NewMatchRepository(db)
+
+	_, err := repo.FindReceivedLikes(context.Background(), 1)
+
+	assert.NoError(t, err)
+	query := fake.statement(t, `FROM "swipes"`)
+	assert.Contains(t, query, "swipes.target_id = 1 AND swipes.direction IN ('like','superlike')")
+	assert.Contains(t, query, `swipes.profile_id NOT IN (SELECT target_id FROM "swipes" WHERE profile_id = 1)`)
+	assert.Contains(t, query, "users.deletion_scheduled_at IS NOT NULL")
+	assert.Contains(t, query, "ORDER BY swipes.direction = 'superlike' DESC")
+}
+
+func TestFindMatchesReadsAPageInOneQuery(t *testing.T) {
+	matchedAt := time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC)
+	db, fake := newFakeDB(t, map[string]fakeResult{`FROM "matches"`: {
+		columns: []string{"match_id", "matched_at", "is_new", "initiated", "name", "id", "user_id", "picture", "interested_in", "interests"},
+		rows:    [][]driver.Value{{int64(8), matchedAt, true, false, "Jane", int64(2), int64(20), "jane.jpg", "[]", `["hiking"]`}},
+	}})
+	repo := NewMatchRepository(db)
+
+	matches, err := repo.FindMatches(context.Background(), 1, 9, 21)
+
+	assert.NoError(t, err)
+	assert.Len(t, fake.statements, 1)
+	query := fake.statement(t, `FROM "matches"`)
+	assert.Contains(t, query, "JOIN profiles ON profiles.id = CASE WHEN matches.profile_id = 1 THEN matches.partner_id ELSE matches.profile_id END")
+	assert.Contains(t, query, "(matches.created_at, matches.id) < (SELECT created_at, id FROM \"matches\" WHERE id = 9)")
+	assert.Contains(t, query, "matches.status = 'accepted'")
+	assert.Contains(t, query, "ORDER BY matches.created_at DESC, matches.id DESC LIMIT 21")
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, 8, matches[0].MatchID)
+		assert.True(t, matches[0].MatchedAt.Equal(matchedAt))
+		assert.True(t, matches[0].IsNew)
+		assert.Equal(t, "Jane", matches[0].Name)
+		assert.Equal(t, uint(2), matches[0].Partner.ID)
+		assert.Equal(t, "jane.jpg", matches[0].Partner.Picture)
+		assert.Equal(t, []string{"hiking"}, matches[0].Partner.Interests)
+	}
+}
+
+// swipeConcurrently runs every swipe at the same moment and returns the results and errors.
+func swipeConcurrently(repo MatchRepositoryInterface, swipes [][2]int) ([]SwipeResult, []error) {
+	results := make([]SwipeResult, len(swipes))
+	errs := make([]error, len(swipes))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, swipe := range swipes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			results[i], errs[i] = repo.Swipe(context.Background(), swipe[0], swipe[1], entity.SwipeLike, 0)
+		}()
+	}
+	close(start)
+	wg.Wait()
+	return results, errs
+}
+
+func findMatches(t *testing.T, db *gorm.DB, a, b int) []entity.Match {
+	var matches []entity.Match
+	if err := db.Where("(profile_id = ? AND partner_id = ?) OR (profile_id = ? AND partner_id = ?)", a, b, b, a).Find(&matches).Error; err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
+
+func findSwipes(t *testing.T, db *gorm.DB, profileID, targetID int) []entity.Swipe {
+	var swipes []entity.Swipe
+	if err := db.Where("profile_id = ? AND target_id = ?", profileID, targetID).Find(&swipes).Error; err != nil {
+		t.Fatal(err)
+	}
+	return swipes
+}
+
+func TestSwipeConcurrentMutualLikesMatch(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewMatchRepository(db)
+
+	for round := 0; round < 10; round++ {
+		a, b := int(createTestProfile(t, db).ID), int(createTestProfile(t, db).ID)
+		swipes := make([][2]int, 0, 20)
+		for i := 0; i < 10; i++ {
+			swipes = append(swipes, [2]int{a, b}, [2]int{b, a})
+		}
+
+		results, errs := swipeConcurrently(repo, swipes)
+
+		counts := map[SwipeResult]int{}
+		for i, err := range errs {
+			if err != nil {
+				assert.True(t, errors.Is(err, ErrAlreadySwiped) || errors.Is(err, ErrAlreadyMatched), "unexpected error %v", err)
+				continue
+			}
+			counts[results[i]]++
+		}
+		assert.Equal(t, map[SwipeResult]int{SwipeLiked: 1, SwipeMatched: 1}, counts)
+		assert.Len(t, findMatches(t, db, a, b), 1)
+		assert.Len(t, findSwipes(t, db, a, b), 1)
+		assert.Len(t, findSwipes(t, db, b, a), 1)
+	}
+}
+
+func TestSwipeConcurrentDuplicateLikes(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewMatchRepository(db)
+	a, b := int(createTestProfile(t, db).ID), int(createTestProfile(t, db).ID)
+	swipes := make([][2]int, 20)
+	for i := range swipes {
+		swipes[i] = [2]int{a, b}
+	}
+
+	results, errs := swipeConcurrently(repo, swipes)
+
+	liked := 0
+	for i, err := range errs {
+		if err != nil {
+			assert.ErrorIs(t, err, ErrAlreadySwiped)
+			continue
+		}
+		assert.Equal(t, SwipeLiked, results[i])
+		liked++
+	}
+	assert.Equal(t, 1, liked)
+	assert.Empty(t, findMatches(t, db, a, b))
+	if sent := findSwipes(t, db, a, b); assert.Len(t, sent, 1) {
+		assert.Equal(t, entity.SwipeLike, sent[0].Direction)
+	}
+}
+
+func TestSwipeUniquePair(t *testing.T) {
+	db := newTestDB(t)
+	a, b := int(createTestProfile(t, db).ID), int(createTestProfile(t, db).ID)
+
+	assert.NoError(t, db.Create(&entity.Swipe{ProfileID: a, TargetID: b, Direction: entity.SwipeLike}).Error)
+	assert.ErrorIs(t, db.Create(&entity.Swipe{ProfileID: a, TargetID: b, Direction: entity.SwipePass}).Error, gorm.ErrDuplicatedKey)
+	assert.NoError(t, db.Create(&entity.Match{ProfileID: a, PartnerID: b, Status: entity.StatusAccepted}).Error)
+	assert.ErrorIs(t, db.Create(&entity.Match{ProfileID: b, PartnerID: a, Status: entity.StatusAccepted}).Error, gorm.ErrDuplicatedKey)
+}
+
+func TestSwipePassOnReceivedLike(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewMatchRepository(db)
+	ctx := context.Background()
+	a, b := int(createTestProfile(t, db).ID), int(createTestProfile(t, db).ID)
+
+	result, err := repo.Swipe(ctx, b, a, entity.SwipeLike, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, SwipeLiked, result)
+	result, err = repo.Swipe(ctx, a, b, entity.SwipePass, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, SwipePassed, result)
+
+	assert.Empty(t, findMatches(t, db, a, b))
+	if sent := findSwipes(t, db, a, b); assert.Len(t, sent, 1) {
+		assert.Equal(t, entity.SwipePass, sent[0].Direction)
+	}
+	_, err = repo.Swipe(ctx, b, a, entity.SwipeLike, 0)
+	assert.ErrorIs(t, err, ErrAlreadySwiped)
+}
+
+func TestSwipeLikeAfterPass(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewMatchRepository(db)
+	ctx := context.Background()
+	a, b := int(createTestProfile(t, db).ID), int(createTestProfile(t, db).ID)
+
+	_, err := repo.Swipe(ctx, b, a, entity.SwipeLike, 0)
+	assert.NoError(t, err)
+	_, err = repo.Swipe(ctx, a, b, entity.SwipePass, 0)
+	assert.NoError(t, err)
+	_, err = repo.Swipe(ctx, a, b, entity.SwipePass, 0)
+	assert.NoError(t, err)
+	result, err := repo.Swipe(ctx, a, b, entity.SwipeLike, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, SwipeMatched, result)
+
+	if matches := findMatches(t, db, a, b); assert.Len(t, matches, 1) {
+		assert.Equal(t, b, matches[0].ProfileID)
+		assert.Equal(t, entity.StatusAccepted, matches[0].Status)
+	}
+	if sent := findSwipes(t, db, a, b); assert.Len(t, sent, 1) {
+		assert.Equal(t, entity.SwipeLike, sent[0].Direction)
+	}
+}
+
+func TestSwipeUnknownProfile(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewMatchRepository(db)
+	a := int(createTestProfile(t, db).ID)
+
+	_, err := repo.Swipe(context.Background(), a, 0, entity.SwipeLike, 0)
+
+	assert.ErrorIs(t, err, ErrProfileNotFound)
+}
+
+func TestSwipeSuperlikeLimit(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewMatchRepository(db)
+	ctx := context.Background()
+	profile := createTestProfile(t, db)
+	a := int(profile.ID)
+	b, c := int(createTestProfile(t, db).ID), int(createTestProfile(t, db).ID)
+
+	result, err := repo.Swipe(ctx, a, b, entity.SwipeSuperlike, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, SwipeLiked, result)
+	_, err = repo.Swipe(ctx, a, c, entity.SwipeSuperlike, 1)
+	assert.ErrorIs(t, err, ErrSuperlikeLimit)
+	_, err = repo.Swipe(ctx, a, c, entity.SwipeLike, 1)
+	assert.NoError(t, err)
+
+	quota, err := repo.SuperlikeQuota(ctx, int(profile.UserID), a, 1, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, quota.Limit)
+	assert.Equal(t, 1, quota.Used)
+	assert.Equal(t, 0, quota.Remaining)
+	assert.True(t, quota.ResetsAt.After(time.Now()))
+}
+
+func TestSwipeConcurrentSuperlikes(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewMatchRepository(db)
+	a := int(createTestProfile(t, db).ID)
+	targets := make([]int, 10)
+	for i := range targets {
+		targets[i] = int(createTestProfile(t, db).ID)
+	}
+
+	errs := make([]error, len(targets))
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, errs[i] = repo.Swipe(context.Background(), a, target, entity.SwipeSuperlike, 3)
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	sent := 0
+	for _, err := range errs {
+		if err != nil {
+			assert.ErrorIs(t, err, ErrSuperlikeLimit)
+			continue
+		}
+		sent++
+	}
+	assert.Equal(t, 3, sent)
+}
+
+func TestUndoSwipeRestoresReceivedLike(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewMatchRepository(db)
+	ctx := context.Background()
+	a, b := int(createTestProfile(t, db).ID), int(createTestProfile(t, db).ID)
+	_, err := repo.Swipe(ctx, b, a, entity.SwipeLike, 0)
+	assert.NoError(t, err)
+	_, err = repo.Swipe(ctx, a, b, entity.SwipePass, 0)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Create(&entity.ProfileViewLog{ViewerID: uint(a), ProfileID: uint(b)}).Error)
+
+	swipe, err := repo.UndoSwipe(ctx, a, time.Now().Add(-time.Minute))
+
+	assert.NoError(t, err)
+	assert.Equal(t, entity.SwipePass, swipe.Direction)
+	assert.Empty(t, findSwipes(t, db, a, b))
+	var views int64
+	db.Model(&entity.ProfileViewLog{}).Where("viewer_id = ? AND profile_id = ?", a, b).Count(&views)
+	assert.Zero(t, views)
+	result, err := repo.Swipe(ctx, a, b, entity.SwipeLike, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, SwipeMatched, result)
+}
+
+func TestUndoSwipeRefusesMatch(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewMatchRepository(db)
+	ctx := context.Background()
+	a, b := int(createTestProfile(t, db).ID), int(createTestProfile(t, db).ID)
+	_, err := repo.Swipe(ctx, a, b, entity.SwipeLike, 0)
+	assert.NoError(t, err)
+	_, err = repo.Swipe(ctx, b, a, entity.SwipeLike, 0)
+	assert.NoError(t, err)
+
+	_, err = repo.UndoSwipe(ctx, b, time.Now().Add(-time.Minute))
+
+	assert.ErrorIs(t, err, ErrAlreadyMatched)
+	assert.Len(t, findSwipes(t, db, b, a), 1)
+	assert.Len(t, findMatches(t, db, a, b), 1)
+}
+
+func TestUndoSwipeOutsideWindow(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewMatchRepository(db)
+	ctx := context.Background()
+	a, b := int(createTestProfile(t, db).ID), int(createTestProfile(t, db).ID)
+	_, err := repo.Swipe(ctx, a, b, entity.SwipeLike, 0)
+	assert.NoError(t, err)
+
+	_, err = repo.UndoSwipe(ctx, a, time.Now().Add(time.Minute))
+
+	assert.ErrorIs(t, err, ErrNothingToUndo)
+	assert.Len(t, findSwipes(t, db, a, b), 1)
+}
+
+func TestUnmatch(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewMatchRepository(db)
+	ctx := context.Background()
+	a, b, c := int(createTestProfile(t, db).ID), int(createTestProfile(t, db).ID), int(createTestProfile(t, db).ID)
+	_, err := repo.Swipe(ctx, a, b, entity.SwipeLike, 0)
+	assert.NoError(t, err)
+	_, err = repo.Swipe(ctx, b, a, entity.SwipeLike, 0)
+	assert.NoError(t, err)
+	matchID := findMatches(t, db, a, b)[0].ID
+
+	_, err = repo.Unmatch(ctx, matchID, c)
+	assert.ErrorIs(t, err, ErrMatchNotFound)
+	match, err := repo.Unmatch(ctx, matchID, b)
+	assert.NoError(t, err)
+	assert.Equal(t, entity.StatusUnmatched, match.Status)
+	_, err = repo.Unmatch(ctx, matchID, a)
+	assert.ErrorIs(t, err, ErrMatchNotFound)
+
+	if matches := findMatches(t, db, a, b); assert.Len(t, matches, 1) {
+		assert.Equal(t, entity.StatusUnmatched, matches[0].Status)
+		assert.Equal(t, b, *matches[0].UnmatchedBy)
+		assert.NotNil(t, matches[0].UnmatchedAt)
+	}
+	for _, profileID := range []int{a, b} {
+		matches, err := repo.FindMatches(ctx, profileID, 0, 10)
+		assert.NoError(t, err)
+		assert.Empty(t, matches)
+	}
+	_, err = repo.Swipe(ctx, a, b, entity.SwipeLike, 0)
+	assert.ErrorIs(t, err, ErrAlreadyMatched)
+	_, err = repo.UndoSwipe(ctx, b, time.Now().Add(-time.Minute))
+	assert.ErrorIs(t, err, ErrAlreadyMatched)
+}
+
+func TestFindMatchesPaginates(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewMatchRepository(db)
+	ctx := context.Background()
+	a := int(createTestProfile(t, db).ID)
+	var partners []int
+	for i := 0; i < 3; i++ {
+		b := int(createTestProfile(t, db).ID)
+		_, err := repo.Swipe(ctx, a, b, entity.SwipeLike, 0)
+		assert.NoError(t, err)
+		_, err = repo.Swipe(ctx, b, a, entity.SwipeLike, 0)
+		assert.NoError(t, err)
+		partners = append(partners, b)
+	}
+
+	first, err := repo.FindMatches(ctx, a, 0, 2)
+	assert.NoError(t, err)
+	second, err := repo.FindMatches(ctx, a, first[len(first)-1].MatchID, 2)
+	assert.NoError(t, err)
+
+	if assert.Len(t, first, 2) && assert.Len(t, second, 1) {
+		assert.Equal(t, uint(partners[2]), first[0].Partner.ID)
+		assert.Equal(t, uint(partners[1]), first[1].Partner.ID)
+		assert.Equal(t, uint(partners[0]), second[0].Partner.ID)
+		assert.Equal(t, "Test", first[0].Name)
+	}
+}
+
+func TestOpenMatchClearsNewFlagForOneSide(t *testing.T) {
+	db := newTestDB(t)
+	repo := NewMatchRepository(db)
+	ctx := context.Background()
+	a, b, c := int(createTestProfile(t, db).ID), int(createTestProfile(t, db).ID), int(createTestProfile(t, db).ID)
+	_, err := repo.Swipe(ctx, a, b, entity.SwipeLike, 0)
+	assert.NoError(t, err)
+	_, err = repo.Swipe(ctx, b, a, entity.SwipeLike, 0)
+	assert.NoError(t, err)
+	matchID := findMatches(t, db, a, b)[0].ID
+
+	opened, err := repo.OpenMatch(ctx, matchID, c)
+	assert.NoError(t, err)
+	assert.Nil(t, opened)
+	opened, err = repo.OpenMatch(ctx, matchID, b)
+	assert.NoError(t, err)
+	if assert.NotNil(t, opened) {
+		assert.Equal(t, uint(a), opened.Partner.ID)
+		assert.False(t, opened.IsNew)
+	}
+
+	forA, err := repo.FindMatches(ctx, a, 0, 10)
+	assert.NoError(t, err)
+	forB, err := repo.FindMatches(ctx, b, 0, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, forA, 1) && assert.Len(t, forB, 1) {
+		assert.True(t, forA[0].IsNew)
+		assert.False(t, forB[0].IsNew)
+	}
+}
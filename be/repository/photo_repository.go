@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"main/entity"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrPhotoLimitReached = errors.New("photo limit reached")
+	ErrInvalidPhotoOrder = errors.New("photo order must list every photo once")
+)
+
+type PhotoRepositoryInterface interface {
+	ListByProfileID(ctx context.Context, profileID int) ([]entity.ProfilePhoto, error)
+	Create(ctx context.Context, photo *entity.ProfilePhoto, maxCount int) error
+	Delete(ctx context.Context, profileID int, id int) (*entity.ProfilePhoto, error)
+	Reorder(ctx context.Context, profileID int, ids []uint) error
+	SetPrimary(ctx context.Context, profileID int, id int) (bool, error)
+}
+
+type PhotoRepository struct {
+	db *gorm.DB
+}
+
+func NewPhotoRepository(db *gorm.DB) PhotoRepositoryInterface {
+	return &PhotoRepository{
+		db: db,
+	}
+}
+
+func (r *PhotoRepository) ListByProfileID(ctx context.Context, profileID int) ([]entity.ProfilePhoto, error) {
+	var photos []entity.ProfilePhoto
+	if err := r.db.WithContext(ctx).Where("profile_id = ?", profileID).Order("position").Find(&photos).Error; err != nil {
+		return nil, err
+	}
+	return photos, nil
+}
+
+// Create appends the photo to the end of the gallery. The first photo becomes the primary one.
+// ErrPhotoLimitReached is returned when the gallery already holds maxCount photos.
+func (r *PhotoRepository) Create(ctx context.Context, photo *entity.ProfilePhoto, maxCount int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockProfile(tx, int(photo.ProfileID)); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&entity.ProfilePhoto{}).Where("profile_id = ?", photo.ProfileID).Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count) >= maxCount {
+			return ErrPhotoLimitReached
+		}
+		photo.Position = int(count)
+		photo.IsPrimary = count == 0
+		if err := tx.Create(photo).Error; err != nil {
+			return err
+		}
+		return syncPicture(tx, int(photo.ProfileID))
+	})
+}
+
+// Delete removes the photo and closes the gap in the ordering. When the primary photo is removed the
+// next photo becomes primary. The deleted photo is returned so its blobs can be removed, or nil when
+// the profile has no such photo.
+func (r *PhotoRepository) Delete(ctx context.Context, profileID int, id int) (*entity.ProfilePhoto, error) {
+	var deleted *entity.ProfilePhoto
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockProfile(tx, profileID); err != nil {
+			return err
+		}
+		var photos []entity.ProfilePhoto
+		if err := tx.Clauses(clause.Returning{}).Where("id = ? AND profile_id = ?", id, profileID).Delete(&photos).Error; err != nil {
+			return err
+		}
+		if len(photos) == 0 {
+			return nil
+		}
+		deleted = &photos[0]
+
+		if err := tx.Model(&entity.ProfilePhoto{}).
+			Where("profile_id = ? AND position > ?", profileID, deleted.Position).
+			Update("position", gorm.Expr("position - 1")).Error; err != nil {
+			return err
+		}
+		if deleted.IsPrimary {
+			if err := tx.Model(&entity.ProfilePhoto{}).
+				Where("profile_id = ? AND position = 0", profileID).
+				Update("is_primary", true).Error; err != nil {
+				return err
+			}
+		}
+		return syncPicture(tx, profileID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// Reorder sets the position of every photo of the gallery from its index in ids.
+// ErrInvalidPhotoOrder is returned unless ids lists each photo of the profile exactly once.
+func (r *PhotoRepository) Reorder(ctx context.Context, profileID int, ids []uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockProfile(tx, profileID); err != nil {
+			return err
+		}
+		var existing []uint
+		if err := tx.Model(&entity.ProfilePhoto{}).Where("profile_id = ?", profileID).Pluck("id", &existing).Error; err != nil {
+			return err
+		}
+		if len(existing) != len(ids) {
+			return ErrInvalidPhotoOrder
+		}
+		remaining := map[uint]bool{}
+		for _, id := range existing {
+			remaining[id] = true
+		}
+		for _, id := range ids {
+			if !remaining[id] {
+				return ErrInvalidPhotoOrder
+			}
+			delete(remaining, id)
+		}
+
+		for position, id := range ids {
+			if err := tx.Model(&entity.ProfilePhoto{}).Where("id = ?", id).Update("position", position).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetPrimary makes the photo the primary one of the gallery. false is returned when the profile
+// has no such photo.
+func (r *PhotoRepository) SetPrimary(ctx context.Context, profileID int, id int) (bool, error) {
+	found := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := lockProfile(tx, profileID); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&entity.ProfilePhoto{}).Where("id = ? AND profile_id = ?", id, profileID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return nil
+		}
+		found = true
+
+		if err := tx.Model(&entity.ProfilePhoto{}).
+			Where("profile_id = ? AND is_primary", profileID).
+			Update("is_primary", false).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&entity.ProfilePhoto{}).Where("id = ?", id).Update("is_primary", true).Error; err != nil {
+			return err
+		}
+		return syncPicture(tx, profileID)
+	})
+	if err != nil {
+		return false, err
+	}
+	return found, nil
+}
+
+// lockProfile serializes the changes to the gallery of a profile.
+func lockProfile(tx *gorm.DB, profileID int) error {
+	var profile entity.Profile
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&profile, profileID).Error
+}
+
+// syncPicture keeps profiles.picture pointing at the full size variant of the primary photo.
+func syncPicture(tx *gorm.DB, profileID int) error {
+	return tx.Model(&entity.Profile{}).Where("id = ?", profileID).
+		Update("picture", gorm.Expr("COALESCE((SELECT full_url FROM profile_photos WHERE profile_id = ? AND is_primary), '')", profileID)).Error
+}
+
+// orderedPhotos preloads a gallery in its display order.
+func orderedPhotos(db *gorm.DB) *gorm.DB {
+	return db.Order("position")
+}
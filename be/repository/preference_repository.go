@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"main/entity"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type PreferenceRepositoryInterface interface {
+	FindByUserID(ctx context.Context, userID int) (*entity.DiscoveryPreference, error)
+	Save(ctx context.Context, preference *entity.DiscoveryPreference) error
+}
+
+type PreferenceRepository struct {
+	db *gorm.DB
+}
+
+func NewPreferenceRepository(db *gorm.DB) PreferenceRepositoryInterface {
+	return &PreferenceRepository{
+		db: db,
+	}
+}
+
+// FindByUserID returns the discovery preferences of the user, or nil when the user has not set any.
+func (r *PreferenceRepository) FindByUserID(ctx context.Context, userID int) (*entity.DiscoveryPreference, error) {
+	var preference entity.DiscoveryPreference
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&preference).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &preference, nil
+}
+
+// Save creates or replaces the discovery preferences of preference.UserID.
+func (r *PreferenceRepository) Save(ctx context.Context, preference *entity.DiscoveryPreference) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"min_age", "max_age", "genders", "max_distance_km", "only_with_photo", "updated_at"}),
+	}).Create(preference).Error
+}
@@ -1,18 +1,23 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"main/entity"
+	"time"
 
-	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrNoMoreProfiles = errors.New("no more profiles")
+
 type ProfileRepositoryInterface interface {
-	FindByUserID(userId int) (*entity.Profile, error)
-	FindByID(id int) (*entity.Profile, error)
-	Save(profile *entity.Profile) (*entity.Profile, error)
-	GetRandomProfile(c echo.Context) (*entity.Profile, error)
-	SaveViewLog(c echo.Context, profileId int) error
+	FindByUserID(ctx context.Context, userId int) (*entity.Profile, error)
+	FindByID(ctx context.Context, id int) (*entity.Profile, error)
+	Save(ctx context.Context, profile *entity.Profile) (*entity.Profile, error)
+	GetRandomProfile(ctx context.Context, viewerId int, passResurface time.Duration) (*entity.Profile, error)
+	SaveViewLog(ctx context.Context, viewerId, profileId int) error
 }
 
 type ProfileRepository struct {
@@ -25,48 +30,174 @@ func NewProfileRepository(db *gorm.DB) ProfileRepositoryInterface {
 	}
 }
 
-func (r *ProfileRepository) FindByID(id int) (*entity.Profile, error) {
+func (r *ProfileRepository) FindByID(ctx context.Context, id int) (*entity.Profile, error) {
 	var profile entity.Profile
-	if err := r.db.First(&profile, id).Error; err != nil {
+	if err := r.db.WithContext(ctx).First(&profile, id).Error; err != nil {
 		return nil, err
 	}
 	return &profile, nil
 }
 
-func (r *ProfileRepository) Save(profile *entity.Profile) (*entity.Profile, error) {
-	if err := r.db.Save(profile).Error; err != nil {
+func (r *ProfileRepository) Save(ctx context.Context, profile *entity.Profile) (*entity.Profile, error) {
+	if err := r.db.WithContext(ctx).Omit(clause.Associations).Save(profile).Error; err != nil {
 		return nil, err
 	}
 	return profile, nil
 }
 
-func (r *ProfileRepository) GetRandomProfile(ctx echo.Context) (*entity.Profile, error) {
-	viewerId := ctx.Get("user_id").(int)
+// GetRandomProfile picks a random candidate for the viewer's profile that matches the viewer's
+// discovery preferences and whose own preferences accept the viewer. Profiles the viewer swiped
+// left on come back after passResurface, or never when it is zero. Candidates who superliked the
+// viewer are picked before the others. The distance to the viewer is read with the same
+// earthdistance functions as the radius, and computed in Go when the row carries none.
+// ErrNoMoreProfiles is returned when no candidate is left.
+func (r *ProfileRepository) GetRandomProfile(ctx context.Context, viewerId int, passResurface time.Duration) (*entity.Profile, error) {
+	viewer, err := r.FindByID(ctx, viewerId)
+	if err != nil {
+		return nil, err
+	}
+	var preference *entity.DiscoveryPreference
+	var found entity.DiscoveryPreference
+	if err := r.db.WithContext(ctx).Where("user_id = ?", viewer.UserID).First(&found).Error; err == nil {
+		preference = &found
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	// profiles that superliked the viewer come first
+	superliked := r.db.Table("swipes").Select("1").
+		Where("swipes.profile_id = profiles.id AND swipes.target_id = ? AND swipes.direction = ?", viewer.ID, entity.SwipeSuperlike)
+	columns, args := "profiles.*, EXISTS (?) AS superliked", []interface{}{superliked}
+	if viewer.HasLocation() {
+		columns += ", earth_distance(?, ll_to_earth(profiles.latitude, profiles.longitude)) / 1000 AS distance_km"
+		args = append(args, viewerPoint(viewer))
+	}
 	var profile entity.Profile
-	if err := r.db.
-		Where("id NOT IN (?)", r.db.Table("profile_view_logs").Select("profile_id").Where("viewer_id = ? AND DATE(created_at) = DATE(NOW())", viewerId)).
+	if err := r.db.WithContext(ctx).
+		Select(columns, args...).
+		Preload("Photos", orderedPhotos).
+		Joins("LEFT JOIN discovery_preferences ON discovery_preferences.user_id = profiles.user_id").
+		Where("profiles.user_id NOT IN (?)", r.db.Table("users").Select("id").Where("deletion_scheduled_at IS NOT NULL")).
+		Scopes(notViewedToday(viewer), notSwipedBy(viewer, passResurface, time.Now()), wantedBy(viewer, preference, time.Now()), accepts(viewer, time.Now())).
+		Order("superliked DESC").
 		Order("RANDOM()").
 		First(&profile).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNoMoreProfiles
+		}
 		return nil, err
 	}
+	if profile.DistanceKm == nil {
+		profile.DistanceKm = viewer.DistanceKmTo(&profile)
+	}
 	return &profile, nil
 }
 
-func (r *ProfileRepository) SaveViewLog(ctx echo.Context, profileId int) error {
-	viewerId := ctx.Get("profile_id").(int)
+// notViewedToday drops the profiles the viewer was shown today. View logs are keyed by profile ID.
+func notViewedToday(viewer *entity.Profile) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		viewed := db.Session(&gorm.Session{NewDB: true}).Table("profile_view_logs").Select("profile_id").
+			Where("viewer_id = ? AND DATE(created_at) = DATE(NOW())", viewer.ID)
+		return db.Where("profiles.id NOT IN (?)", viewed)
+	}
+}
+
+// notSwipedBy drops the viewer's own profile and every profile the viewer swiped, except those the
+// viewer passed on longer than passResurface ago. Profiles the viewer matched with are dropped even
+// after the match ended, whatever happened to the swipes.
+func notSwipedBy(viewer *entity.Profile, passResurface time.Duration, now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		newDB := db.Session(&gorm.Session{NewDB: true})
+		swiped := newDB.Table("swipes").Select("target_id").Where("profile_id = ?", viewer.ID)
+		if passResurface > 0 {
+			swiped = swiped.Where("(direction <> ? OR updated_at > ?)", entity.SwipePass, now.Add(-passResurface))
+		}
+		matched := newDB.Table("matches").Select("partner_id").Where("profile_id = ?", viewer.ID)
+		matchedBack := newDB.Table("matches").Select("profile_id").Where("partner_id = ?", viewer.ID)
+		return db.Where("profiles.id <> ?", viewer.ID).
+			Where("profiles.id NOT IN (?)", swiped).
+			Where("profiles.id NOT IN (?)", matched).
+			Where("profiles.id NOT IN (?)", matchedBack)
+	}
+}
+
+// wantedBy keeps the profiles that match the viewer's preferences, or the genders the viewer's
+// profile is interested in when the viewer has none. Once the viewer's location is known, only
+// candidates with a location inside the viewer's radius are kept, even without preferences. A
+// candidate must have a birthdate to match an age range.
+func wantedBy(viewer *entity.Profile, preference *entity.DiscoveryPreference, now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if viewer.HasLocation() {
+			db = db.Where("profiles.latitude IS NOT NULL AND profiles.longitude IS NOT NULL").
+				Where("earth_box(?, ?) @> ll_to_earth(profiles.latitude, profiles.longitude)", viewerPoint(viewer), preference.RadiusKm()*1000).
+				Where("earth_distance(?, ll_to_earth(profiles.latitude, profiles.longitude)) <= ?", viewerPoint(viewer), preference.RadiusKm()*1000)
+		}
+		if preference == nil {
+			if len(viewer.InterestedIn) > 0 {
+				db = db.Where("profiles.gender IN ?", viewer.InterestedIn)
+			}
+			return db
+		}
+		earliest, latest := preference.BirthdateRange(now)
+		db = db.Where("profiles.birthdate > ? AND profiles.birthdate <= ?", earliest.Format("2006-01-02"), latest.Format("2006-01-02"))
+		if len(preference.Genders) > 0 {
+			db = db.Where("profiles.gender IN ?", preference.Genders)
+		}
+		if preference.OnlyWithPhoto {
+			db = db.Where("profiles.picture <> ''")
+		}
+		return db
+	}
+}
+
+// accepts keeps the profiles whose owner's preferences match the viewer. An owner without
+// preferences accepts the viewer when the viewer's gender is among those the candidate is
+// interested in. It expects discovery_preferences to be joined on the candidate's user. The
+// candidate's radius is checked against the distance computed by wantedBy, and a viewer without a
+// location is only accepted by candidates who have not set a maximum distance.
+func accepts(viewer *entity.Profile, now time.Time) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		conditions := db.Session(&gorm.Session{NewDB: true})
+		if age := viewer.AgeAt(now); age != nil {
+			conditions = conditions.Where("? BETWEEN discovery_preferences.min_age AND discovery_preferences.max_age", *age)
+		} else {
+			conditions = conditions.Where("FALSE")
+		}
+		conditions = conditions.Where("(jsonb_array_length(discovery_preferences.genders) = 0 OR discovery_preferences.genders @> jsonb_build_array(?::text))", viewer.Gender)
+		if viewer.HasLocation() {
+			conditions = conditions.Where("earth_distance(?, ll_to_earth(profiles.latitude, profiles.longitude)) <= COALESCE(discovery_preferences.max_distance_km, ?) * 1000", viewerPoint(viewer), entity.DefaultMaxDistanceKm)
+		} else {
+			conditions = conditions.Where("discovery_preferences.max_distance_km IS NULL")
+		}
+		if viewer.Picture == "" {
+			conditions = conditions.Where("NOT discovery_preferences.only_with_photo")
+		}
+		interested := db.Session(&gorm.Session{NewDB: true}).Where("discovery_preferences.id IS NULL").
+			Where("(jsonb_array_length(profiles.interested_in) = 0 OR profiles.interested_in @> jsonb_build_array(?::text))", viewer.Gender)
+		return db.Where(db.Session(&gorm.Session{NewDB: true}).Where(interested).Or(conditions))
+	}
+}
+
+// viewerPoint is the viewer's location as an earthdistance point. Candidates are compared with
+// ll_to_earth(profiles.latitude, profiles.longitude) so the profiles_location_idx index applies.
+func viewerPoint(viewer *entity.Profile) interface{} {
+	return gorm.Expr("ll_to_earth(?, ?)", *viewer.Latitude, *viewer.Longitude)
+}
+
+func (r *ProfileRepository) SaveViewLog(ctx context.Context, viewerId, profileId int) error {
 	viewLog := entity.ProfileViewLog{
 		ViewerID:  uint(viewerId),
 		ProfileID: uint(profileId),
 	}
-	if err := r.db.Save(&viewLog).Error; err != nil {
+	if err := r.db.WithContext(ctx).Save(&viewLog).Error; err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *ProfileRepository) FindByUserID(userId int) (*entity.Profile, error) {
+func (r *ProfileRepository) FindByUserID(ctx context.Context, userId int) (*entity.Profile, error) {
 	var profile entity.Profile
-	if err := r.db.Where("user_id = ?", userId).First(&profile).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userId).First(&profile).Error; err != nil {
 		return nil, err
 	}
 	return &profile, nil
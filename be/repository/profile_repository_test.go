@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the viewer's user and profile IDs differ so using one in place of the other is caught
+var viewerProfile = fakeResult{
+	columns: []string{"id", "user_id"},
+	rows:    [][]driver.Value{{int64(1), int64(10)}},
+}
+
+func TestGetRandomProfileUsesViewerProfileID(t *testing.T) {
+	db, fake := newFakeDB(t, map[string]fakeResult{`SELECT * FROM "profiles"`: viewerProfile})
+	repo := NewProfileRepository(db)
+
+	_, _ = repo.GetRandomProfile(context.Background(), 1, time.Hour)
+
+	assert.Contains(t, fake.statement(t, `"profiles"."id" = 1`), "LIMIT 1")
+	assert.Contains(t, fake.statement(t, "discovery_preferences"), `user_id = 10`)
+	query := fake.statement(t, "profile_view_logs")
+	assert.Contains(t, query, "viewer_id = 1 ")
+	assert.Contains(t, query, "profiles.id <> 1 ")
+	assert.Contains(t, query, `FROM "swipes" WHERE profile_id = 1 `)
+	assert.Contains(t, query, `SELECT partner_id FROM "matches" WHERE profile_id = 1)`)
+	assert.Contains(t, query, `SELECT profile_id FROM "matches" WHERE partner_id = 1)`)
+	assert.NotContains(t, query, "viewer_id = 10")
+	assert.NotContains(t, query, "profile_id = 10")
+}
+
+func TestGetRandomProfileServesSuperlikersFirst(t *testing.T) {
+	db, fake := newFakeDB(t, map[string]fakeResult{`SELECT * FROM "profiles"`: viewerProfile})
+	repo := NewProfileRepository(db)
+
+	_, _ = repo.GetRandomProfile(context.Background(), 1, time.Hour)
+
+	query := fake.statement(t, "profile_view_logs")
+	assert.Contains(t, query, `EXISTS (SELECT 1 FROM "swipes" WHERE swipes.profile_id = profiles.id AND swipes.target_id = 1 AND swipes.direction = 'superlike') AS superliked`)
+	assert.Contains(t, query, "ORDER BY superliked DESC,RANDOM()")
+}
+
+func TestGetRandomProfileComputesMissingDistance(t *testing.T) {
+	db, _ := newFakeDB(t, map[string]fakeResult{
+		`SELECT * FROM "profiles"`: {
+			columns: []string{"id", "user_id", "latitude", "longitude"},
+			rows:    [][]driver.Value{{int64(1), int64(10), -6.2088, 106.8456}},
+		},
+		"profile_view_logs": {
+			columns: []string{"id", "user_id", "latitude", "longitude"},
+			rows:    [][]driver.Value{{int64(2), int64(20), -6.9175, 107.6191}},
+		},
+	})
+	repo := NewProfileRepository(db)
+
+	profile, err := repo.GetRandomProfile(context.Background(), 1, time.Hour)
+
+	assert.NoError(t, err)
+	if assert.NotNil(t, profile.DistanceKm) {
+		assert.InDelta(t, 116.0, *profile.DistanceKm, 1)
+	}
+}
+
+func TestGetRandomProfileFallsBackToInterestedIn(t *testing.T) {
+	db, fake := newFakeDB(t, map[string]fakeResult{`SELECT * FROM "profiles"`: {
+		columns: []string{"id", "user_id", "gender", "interested_in"},
+		rows:    [][]driver.Value{{int64(1), int64(10), "male", `["female"]`}},
+	}})
+	repo := NewProfileRepository(db)
+
+	_, _ = repo.GetRandomProfile(context.Background(), 1, time.Hour)
+
+	query := fake.statement(t, "profile_view_logs")
+	assert.Contains(t, query, "profiles.gender IN ('female')")
+	assert.Contains(t, query, "(discovery_preferences.id IS NULL AND ((jsonb_array_length(profiles.interested_in) = 0 OR profiles.interested_in @> jsonb_build_array('male'::text)))) OR")
+}
+
+func TestGetRandomProfileReadsDistance(t *testing.T) {
+	db, fake := newFakeDB(t, map[string]fakeResult{`SELECT * FROM "profiles"`: {
+		columns: []string{"id", "user_id", "latitude", "longitude"},
+		rows:    [][]driver.Value{{int64(1), int64(10), -6.2, 106.8}},
+	}})
+	repo := NewProfileRepository(db)
+
+	_, _ = repo.GetRandomProfile(context.Background(), 1, time.Hour)
+
+	query := fake.statement(t, "profile_view_logs")
+	assert.Contains(t, query, "earth_distance(ll_to_earth(-6.2, 106.8), ll_to_earth(profiles.latitude, profiles.longitude)) / 1000 AS distance_km")
+	assert.Contains(t, query, "earth_distance(ll_to_earth(-6.2, 106.8), ll_to_earth(profiles.latitude, profiles.longitude)) <= 100000")
+}
+
+func TestSaveViewLogUsesProfileIDs(t *testing.T) {
+	db, fake := newFakeDB(t, nil)
+	repo := NewProfileRepository(db)
+
+	assert.NoError(t, repo.SaveViewLog(context.Background(), 1, 2))
+
+	assert.Contains(t, fake.statement(t, "profile_view_logs"), "(1,2,")
+}
+
+func TestFindByIDAbortsWhenContextCancelled(t *testing.T) {
+	db, _ := newFakeDB(t, map[string]fakeResult{`SELECT * FROM "profiles"`: viewerProfile})
+	repo := NewProfileRepository(db)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := repo.FindByID(ctx, 1)
+
+	assert.ErrorIs(t, err, context.Canceled)
+}
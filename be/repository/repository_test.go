@@ -0,0 +1,170 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"main/entity"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB connects to the database in TEST_DATABASE_DSN, which must have the migrations applied.
+// Tests that need real locking and constraints are skipped without it.
+func newTestDB(t *testing.T) *gorm.DB {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		Logger:         logger.Discard,
+		TranslateError: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// createTestProfile creates a user with a profile, both deleted with everything swiped at the end of the test.
+func createTestProfile(t *testing.T, db *gorm.DB) *entity.Profile {
+	user := &entity.User{
+		Name:     "Test",
+		Email:    fmt.Sprintf("test-%d@example.com", time.Now().UnixNano()),
+		Password: "password",
+	}
+	if err := db.Omit(clause.Associations).Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	profile := &entity.Profile{UserID: user.ID}
+	if err := db.Omit(clause.Associations).Create(profile).Error; err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Where("profile_id = ? OR partner_id = ?", profile.ID, profile.ID).Delete(&entity.Match{})
+		db.Where("profile_id = ? OR target_id = ?", profile.ID, profile.ID).Delete(&entity.Swipe{})
+		db.Where("profile_id = ? OR viewer_id = ?", profile.ID, profile.ID).Delete(&entity.ProfileViewLog{})
+		db.Delete(profile)
+		db.Delete(user)
+	})
+	return profile
+}
+
+// fakeDB records the SQL sent by a repository without a database. Queries are answered with the
+// first result whose key is contained in the statement, or with no rows.
+type fakeDB struct {
+	results    map[string]fakeResult
+	statements []string
+}
+
+type fakeResult struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func newFakeDB(t *testing.T, results map[string]fakeResult) (*gorm.DB, *fakeDB) {
+	fake := &fakeDB{results: results}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(fake)}), &gorm.Config{
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := func(db *gorm.DB) {
+		// subqueries are built by running the callbacks in dry run mode
+		if db.DryRun {
+			return
+		}
+		fake.statements = append(fake.statements, db.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...))
+	}
+	db.Callback().Query().After("gorm:query").Register("test:record", record)
+	db.Callback().Row().After("gorm:row").Register("test:record", record)
+	db.Callback().Raw().After("gorm:raw").Register("test:record", record)
+	db.Callback().Create().After("gorm:create").Register("test:record", record)
+	db.Callback().Update().After("gorm:update").Register("test:record", record)
+	db.Callback().Delete().After("gorm:delete").Register("test:record", record)
+	return db, fake
+}
+
+// statement returns the recorded statement containing fragment.
+func (f *fakeDB) statement(t *testing.T, fragment string) string {
+	t.Helper()
+	for _, statement := range f.statements {
+		if strings.Contains(statement, fragment) {
+			return statement
+		}
+	}
+	t.Fatalf("no statement contains %q in %q", fragment, f.statements)
+	return ""
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{db: f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *fakeConn) Commit() error             { return nil }
+func (c *fakeConn) Rollback() error           { return nil }
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	for key, result := range c.db.results {
+		if strings.Contains(query, key) {
+			return &fakeRows{result: result}, nil
+		}
+	}
+	return &fakeRows{}, nil
+}
+
+func (c *fakeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, nil)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, nil)
+}
+
+type fakeRows struct {
+	result fakeResult
+	next   int
+}
+
+func (r *fakeRows) Columns() []string { return r.result.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.next >= len(r.result.rows) {
+		return io.EOF
+	}
+	copy(dest, r.result.rows[r.next])
+	r.next++
+	return nil
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"main/entity"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SessionRepositoryInterface interface {
+	Create(ctx context.Context, session *entity.Session) error
+	FindByID(ctx context.Context, id string) (*entity.Session, error)
+	ListActive(ctx context.Context, userID int) ([]entity.Session, error)
+	Touch(ctx context.Context, id string, ip string, seenAt time.Time) error
+	Revoke(ctx context.Context, userID int, id string) (bool, error)
+}
+
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepositoryInterface {
+	return &SessionRepository{
+		db: db,
+	}
+}
+
+func (r *SessionRepository) Create(ctx context.Context, session *entity.Session) error {
+	if err := r.db.WithContext(ctx).Create(session).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *SessionRepository) FindByID(ctx context.Context, id string) (*entity.Session, error) {
+	var session entity.Session
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListActive returns the sessions of the user that are not revoked and can still be refreshed,
+// most recently used first.
+func (r *SessionRepository) ListActive(ctx context.Context, userID int) ([]entity.Session, error) {
+	var sessions []entity.Session
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Where("EXISTS (SELECT 1 FROM refresh_tokens WHERE refresh_tokens.family_id = sessions.id AND refresh_tokens.revoked_at IS NULL AND refresh_tokens.expires_at > ?)", time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *SessionRepository) Touch(ctx context.Context, id string, ip string, seenAt time.Time) error {
+	if err := r.db.WithContext(ctx).Model(&entity.Session{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"ip":           ip,
+			"last_seen_at": seenAt,
+		}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// Revoke revokes a session of the user together with its refresh tokens.
+// false is returned when the user has no such active session.
+func (r *SessionRepository) Revoke(ctx context.Context, userID int, id string) (bool, error) {
+	revoked := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&entity.Session{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userID).
+			Update("revoked_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		revoked = true
+		return tx.Model(&entity.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", now).Error
+	})
+	if err != nil {
+		return false, err
+	}
+	return revoked, nil
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"main/entity"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrTokenAlreadyRotated = errors.New("refresh token already rotated")
+
+type TokenRepositoryInterface interface {
+	Create(ctx context.Context, token *entity.RefreshToken) error
+	FindByHash(ctx context.Context, hash string) (*entity.RefreshToken, error)
+	Rotate(ctx context.Context, oldToken *entity.RefreshToken, newToken *entity.RefreshToken) error
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeAllForUser(ctx context.Context, userID int) error
+	CreatePasswordReset(ctx context.Context, token *entity.PasswordResetToken) error
+	ConsumePasswordReset(ctx context.Context, hash string) (*entity.PasswordResetToken, error)
+}
+
+type TokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) TokenRepositoryInterface {
+	return &TokenRepository{
+		db: db,
+	}
+}
+
+func (r *TokenRepository) Create(ctx context.Context, token *entity.RefreshToken) error {
+	if err := r.db.WithContext(ctx).Create(token).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *TokenRepository) FindByHash(ctx context.Context, hash string) (*entity.RefreshToken, error) {
+	var token entity.RefreshToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Rotate revokes oldToken and stores newToken as its replacement in a single transaction.
+// ErrTokenAlreadyRotated is returned when oldToken was revoked concurrently.
+func (r *TokenRepository) Rotate(ctx context.Context, oldToken *entity.RefreshToken, newToken *entity.RefreshToken) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(newToken).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&entity.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", oldToken.ID).
+			Updates(map[string]interface{}{
+				"revoked_at":     time.Now(),
+				"replaced_by_id": newToken.ID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrTokenAlreadyRotated
+		}
+		return nil
+	})
+}
+
+// RevokeFamily revokes every refresh token of the family and the session it belongs to.
+func (r *TokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&entity.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&entity.Session{}).
+			Where("id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// RevokeAllForUser revokes every session, refresh token and outstanding password reset token of the user.
+func (r *TokenRepository) RevokeAllForUser(ctx context.Context, userID int) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&entity.Session{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&entity.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userID).
+			Update("revoked_at", now).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&entity.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", userID).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+func (r *TokenRepository) CreatePasswordReset(ctx context.Context, token *entity.PasswordResetToken) error {
+	if err := r.db.WithContext(ctx).Create(token).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// ConsumePasswordReset atomically marks an unused, unexpired reset token as used and returns it.
+// nil is returned when no such token exists.
+func (r *TokenRepository) ConsumePasswordReset(ctx context.Context, hash string) (*entity.PasswordResetToken, error) {
+	var tokens []entity.PasswordResetToken
+	now := time.Now()
+	result := r.db.WithContext(ctx).Model(&tokens).
+		Clauses(clause.Returning{}).
+		Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", hash, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	return &tokens[0], nil
+}
@@ -1,21 +1,33 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"main/entity"
 	"main/helpers"
 	"time"
 
-	"github.com/labstack/echo/v4"
 	"gorm.io/gorm"
 )
 
+var ErrDuplicateEmail = errors.New("email already registered")
+
 type UserRepositoryInterface interface {
-	FindByID(id int) (*entity.User, error)
-	FindByEmail(email string) (*entity.User, error)
-	Save(user *entity.User) (*entity.User, error)
-	Update(user *entity.User) (*entity.User, error)
-	Subscribe(c echo.Context) (*entity.User, error)
-	CheckSubscription(c echo.Context) (bool, error)
+	FindByID(ctx context.Context, id int) (*entity.User, error)
+	FindByEmail(ctx context.Context, email string) (*entity.User, error)
+	Save(ctx context.Context, user *entity.User) (*entity.User, error)
+	Update(ctx context.Context, user *entity.User) (*entity.User, error)
+	Subscribe(ctx context.Context, userId int) (*entity.User, error)
+	CheckSubscription(ctx context.Context, userId int) (bool, error)
+	Verify(ctx context.Context, id int) error
+	UpdatePassword(ctx context.Context, id int, password string) error
+	SetTOTPSecret(ctx context.Context, id int, secret string) error
+	EnableTOTP(ctx context.Context, id int, recoveryCodeHashes []string) error
+	DisableTOTP(ctx context.Context, id int) error
+	UseTOTPStep(ctx context.Context, id int, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, id int, codeHash string) (bool, error)
+	ScheduleDeletion(ctx context.Context, id int, at time.Time) error
+	CancelDeletion(ctx context.Context, id int) error
 }
 
 type UserRepository struct {
@@ -28,31 +40,31 @@ func NewUserRepository(db *gorm.DB) UserRepositoryInterface {
 	}
 }
 
-func (r *UserRepository) FindByID(id int) (*entity.User, error) {
+func (r *UserRepository) FindByID(ctx context.Context, id int) (*entity.User, error) {
 	var user entity.User
-	err := r.db.Preload("Profile").Preload("Subscription").First(&user, id).Error
+	err := r.db.WithContext(ctx).Preload("Profile").Preload("Profile.Photos", orderedPhotos).Preload("Subscription").First(&user, id).Error
 	if err != nil {
 		return &entity.User{}, err
 	}
 	return &user, nil
 }
 
-func (r *UserRepository) FindByEmail(email string) (*entity.User, error) {
+func (r *UserRepository) FindByEmail(ctx context.Context, email string) (*entity.User, error) {
 	var user entity.User
-	err := r.db.Where("email = ?", email).Preload("Profile").First(&user).Error
+	err := r.db.WithContext(ctx).Where("LOWER(email) = LOWER(?)", email).Preload("Profile").First(&user).Error
 	if err != nil {
 		return &entity.User{}, err
 	}
 	return &user, nil
 }
 
-func (r *UserRepository) Save(user *entity.User) (*entity.User, error) {
+func (r *UserRepository) Save(ctx context.Context, user *entity.User) (*entity.User, error) {
 	hashedPassword, err := helpers.HashPassword(user.Password)
 	if err != nil {
 		return &entity.User{}, err
 	}
 	user.Password = *hashedPassword
-	err = r.db.Transaction(func(tx *gorm.DB) error {
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(&user).Error; err != nil {
 			return err
 		}
@@ -64,30 +76,32 @@ func (r *UserRepository) Save(user *entity.User) (*entity.User, error) {
 		return nil
 	})
 	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return &entity.User{}, ErrDuplicateEmail
+		}
 		return &entity.User{}, err
 	}
 	return user, nil
 }
 
-func (r *UserRepository) Update(user *entity.User) (*entity.User, error) {
+func (r *UserRepository) Update(ctx context.Context, user *entity.User) (*entity.User, error) {
 	hashedPassword, err := helpers.HashPassword(user.Password)
 	if err != nil {
 		return &entity.User{}, err
 	}
 	user.Password = *hashedPassword
-	err = r.db.Save(&user).Error
+	err = r.db.WithContext(ctx).Save(&user).Error
 	if err != nil {
 		return &entity.User{}, err
 	}
 	return user, nil
 }
 
-func (r *UserRepository) Subscribe(ctx echo.Context) (*entity.User, error) {
-	userId := uint(ctx.Get("user_id").(int))
+func (r *UserRepository) Subscribe(ctx context.Context, userId int) (*entity.User, error) {
 	var user entity.User
-	err := r.db.Transaction(func(tx *gorm.DB) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		if err := tx.Create(&entity.Subscription{
-			UserID:     userId,
+			UserID:     uint(userId),
 			ValidUntil: time.Now().AddDate(0, 1, 0),
 		}).Error; err != nil {
 			return err
@@ -99,7 +113,7 @@ func (r *UserRepository) Subscribe(ctx echo.Context) (*entity.User, error) {
 	}
 
 	// Load the created subscription into the user object
-	err = r.db.Preload("Subscription").First(&user, userId).Error
+	err = r.db.WithContext(ctx).Preload("Subscription").First(&user, userId).Error
 	if err != nil {
 		return &entity.User{}, err
 	}
@@ -107,10 +121,9 @@ func (r *UserRepository) Subscribe(ctx echo.Context) (*entity.User, error) {
 	return &user, nil
 }
 
-func (r *UserRepository) CheckSubscription(ctx echo.Context) (bool, error) {
-	userId := ctx.Get("user_id")
+func (r *UserRepository) CheckSubscription(ctx context.Context, userId int) (bool, error) {
 	var subscription entity.Subscription
-	err := r.db.Where("user_id = ?", userId).First(&subscription).Error
+	err := r.db.WithContext(ctx).Where("user_id = ?", userId).First(&subscription).Error
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
 			return false, nil
@@ -122,3 +135,114 @@ func (r *UserRepository) CheckSubscription(ctx echo.Context) (bool, error) {
 	}
 	return true, nil
 }
+
+func (r *UserRepository) Verify(ctx context.Context, id int) error {
+	if err := r.db.WithContext(ctx).Model(&entity.User{}).
+		Where("id = ? AND verified_at IS NULL", id).
+		Update("verified_at", time.Now()).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int, password string) error {
+	hashedPassword, err := helpers.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := r.db.WithContext(ctx).Model(&entity.User{}).
+		Where("id = ?", id).
+		Update("password", *hashedPassword).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// SetTOTPSecret stores a pending TOTP secret. It is not enforced until EnableTOTP is called.
+func (r *UserRepository) SetTOTPSecret(ctx context.Context, id int, secret string) error {
+	if err := r.db.WithContext(ctx).Model(&entity.User{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_enabled_at": nil, "totp_last_step": nil}).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+// EnableTOTP activates two-factor authentication and replaces the user's recovery codes.
+func (r *UserRepository) EnableTOTP(ctx context.Context, id int, recoveryCodeHashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&entity.User{}).
+			Where("id = ?", id).
+			Update("totp_enabled_at", time.Now()).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&entity.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]entity.MFARecoveryCode, 0, len(recoveryCodeHashes))
+		for _, hash := range recoveryCodeHashes {
+			codes = append(codes, entity.MFARecoveryCode{UserID: uint(id), CodeHash: hash})
+		}
+		if len(codes) > 0 {
+			if err := tx.Create(&codes).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (r *UserRepository) DisableTOTP(ctx context.Context, id int) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&entity.User{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{"totp_secret": nil, "totp_enabled_at": nil, "totp_last_step": nil}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", id).Delete(&entity.MFARecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+// UseTOTPStep records the time step of an accepted TOTP code.
+// It returns false when a code from the same or a later step was already used.
+func (r *UserRepository) UseTOTPStep(ctx context.Context, id int, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&entity.User{}).
+		Where("id = ? AND (totp_last_step IS NULL OR totp_last_step < ?)", id, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used and reports whether one matched.
+func (r *UserRepository) UseRecoveryCode(ctx context.Context, id int, codeHash string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&entity.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", id, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *UserRepository) ScheduleDeletion(ctx context.Context, id int, at time.Time) error {
+	if err := r.db.WithContext(ctx).Model(&entity.User{}).
+		Where("id = ?", id).
+		Update("deletion_scheduled_at", at).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *UserRepository) CancelDeletion(ctx context.Context, id int) error {
+	if err := r.db.WithContext(ctx).Model(&entity.User{}).
+		Where("id = ?", id).
+		Update("deletion_scheduled_at", nil).Error; err != nil {
+		return err
+	}
+	return nil
+}
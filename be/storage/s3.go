@@ -0,0 +1,237 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const s3TimeFormat = "20060102T150405Z"
+
+type S3Options struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PublicURL is the base URL objects are linked under. It defaults to the bucket URL.
+	PublicURL string
+}
+
+// S3Store keeps blobs in a bucket of an S3-compatible service such as AWS S3 or MinIO.
+// Requests use path-style addressing and are signed with AWS Signature Version 4.
+type S3Store struct {
+	opts       S3Options
+	endpoint   *url.URL
+	httpClient *http.Client
+	now        func() time.Time
+}
+
+func NewS3Store(opts S3Options, httpClient *http.Client) (BlobStore, error) {
+	if opts.Endpoint == "" || opts.Bucket == "" {
+		return nil, errors.New("S3 endpoint and bucket are required")
+	}
+	endpoint, err := url.Parse(strings.TrimSuffix(opts.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if opts.PublicURL == "" {
+		opts.PublicURL = endpoint.String() + "/" + opts.Bucket
+	}
+	opts.PublicURL = strings.TrimSuffix(opts.PublicURL, "/")
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &S3Store{
+		opts:       opts,
+		endpoint:   endpoint,
+		httpClient: httpClient,
+		now:        time.Now,
+	}, nil
+}
+
+// Put uploads the blob. The body is buffered so its SHA-256 can be signed; photos are small enough.
+func (s *S3Store) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	req, err := s.newRequest(ctx, http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	res, err := s.do(req, body)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	return nil
+}
+
+func (s *S3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := s.newRequest(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := s.do(req, nil)
+	if err != nil {
+		return nil, err
+	}
+	return res.Body, nil
+}
+
+// Delete removes the object. S3 reports success for missing objects too.
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	req, err := s.newRequest(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	res, err := s.do(req, nil)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if res != nil {
+		res.Body.Close()
+	}
+	return nil
+}
+
+func (s *S3Store) URL(key string) string {
+	return s.opts.PublicURL + "/" + key
+}
+
+func (s *S3Store) newRequest(ctx context.Context, method, key string, body []byte) (*http.Request, error) {
+	if key == "" {
+		return nil, errors.New("empty blob key")
+	}
+	target := *s.endpoint
+	target.Path = s.endpoint.Path + "/" + s.opts.Bucket + "/" + key
+	target.RawPath = escapePath(target.Path)
+	return http.NewRequestWithContext(ctx, method, target.String(), bytes.NewReader(body))
+}
+
+func (s *S3Store) do(req *http.Request, body []byte) (*http.Response, error) {
+	signV4(req, body, s.opts.AccessKey, s.opts.SecretKey, s.opts.Region, s.now())
+	res, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusNotFound {
+		res.Body.Close()
+		return nil, ErrNotFound
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+		res.Body.Close()
+		return nil, fmt.Errorf("%s %s returned %d: %s", req.Method, req.URL.Redacted(), res.StatusCode, message)
+	}
+	return res, nil
+}
+
+// signV4 adds the x-amz-date, x-amz-content-sha256 and Authorization headers of AWS Signature Version 4
+// for the s3 service. Host and every header already set on the request are signed.
+func signV4(req *http.Request, body []byte, accessKey, secretKey, region string, now time.Time) {
+	amzDate := now.UTC().Format(s3TimeFormat)
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{"AWS4-HMAC-SHA256", amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, escape(key)+"="+escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapePath URI-encodes every segment of the path the way SigV4 expects, keeping the slashes.
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func escape(value string) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,37 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"main/config"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps uploaded files under keys chosen by the application, such as photos/12/abc-full.jpg.
+type BlobStore interface {
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
+
+// New returns the blob store selected by the STORAGE_DRIVER config.
+func New(cfg *config.Storage) (BlobStore, error) {
+	switch cfg.Driver {
+	case "", "local":
+		return NewLocalStore(cfg.Dir, cfg.BaseURL)
+	case "s3":
+		return NewS3Store(S3Options{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+			PublicURL: cfg.S3PublicURL,
+		}, nil)
+	}
+	return nil, fmt.Errorf("unsupported storage driver %q", cfg.Driver)
+}
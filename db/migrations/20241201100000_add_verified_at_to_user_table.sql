@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN verified_at TIMESTAMP;
+
+-- accounts created before verification existed stay usable
+UPDATE users SET verified_at = created_at;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users DROP COLUMN verified_at;
+-- +goose StatementEnd
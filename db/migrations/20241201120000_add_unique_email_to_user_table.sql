@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+-- the oldest account keeps an address used with another case, newer ones get an address nobody can sign in with
+UPDATE users SET email = LOWER(TRIM(users.email)) || '#duplicate-' || users.id
+FROM (
+  SELECT id, ROW_NUMBER() OVER (PARTITION BY LOWER(TRIM(email)) ORDER BY created_at, id) AS position
+  FROM users
+) ranked
+WHERE ranked.id = users.id AND ranked.position > 1;
+
+UPDATE users SET email = LOWER(TRIM(email));
+CREATE UNIQUE INDEX users_email_unique_idx ON users (LOWER(email));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX users_email_unique_idx;
+-- +goose StatementEnd
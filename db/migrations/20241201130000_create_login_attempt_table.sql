@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE login_attempts (
+  key VARCHAR(320) PRIMARY KEY,
+  failures INT NOT NULL DEFAULT 0,
+  last_failed_at TIMESTAMP NOT NULL,
+  locked_until TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE login_lockouts (
+  id SERIAL PRIMARY KEY,
+  key VARCHAR(320) NOT NULL,
+  ip VARCHAR(64) NOT NULL,
+  failures INT NOT NULL,
+  locked_until TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX login_lockouts_key_idx ON login_lockouts (key);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE login_lockouts;
+DROP TABLE login_attempts;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE user_identities (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL,
+  provider VARCHAR(64) NOT NULL,
+  subject VARCHAR(255) NOT NULL,
+  email VARCHAR(255),
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX user_identities_provider_subject_idx ON user_identities (provider, subject);
+CREATE INDEX user_identities_user_id_idx ON user_identities (user_id);
+
+ALTER TABLE user_identities ADD CONSTRAINT user_identities_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id);
+
+CREATE TABLE oauth_states (
+  id SERIAL PRIMARY KEY,
+  state_hash VARCHAR(64) NOT NULL,
+  provider VARCHAR(64) NOT NULL,
+  nonce VARCHAR(64) NOT NULL,
+  code_verifier VARCHAR(128) NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX oauth_states_state_hash_idx ON oauth_states (state_hash);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE oauth_states;
+DROP TABLE user_identities;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN deletion_scheduled_at TIMESTAMP;
+
+CREATE INDEX users_deletion_scheduled_at_idx ON users (deletion_scheduled_at) WHERE deletion_scheduled_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX users_deletion_scheduled_at_idx;
+ALTER TABLE users DROP COLUMN deletion_scheduled_at;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE data_exports (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL,
+  status VARCHAR(16) NOT NULL,
+  file_path TEXT,
+  expires_at TIMESTAMP,
+  completed_at TIMESTAMP,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX data_exports_user_id_idx ON data_exports (user_id);
+CREATE INDEX data_exports_status_idx ON data_exports (status);
+
+ALTER TABLE data_exports ADD CONSTRAINT data_exports_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE data_exports;
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE profiles
+  ADD COLUMN birthdate DATE,
+  ADD COLUMN gender VARCHAR(16) NOT NULL DEFAULT '',
+  ADD COLUMN interested_in JSONB NOT NULL DEFAULT '[]',
+  ADD COLUMN city VARCHAR(100) NOT NULL DEFAULT '',
+  ADD COLUMN latitude DOUBLE PRECISION,
+  ADD COLUMN longitude DOUBLE PRECISION,
+  ADD COLUMN height_cm INT,
+  ADD COLUMN job VARCHAR(100) NOT NULL DEFAULT '',
+  ADD COLUMN interests JSONB NOT NULL DEFAULT '[]';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE profiles
+  DROP COLUMN birthdate,
+  DROP COLUMN gender,
+  DROP COLUMN interested_in,
+  DROP COLUMN city,
+  DROP COLUMN latitude,
+  DROP COLUMN longitude,
+  DROP COLUMN height_cm,
+  DROP COLUMN job,
+  DROP COLUMN interests;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE discovery_preferences (
+  id SERIAL PRIMARY KEY,
+  user_id INT NOT NULL,
+  min_age INT NOT NULL,
+  max_age INT NOT NULL,
+  genders JSONB NOT NULL DEFAULT '[]',
+  max_distance_km INT,
+  only_with_photo BOOLEAN NOT NULL DEFAULT FALSE,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX discovery_preferences_user_id_idx ON discovery_preferences (user_id);
+
+ALTER TABLE discovery_preferences ADD CONSTRAINT discovery_preferences_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE discovery_preferences;
+-- +goose StatementEnd
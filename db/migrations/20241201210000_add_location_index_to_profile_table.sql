@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE EXTENSION IF NOT EXISTS cube;
+CREATE EXTENSION IF NOT EXISTS earthdistance;
+
+ALTER TABLE profiles ADD COLUMN location_updated_at TIMESTAMP;
+
+CREATE INDEX profiles_location_idx ON profiles USING gist (ll_to_earth(latitude, longitude)) WHERE latitude IS NOT NULL AND longitude IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX profiles_location_idx;
+
+ALTER TABLE profiles DROP COLUMN location_updated_at;
+
+DROP EXTENSION IF EXISTS earthdistance;
+DROP EXTENSION IF EXISTS cube;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE profile_photos (
+  id SERIAL PRIMARY KEY,
+  profile_id INT NOT NULL,
+  position INT NOT NULL,
+  is_primary BOOLEAN NOT NULL DEFAULT FALSE,
+  thumbnail_key TEXT NOT NULL,
+  full_key TEXT NOT NULL,
+  thumbnail_url TEXT NOT NULL,
+  full_url TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX profile_photos_profile_id_idx ON profile_photos (profile_id, position);
+CREATE UNIQUE INDEX profile_photos_primary_idx ON profile_photos (profile_id) WHERE is_primary;
+
+ALTER TABLE profile_photos ADD CONSTRAINT profile_photos_profile_id_fkey FOREIGN KEY (profile_id) REFERENCES profiles (id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE profile_photos;
+-- +goose StatementEnd
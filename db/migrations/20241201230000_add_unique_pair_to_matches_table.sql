@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+-- keep a single row per ordered pair, preferring the furthest along
+DELETE FROM matches
+WHERE id IN (
+  SELECT id FROM (
+    SELECT id, ROW_NUMBER() OVER (
+      PARTITION BY profile_id, partner_id
+      ORDER BY CASE status WHEN 'accepted' THEN 0 WHEN 'pending' THEN 1 WHEN 'rejected' THEN 2 ELSE 3 END, id
+    ) AS position
+    FROM matches
+  ) ranked
+  WHERE position > 1
+);
+
+ALTER TABLE matches ADD CONSTRAINT matches_profile_id_partner_id_key UNIQUE (profile_id, partner_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE matches DROP CONSTRAINT matches_profile_id_partner_id_key;
+-- +goose StatementEnd
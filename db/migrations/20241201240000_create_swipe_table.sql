@@ -0,0 +1,73 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE swipes (
+  id SERIAL PRIMARY KEY,
+  profile_id INT NOT NULL,
+  target_id INT NOT NULL,
+  direction VARCHAR(16) NOT NULL,
+  created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX swipes_profile_id_target_id_idx ON swipes (profile_id, target_id);
+CREATE INDEX swipes_target_id_idx ON swipes (target_id, direction);
+
+ALTER TABLE swipes ADD CONSTRAINT swipes_profile_id_fkey FOREIGN KEY (profile_id) REFERENCES profiles (id);
+ALTER TABLE swipes ADD CONSTRAINT swipes_target_id_fkey FOREIGN KEY (target_id) REFERENCES profiles (id);
+
+-- every row was a like of profile_id except a pass
+INSERT INTO swipes (profile_id, target_id, direction, created_at, updated_at)
+SELECT profile_id, partner_id, CASE status WHEN 'passed' THEN 'pass' ELSE 'like' END, created_at, updated_at
+FROM matches
+ON CONFLICT DO NOTHING;
+
+-- an accepted row was liked back and a rejected row passed by the partner when it was last updated
+INSERT INTO swipes (profile_id, target_id, direction, created_at, updated_at)
+SELECT partner_id, profile_id, CASE status WHEN 'accepted' THEN 'like' ELSE 'pass' END, updated_at, updated_at
+FROM matches
+WHERE status IN ('accepted', 'rejected')
+ON CONFLICT DO NOTHING;
+
+-- two pending rows of the same pair were likes in both directions, keep them as a match
+INSERT INTO matches (profile_id, partner_id, status, created_at, updated_at)
+SELECT swipes.profile_id, swipes.target_id, 'accepted',
+  GREATEST(swipes.created_at, back.created_at), GREATEST(swipes.created_at, back.created_at)
+FROM swipes
+JOIN swipes back ON back.profile_id = swipes.target_id AND back.target_id = swipes.profile_id
+WHERE swipes.profile_id < swipes.target_id
+  AND swipes.direction = 'like' AND back.direction = 'like'
+  AND NOT EXISTS (
+    SELECT 1 FROM matches
+    WHERE LEAST(matches.profile_id, matches.partner_id) = swipes.profile_id
+      AND GREATEST(matches.profile_id, matches.partner_id) = swipes.target_id
+      AND matches.status = 'accepted'
+  )
+ON CONFLICT (profile_id, partner_id) DO UPDATE SET status = 'accepted', updated_at = EXCLUDED.updated_at;
+
+DELETE FROM matches WHERE status <> 'accepted';
+UPDATE matches SET created_at = updated_at;
+
+ALTER TABLE matches DROP CONSTRAINT matches_profile_id_partner_id_key;
+CREATE UNIQUE INDEX matches_pair_idx ON matches (LEAST(profile_id, partner_id), GREATEST(profile_id, partner_id));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX matches_pair_idx;
+ALTER TABLE matches ADD CONSTRAINT matches_profile_id_partner_id_key UNIQUE (profile_id, partner_id);
+
+INSERT INTO matches (profile_id, partner_id, status, created_at, updated_at)
+SELECT swipes.profile_id, swipes.target_id,
+  CASE WHEN swipes.direction = 'pass' THEN 'passed' WHEN back.direction = 'pass' THEN 'rejected' ELSE 'pending' END,
+  swipes.created_at, COALESCE(back.updated_at, swipes.updated_at)
+FROM swipes
+LEFT JOIN swipes back ON back.profile_id = swipes.target_id AND back.target_id = swipes.profile_id
+WHERE NOT EXISTS (
+  SELECT 1 FROM matches
+  WHERE LEAST(matches.profile_id, matches.partner_id) = LEAST(swipes.profile_id, swipes.target_id)
+    AND GREATEST(matches.profile_id, matches.partner_id) = GREATEST(swipes.profile_id, swipes.target_id)
+)
+AND NOT (swipes.direction = 'pass' AND back.direction IN ('like', 'superlike'));
+
+DROP TABLE swipes;
+-- +goose StatementEnd
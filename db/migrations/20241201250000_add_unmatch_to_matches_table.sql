@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE matches ADD COLUMN unmatched_by INT;
+ALTER TABLE matches ADD COLUMN unmatched_at TIMESTAMP;
+
+ALTER TABLE matches ADD CONSTRAINT matches_unmatched_by_fk FOREIGN KEY (unmatched_by) REFERENCES profiles (id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE matches DROP COLUMN unmatched_at;
+ALTER TABLE matches DROP COLUMN unmatched_by;
+-- +goose StatementEnd
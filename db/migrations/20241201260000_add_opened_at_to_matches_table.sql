@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE matches ADD COLUMN profile_opened_at TIMESTAMP;
+ALTER TABLE matches ADD COLUMN partner_opened_at TIMESTAMP;
+
+-- existing matches are not new anymore
+UPDATE matches SET profile_opened_at = updated_at, partner_opened_at = updated_at;
+
+CREATE INDEX matches_profile_id_created_at_idx ON matches (profile_id, created_at DESC, id DESC);
+CREATE INDEX matches_partner_id_created_at_idx ON matches (partner_id, created_at DESC, id DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX matches_partner_id_created_at_idx;
+DROP INDEX matches_profile_id_created_at_idx;
+ALTER TABLE matches DROP COLUMN partner_opened_at;
+ALTER TABLE matches DROP COLUMN profile_opened_at;
+-- +goose StatementEnd